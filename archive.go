@@ -0,0 +1,423 @@
+package log
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/base32"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"go.uber.org/multierr"
+	"go.uber.org/zap/zapcore"
+)
+
+const (
+	// archiveQueueSize is the number of sealed chunks waiting to be archived
+	// beyond which chunks are dropped.
+	archiveQueueSize = 16
+
+	// maxArchiveChunkSize is the default chunk size of IPFS. Chunks up to
+	// this size are added as a single raw block, so their CID is the one
+	// computed by ChunkCID.
+	maxArchiveChunkSize = 256 << 10
+
+	defaultArchiveInterval = time.Minute
+)
+
+// errArchiveStopped is returned when writing to a stopped archive.
+var errArchiveStopped = errors.New("log archive is stopped")
+
+// ArchiveConfig configures archiving of the log output in content-addressed
+// chunks. The output is cut into chunks that are sealed once full or after
+// Interval. Every sealed chunk is stored under its CID and recorded in the
+// manifest together with the CID of the previous chunk, so that the chunks
+// form a chain. The chain is not signed: it reveals chunks that were lost,
+// reordered or corrupted, but anyone who can write the archive can rebuild
+// it. Concatenating the chunks in manifest order yields the log output.
+//
+// Chunks are archived in the background. When archiving falls behind, i.e.
+// because the IPFS node is slow or unreachable, chunks are dropped rather
+// than blocking logging, and writes report an error.
+type ArchiveConfig struct {
+	// Dir is the directory the chunks are written to. Archiving is disabled
+	// when Dir is empty.
+	Dir string
+
+	// ChunkSize is the size of a full chunk in bytes. Defaults to, and is
+	// limited to, 256KiB.
+	ChunkSize int
+
+	// Interval bounds how long data stays in an unsealed chunk. Defaults to
+	// one minute.
+	Interval time.Duration
+
+	// IPFSAPI is the address of the HTTP API of an IPFS node chunks are
+	// added to and pinned on, i.e. "http://127.0.0.1:5001". Chunks are only
+	// stored in Dir when empty.
+	IPFSAPI string
+
+	// HTTPClient configures TLS and authentication for IPFSAPI.
+	HTTPClient HTTPClientConfig
+
+	// Manifest receives an ArchiveChunk as a line of JSON for every sealed
+	// chunk. Defaults to appending to the file "manifest" in Dir, in which
+	// case the chain continues from the last chunk recorded there.
+	Manifest io.Writer
+}
+
+// ArchiveChunk is a manifest record of a sealed chunk.
+type ArchiveChunk struct {
+	CID    string    `json:"cid"`
+	Size   int       `json:"size"`
+	Prev   string    `json:"prev,omitempty"`
+	Sealed time.Time `json:"sealed"`
+	// Pinned reports whether the chunk was added to the IPFS node.
+	Pinned bool `json:"pinned,omitempty"`
+}
+
+// ChunkCID returns the CIDv1 of data as a single raw block hashed with
+// SHA2-256, in its base32 string form.
+func ChunkCID(data []byte) string {
+	sum := sha256.Sum256(data)
+	// version 1, raw codec, sha2-256 multihash of 32 bytes.
+	raw := append([]byte{0x01, 0x55, 0x12, 0x20}, sum[:]...)
+	enc := base32.StdEncoding.WithPadding(base32.NoPadding)
+	// "b" is the multibase prefix of lowercase base32.
+	return "b" + strings.ToLower(enc.EncodeToString(raw))
+}
+
+type archiveJob struct {
+	data []byte
+	done chan struct{} // closed once the job is processed, may be nil
+}
+
+var _ zapcore.WriteSyncer = (*archiveWriteSyncer)(nil)
+
+// archiveWriteSyncer cuts the output into chunks and archives them in the
+// background.
+type archiveWriteSyncer struct {
+	pending int64 // bytes in queued jobs, accessed atomically
+
+	mu      sync.Mutex // guards buf, closed and sends of chunks on jobs
+	buf     []byte
+	size    int
+	closed  bool
+	jobs    chan archiveJob
+	dropped int // chunks dropped because jobs was full
+
+	dir      string
+	api      string
+	client   *http.Client
+	manifest io.Writer
+	file     *os.File // manifest file owned by the archive, if any
+	prev     string   // CID of the last sealed chunk
+
+	errMu sync.Mutex
+	err   error // errors since the last Sync
+
+	stop     chan struct{}
+	tickDone chan struct{}
+	sealDone chan struct{}
+	syncs    sync.WaitGroup // Syncs sending on jobs without holding mu
+	stopOnce sync.Once
+	stopErr  error
+}
+
+func newArchiveWriteSyncer(cfg ArchiveConfig) (*archiveWriteSyncer, error) {
+	size := cfg.ChunkSize
+	if size <= 0 || size > maxArchiveChunkSize {
+		size = maxArchiveChunkSize
+	}
+	interval := cfg.Interval
+	if interval <= 0 {
+		interval = defaultArchiveInterval
+	}
+	client, err := cfg.HTTPClient.Client()
+	if err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(cfg.Dir, 0o755); err != nil {
+		return nil, err
+	}
+
+	a := &archiveWriteSyncer{
+		size:     size,
+		jobs:     make(chan archiveJob, archiveQueueSize),
+		dir:      cfg.Dir,
+		api:      strings.TrimSuffix(cfg.IPFSAPI, "/"),
+		client:   client,
+		manifest: cfg.Manifest,
+		stop:     make(chan struct{}),
+		tickDone: make(chan struct{}),
+		sealDone: make(chan struct{}),
+	}
+	if a.manifest == nil {
+		path := filepath.Join(cfg.Dir, "manifest")
+		prev, err := lastArchivedChunk(path)
+		if err != nil {
+			return nil, err
+		}
+		f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o644)
+		if err != nil {
+			return nil, err
+		}
+		a.manifest, a.file, a.prev = f, f, prev
+	}
+
+	go a.sealLoop()
+	go a.tickLoop(interval)
+	return a, nil
+}
+
+// lastArchivedChunk returns the CID of the last chunk recorded in the
+// manifest at path, if it exists.
+func lastArchivedChunk(path string) (string, error) {
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return "", nil
+	} else if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	var last string
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var chunk ArchiveChunk
+		if err := json.Unmarshal(scanner.Bytes(), &chunk); err == nil && chunk.CID != "" {
+			last = chunk.CID
+		}
+	}
+	return last, scanner.Err()
+}
+
+func (a *archiveWriteSyncer) Write(p []byte) (int, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if a.closed {
+		return 0, errArchiveStopped
+	}
+	n := len(p)
+	var err error
+	for len(p) > 0 {
+		free := a.size - len(a.buf)
+		if free > len(p) {
+			free = len(p)
+		}
+		a.buf = append(a.buf, p[:free]...)
+		p = p[free:]
+		if len(a.buf) == a.size {
+			err = multierr.Append(err, a.seal())
+		}
+	}
+	return n, err
+}
+
+// Sync seals the open chunk and waits until all chunks are archived. It
+// returns the errors that occurred since the last Sync.
+func (a *archiveWriteSyncer) Sync() error {
+	a.mu.Lock()
+	if a.closed {
+		a.mu.Unlock()
+		return errArchiveStopped
+	}
+	err := a.seal()
+	a.syncs.Add(1)
+	a.mu.Unlock()
+
+	// the marker is sent without holding mu, so that writes don't wait for
+	// room in the queue.
+	done := make(chan struct{})
+	a.jobs <- archiveJob{done: done}
+	a.syncs.Done()
+	<-done
+
+	a.errMu.Lock()
+	defer a.errMu.Unlock()
+	err = multierr.Append(err, a.err)
+	a.err = nil
+	return err
+}
+
+// Stop archives the open chunk and stops the archive. Calls after the first
+// return the result of the first.
+func (a *archiveWriteSyncer) Stop() error {
+	a.stopOnce.Do(func() {
+		close(a.stop)
+		<-a.tickDone
+
+		a.mu.Lock()
+		last := a.buf
+		a.buf = nil
+		a.closed = true
+		a.mu.Unlock()
+
+		// nothing else is sent on jobs once the Syncs are done, the last
+		// chunk is queued even if archiving is behind.
+		a.syncs.Wait()
+		if len(last) > 0 {
+			atomic.AddInt64(&a.pending, int64(len(last)))
+			a.jobs <- archiveJob{data: last}
+		}
+		close(a.jobs)
+		<-a.sealDone
+
+		a.errMu.Lock()
+		err := a.err
+		a.errMu.Unlock()
+		if a.file != nil {
+			err = multierr.Append(err, a.file.Close())
+		}
+		a.stopErr = err
+	})
+	return a.stopErr
+}
+
+// seal queues the open chunk for archiving. The chunk is dropped when the
+// queue is full. a.mu must be held.
+func (a *archiveWriteSyncer) seal() error {
+	if len(a.buf) == 0 {
+		return nil
+	}
+	select {
+	case a.jobs <- archiveJob{data: a.buf}:
+	default:
+		a.dropped++
+		err := fmt.Errorf("log archive is behind, dropped a chunk of %d bytes (%d in total)", len(a.buf), a.dropped)
+		a.buf = nil
+		return err
+	}
+	atomic.AddInt64(&a.pending, int64(len(a.buf)))
+	a.buf = nil
+	return nil
+}
+
+func (a *archiveWriteSyncer) queued() int {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return len(a.buf) + int(atomic.LoadInt64(&a.pending))
+}
+
+func (a *archiveWriteSyncer) tickLoop(interval time.Duration) {
+	defer close(a.tickDone)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			a.mu.Lock()
+			err := a.seal()
+			a.mu.Unlock()
+			if err != nil {
+				a.errMu.Lock()
+				a.err = multierr.Append(a.err, err)
+				a.errMu.Unlock()
+			}
+		case <-a.stop:
+			return
+		}
+	}
+}
+
+func (a *archiveWriteSyncer) sealLoop() {
+	defer close(a.sealDone)
+
+	for job := range a.jobs {
+		if len(job.data) > 0 {
+			if err := a.archive(job.data); err != nil {
+				a.errMu.Lock()
+				a.err = multierr.Append(a.err, err)
+				a.errMu.Unlock()
+			}
+			atomic.AddInt64(&a.pending, -int64(len(job.data)))
+		}
+		if job.done != nil {
+			close(job.done)
+		}
+	}
+}
+
+// archive stores a sealed chunk and records it in the manifest.
+func (a *archiveWriteSyncer) archive(data []byte) error {
+	chunk := ArchiveChunk{
+		CID:    ChunkCID(data),
+		Size:   len(data),
+		Prev:   a.prev,
+		Sealed: time.Now().UTC(),
+	}
+	if err := writeFileAtomic(a.dir, chunk.CID, data); err != nil {
+		return err
+	}
+
+	var err error
+	if a.api != "" {
+		if err = a.addToIPFS(chunk.CID, data); err == nil {
+			chunk.Pinned = true
+		}
+	}
+
+	line, merr := json.Marshal(chunk)
+	if merr != nil {
+		return multierr.Append(err, merr)
+	}
+	if _, werr := a.manifest.Write(append(line, '\n')); werr != nil {
+		return multierr.Append(err, werr)
+	}
+	a.prev = chunk.CID
+	return err
+}
+
+// addToIPFS adds and pins a chunk on the IPFS node and checks that the node
+// computed the same CID.
+func (a *archiveWriteSyncer) addToIPFS(cid string, data []byte) error {
+	body := &bytes.Buffer{}
+	mw := multipart.NewWriter(body)
+	part, err := mw.CreateFormFile("file", cid)
+	if err != nil {
+		return err
+	}
+	if _, err := part.Write(data); err != nil {
+		return err
+	}
+	if err := mw.Close(); err != nil {
+		return err
+	}
+
+	url := a.api + "/api/v0/add?cid-version=1&raw-leaves=true&pin=true"
+	req, err := http.NewRequest(http.MethodPost, url, body)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", mw.FormDataContentType())
+	resp, err := a.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("adding log chunk to IPFS: %s", resp.Status)
+	}
+	var added struct{ Hash string }
+	if err := json.NewDecoder(resp.Body).Decode(&added); err != nil {
+		return err
+	}
+	if added.Hash != cid {
+		return fmt.Errorf("IPFS added log chunk %s as %s", cid, added.Hash)
+	}
+	return nil
+}
@@ -0,0 +1,185 @@
+package log
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestChunkCID(t *testing.T) {
+	// the well-known CID of the empty raw block.
+	want := "bafkreihdwdcefgh4dqkjv67uzcmw7ojee6xedzdetojuzjevtenxquvyku"
+	if got := ChunkCID(nil); got != want {
+		t.Errorf("got %s, want %s", got, want)
+	}
+}
+
+func TestArchiveWriteSyncer(t *testing.T) {
+	dir, err := ioutil.TempDir("", "go-log-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	var added []string
+	ipfs := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		f, _, err := r.FormFile("file")
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		data, _ := ioutil.ReadAll(f)
+		added = append(added, ChunkCID(data))
+		fmt.Fprintf(w, `{"Name":"chunk","Hash":%q}`, ChunkCID(data))
+	}))
+	defer ipfs.Close()
+
+	cfg := ArchiveConfig{Dir: dir, ChunkSize: 8, Interval: time.Hour, IPFSAPI: ipfs.URL}
+	a, err := newArchiveWriteSyncer(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := a.Write([]byte("first entry\n")); err != nil {
+		t.Fatal(err)
+	}
+	if err := a.Sync(); err != nil {
+		t.Fatal(err)
+	}
+	if err := a.Stop(); err != nil {
+		t.Fatal(err)
+	}
+
+	// a restarted archive continues the chain.
+	a, err = newArchiveWriteSyncer(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := a.Write([]byte("second\n")); err != nil {
+		t.Fatal(err)
+	}
+	if err := a.Stop(); err != nil {
+		t.Fatal(err)
+	}
+
+	f, err := os.Open(filepath.Join(dir, "manifest"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	var chunks []ArchiveChunk
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var chunk ArchiveChunk
+		if err := json.Unmarshal(scanner.Bytes(), &chunk); err != nil {
+			t.Fatal(err)
+		}
+		chunks = append(chunks, chunk)
+	}
+	if len(chunks) != 3 {
+		t.Fatalf("got %d chunks, want 3", len(chunks))
+	}
+
+	var log []byte
+	prev := ""
+	for i, chunk := range chunks {
+		if chunk.Prev != prev {
+			t.Errorf("chunk %d: got prev %q, want %q", i, chunk.Prev, prev)
+		}
+		if !chunk.Pinned || added[i] != chunk.CID {
+			t.Errorf("chunk %d was not added to IPFS", i)
+		}
+		data, err := ioutil.ReadFile(filepath.Join(dir, chunk.CID))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if ChunkCID(data) != chunk.CID || len(data) != chunk.Size {
+			t.Errorf("chunk %d does not match its manifest record", i)
+		}
+		log = append(log, data...)
+		prev = chunk.CID
+	}
+	if want := "first entry\nsecond\n"; !bytes.Equal(log, []byte(want)) {
+		t.Errorf("got log %q, want %q", log, want)
+	}
+}
+
+func TestArchiveDropsWhenBehind(t *testing.T) {
+	dir, err := ioutil.TempDir("", "go-log-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	release := make(chan struct{})
+	ipfs := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+		f, _, err := r.FormFile("file")
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		data, _ := ioutil.ReadAll(f)
+		fmt.Fprintf(w, `{"Name":"chunk","Hash":%q}`, ChunkCID(data))
+	}))
+	defer ipfs.Close()
+
+	a, err := newArchiveWriteSyncer(ArchiveConfig{Dir: dir, ChunkSize: 4, Interval: time.Hour, IPFSAPI: ipfs.URL})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// one chunk is being archived and archiveQueueSize are queued, the
+	// writes after that must not block.
+	done := make(chan error, 1)
+	go func() {
+		var err error
+		for i := 0; i < archiveQueueSize+4; i++ {
+			if _, werr := a.Write([]byte("abcd")); werr != nil {
+				err = werr
+			}
+		}
+		done <- err
+	}()
+	select {
+	case err := <-done:
+		if err == nil {
+			t.Error("expected an error for the dropped chunks")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("write blocked on a slow IPFS node")
+	}
+
+	// a Sync waiting for room in the queue doesn't block writes either.
+	synced := make(chan error, 1)
+	go func() { synced <- a.Sync() }()
+	time.Sleep(10 * time.Millisecond)
+	go func() {
+		_, err := a.Write([]byte("abcd"))
+		done <- err
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("write blocked behind Sync")
+	}
+	close(release)
+	if err := <-synced; err != nil {
+		t.Fatal(err)
+	}
+
+	if err := a.Stop(); err != nil {
+		t.Fatal(err)
+	}
+	// Stop is idempotent.
+	if err := a.Stop(); err != nil {
+		t.Fatal(err)
+	}
+}
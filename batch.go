@@ -0,0 +1,339 @@
+package log
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"go.uber.org/multierr"
+	"go.uber.org/zap"
+)
+
+const (
+	defaultBatchEntries  = 500
+	defaultBatchBytes    = 1 << 20
+	defaultBatchInterval = 5 * time.Second
+	defaultBatchQueued   = 16 << 20
+	defaultBatchRetries  = 5
+	defaultBatchBackoff  = time.Second
+	defaultBatchSpooled  = 64 << 20
+)
+
+var (
+	// errBatchSinkClosed is returned when writing to a closed BatchSink.
+	errBatchSinkClosed = errors.New("log batch sink is closed")
+	// errBatchQueueFull is returned when an entry is dropped because
+	// BatchConfig.MaxQueued is reached.
+	errBatchQueueFull = errors.New("log batch sink queue is full")
+)
+
+// BatchConfig configures a sink created with NewBatchSink.
+type BatchConfig struct {
+	// MaxEntries and MaxBytes limit the size of a batch. Default to 500
+	// entries and 1MiB.
+	MaxEntries int
+	MaxBytes   int
+
+	// FlushInterval bounds how long an entry waits for its batch to fill
+	// up. Defaults to five seconds.
+	FlushInterval time.Duration
+
+	// MaxQueued is the number of bytes that may wait to be sent, i.e. while
+	// the destination is unreachable. Further entries are dropped. Defaults
+	// to 16MiB.
+	MaxQueued int
+
+	// MaxRetries is the number of times a batch is retried after a
+	// RetryableError before it is dropped or spooled. Defaults to five.
+	MaxRetries int
+
+	// RetryBackoff is the delay before the first retry, doubled for every
+	// further one. Defaults to one second.
+	RetryBackoff time.Duration
+
+	// SpoolDir is a directory where batches that still fail with a
+	// RetryableError after MaxRetries are kept instead of being dropped.
+	// Spooled batches are resent, oldest first, before any new ones, also
+	// by a later process using the same directory. Disabled when empty.
+	SpoolDir string
+
+	// MaxSpooled is the number of bytes that may be kept in SpoolDir. The
+	// oldest batches are removed beyond it. Defaults to 64MiB.
+	MaxSpooled int64
+}
+
+// BatchSender sends a batch of entries. Every entry is the output of the
+// encoder for one log entry, without the trailing newline.
+type BatchSender func(entries [][]byte) error
+
+// RetryableError is returned by a BatchSender when sending the batch may
+// succeed later, i.e. when the destination is throttling or unavailable.
+type RetryableError struct {
+	Err error
+	// After is the delay the destination asked for. The backoff of
+	// BatchConfig is used when zero.
+	After time.Duration
+}
+
+func (e *RetryableError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *RetryableError) Unwrap() error {
+	return e.Err
+}
+
+// NewBatchSink returns a sink that sends the entries written to it in
+// batches, so that log collectors can be written to without a round trip per
+// entry. Batches are sent in the background by send, and retried with
+// backoff when send returns a RetryableError. Sync sends the queued entries
+// and returns the errors of the batches dropped since the last Sync. With
+// BatchConfig.SpoolDir, batches are kept on disk while the destination is
+// unavailable.
+//
+// Register it with zap.RegisterSink to log to it with Config.URL.
+func NewBatchSink(send BatchSender, cfg BatchConfig) zap.Sink {
+	if cfg.MaxEntries <= 0 {
+		cfg.MaxEntries = defaultBatchEntries
+	}
+	if cfg.MaxBytes <= 0 {
+		cfg.MaxBytes = defaultBatchBytes
+	}
+	if cfg.FlushInterval <= 0 {
+		cfg.FlushInterval = defaultBatchInterval
+	}
+	if cfg.MaxQueued <= 0 {
+		cfg.MaxQueued = defaultBatchQueued
+	}
+	if cfg.MaxRetries <= 0 {
+		cfg.MaxRetries = defaultBatchRetries
+	}
+	if cfg.RetryBackoff <= 0 {
+		cfg.RetryBackoff = defaultBatchBackoff
+	}
+	if cfg.MaxSpooled <= 0 {
+		cfg.MaxSpooled = defaultBatchSpooled
+	}
+
+	s := &batchSink{
+		send:  send,
+		cfg:   cfg,
+		kick:  make(chan struct{}, 1),
+		syncs: make(chan chan struct{}),
+		stop:  make(chan struct{}),
+		done:  make(chan struct{}),
+	}
+	if cfg.SpoolDir != "" {
+		spool, err := openBatchSpool(cfg.SpoolDir, cfg.MaxSpooled)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "failed to open log spool %q, failed batches will be dropped: %s\n", cfg.SpoolDir, err)
+		}
+		s.spool = spool
+	}
+	go s.loop()
+	return s
+}
+
+var _ zap.Sink = (*batchSink)(nil)
+
+type batchSink struct {
+	send  BatchSender
+	cfg   BatchConfig
+	spool *batchSpool // nil without SpoolDir, only used by loop
+
+	mu      sync.Mutex // guards the fields below
+	pending [][]byte
+	size    int // bytes in pending
+	queued  int // bytes in pending and in batches being sent
+	closed  bool
+	err     error // errors since the last Sync
+
+	kick  chan struct{}      // signals that a batch is full
+	syncs chan chan struct{} // requests to send all pending entries
+	stop  chan struct{}
+	done  chan struct{}
+}
+
+func (s *batchSink) Write(p []byte) (int, error) {
+	n := len(p)
+	if n > 0 && p[n-1] == '\n' {
+		p = p[:n-1]
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.closed {
+		return 0, errBatchSinkClosed
+	}
+	if s.queued+len(p) > s.cfg.MaxQueued {
+		return 0, errBatchQueueFull
+	}
+	s.pending = append(s.pending, append([]byte(nil), p...))
+	s.size += len(p)
+	s.queued += len(p)
+	if len(s.pending) >= s.cfg.MaxEntries || s.size >= s.cfg.MaxBytes {
+		select {
+		case s.kick <- struct{}{}:
+		default:
+		}
+	}
+	return n, nil
+}
+
+// Sync sends the queued entries.
+func (s *batchSink) Sync() error {
+	done := make(chan struct{})
+	select {
+	case s.syncs <- done:
+		<-done
+	case <-s.done:
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	err := s.err
+	s.err = nil
+	return err
+}
+
+// Close sends the queued entries and stops the sink. Batches that fail are
+// not retried after Close.
+func (s *batchSink) Close() error {
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		return nil
+	}
+	s.closed = true
+	s.mu.Unlock()
+
+	close(s.stop)
+	<-s.done
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.err
+}
+
+func (s *batchSink) loop() {
+	defer close(s.done)
+
+	ticker := time.NewTicker(s.cfg.FlushInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			s.flush()
+		case <-s.kick:
+			s.flush()
+		case done := <-s.syncs:
+			s.flush()
+			close(done)
+		case <-s.stop:
+			s.flush()
+			return
+		}
+	}
+}
+
+// flush sends the spooled batches and then the pending entries in batches
+// within the limits. Once a batch is spooled, the following ones are spooled
+// without being sent, to keep them in order.
+func (s *batchSink) flush() {
+	s.mu.Lock()
+	pending := s.pending
+	s.pending, s.size = nil, 0
+	s.mu.Unlock()
+
+	spooling := s.spool != nil && !s.replay()
+	for len(pending) > 0 {
+		n, size := 0, 0
+		for n < len(pending) && n < s.cfg.MaxEntries {
+			if n > 0 && size+len(pending[n]) > s.cfg.MaxBytes {
+				break
+			}
+			size += len(pending[n])
+			n++
+		}
+		batch := pending[:n]
+
+		var err error
+		if !spooling {
+			err = s.sendWithRetry(batch)
+			var retryable *RetryableError
+			spooling = s.spool != nil && errors.As(err, &retryable)
+		}
+		var removed int64
+		if spooling {
+			removed, err = s.spool.push(batch)
+		}
+
+		s.mu.Lock()
+		s.queued -= size
+		if err != nil {
+			s.err = multierr.Append(s.err, fmt.Errorf("dropped batch of %d log entries: %w", n, err))
+		}
+		if removed > 0 {
+			s.err = multierr.Append(s.err, fmt.Errorf("removed %d bytes of spooled log entries beyond MaxSpooled", removed))
+		}
+		s.mu.Unlock()
+		pending = pending[n:]
+	}
+}
+
+// replay sends the spooled batches once each, and reports whether all of them
+// were sent or dropped, i.e. whether the destination is available.
+func (s *batchSink) replay() bool {
+	for !s.spool.empty() {
+		batch, err := s.spool.peek()
+		if err == nil {
+			err = s.send(batch)
+			var retryable *RetryableError
+			if errors.As(err, &retryable) {
+				return false
+			}
+			if perr := s.spool.pop(); perr != nil {
+				err = multierr.Append(err, perr)
+			}
+			if err != nil {
+				err = fmt.Errorf("dropped spooled batch of %d log entries: %w", len(batch), err)
+			}
+		}
+		if err != nil {
+			s.mu.Lock()
+			s.err = multierr.Append(s.err, err)
+			s.mu.Unlock()
+		}
+	}
+	return true
+}
+
+// sendWithRetry sends a batch, retrying it as long as send returns a
+// RetryableError and the sink is not stopped.
+func (s *batchSink) sendWithRetry(batch [][]byte) error {
+	backoff := s.cfg.RetryBackoff
+	for retries := 0; ; retries++ {
+		err := s.send(batch)
+		var retryable *RetryableError
+		if err == nil || !errors.As(err, &retryable) || retries == s.cfg.MaxRetries {
+			return err
+		}
+
+		delay := backoff
+		if retryable.After > 0 {
+			delay = retryable.After
+		}
+		backoff *= 2
+		timer := time.NewTimer(delay)
+		select {
+		case <-timer.C:
+		case <-s.stop:
+			timer.Stop()
+			return err
+		}
+	}
+}
@@ -0,0 +1,160 @@
+package log
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+type recordingSender struct {
+	mu       sync.Mutex
+	batches  [][]string
+	failures int // number of sends to fail with a RetryableError
+}
+
+func (r *recordingSender) send(entries [][]byte) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.failures > 0 {
+		r.failures--
+		return &RetryableError{Err: errors.New("throttled"), After: time.Millisecond}
+	}
+	batch := make([]string, len(entries))
+	for i, e := range entries {
+		batch[i] = string(e)
+	}
+	r.batches = append(r.batches, batch)
+	return nil
+}
+
+func TestBatchSink(t *testing.T) {
+	r := &recordingSender{failures: 2}
+	s := NewBatchSink(r.send, BatchConfig{MaxEntries: 2, FlushInterval: time.Hour})
+	defer s.Close()
+
+	for _, e := range []string{"a\n", "b\n", "c\n"} {
+		if _, err := s.Write([]byte(e)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := s.Sync(); err != nil {
+		t.Fatal(err)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var got []string
+	for _, b := range r.batches {
+		got = append(got, strings.Join(b, ","))
+	}
+	if strings.Join(got, " ") != "a,b c" {
+		t.Errorf("got batches %q", got)
+	}
+}
+
+func TestBatchSinkDrops(t *testing.T) {
+	send := func([][]byte) error { return errors.New("rejected") }
+	s := NewBatchSink(send, BatchConfig{MaxQueued: 4, FlushInterval: time.Hour})
+
+	if _, err := s.Write([]byte("abc\n")); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Write([]byte("de\n")); err != errBatchQueueFull {
+		t.Errorf("got %v, want a full queue", err)
+	}
+	if err := s.Sync(); err == nil || !strings.Contains(err.Error(), "rejected") {
+		t.Errorf("got sync error %v", err)
+	}
+	if err := s.Sync(); err != nil {
+		t.Errorf("got error %v after it was reported", err)
+	}
+
+	s.Close()
+	if _, err := s.Write([]byte("f\n")); err != errBatchSinkClosed {
+		t.Errorf("got %v after close", err)
+	}
+}
+
+func TestBatchSinkSpool(t *testing.T) {
+	dir, err := ioutil.TempDir("", "batch-spool")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	r := &recordingSender{failures: 1 << 30}
+	cfg := BatchConfig{MaxEntries: 2, FlushInterval: time.Hour, MaxRetries: 1, SpoolDir: dir}
+	s := NewBatchSink(r.send, cfg)
+	for _, e := range []string{"a\n", "b\n", "c\n"} {
+		if _, err := s.Write([]byte(e)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := s.Sync(); err != nil {
+		t.Fatalf("got %v, want the batches to be spooled", err)
+	}
+	if err := s.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// a new sink on the same directory sends the spooled batches first
+	r.failures = 0
+	s = NewBatchSink(r.send, cfg)
+	defer s.Close()
+	if _, err := s.Write([]byte("d\n")); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Sync(); err != nil {
+		t.Fatal(err)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var got []string
+	for _, b := range r.batches {
+		got = append(got, strings.Join(b, ","))
+	}
+	if strings.Join(got, " ") != "a,b c d" {
+		t.Errorf("got batches %q", got)
+	}
+	if files, _ := ioutil.ReadDir(dir); len(files) != 0 {
+		t.Errorf("got %d files left in the spool", len(files))
+	}
+}
+
+func TestBatchSinkSpoolLimit(t *testing.T) {
+	dir, err := ioutil.TempDir("", "batch-spool")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	r := &recordingSender{failures: 1 << 30}
+	s := NewBatchSink(r.send, BatchConfig{MaxEntries: 1, FlushInterval: time.Hour, MaxRetries: 1, SpoolDir: dir, MaxSpooled: 8})
+	defer s.Close()
+	for _, e := range []string{"aaaa\n", "bbbb\n"} {
+		if _, err := s.Write([]byte(e)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := s.Sync(); err == nil || !strings.Contains(err.Error(), "removed 5 bytes") {
+		t.Errorf("got %v, want the oldest batch to be removed", err)
+	}
+
+	r.mu.Lock()
+	r.failures = 0
+	r.mu.Unlock()
+	if err := s.Sync(); err != nil {
+		t.Fatal(err)
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if len(r.batches) != 1 || r.batches[0][0] != "bbbb" {
+		t.Errorf("got batches %q", r.batches)
+	}
+}
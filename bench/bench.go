@@ -0,0 +1,215 @@
+// Package bench provides realistic logging workloads for benchmarking go-log,
+// and encoders or sinks built to plug into it.
+//
+// Downstream packages can benchmark their plugins against the same workloads
+// go-log is measured with:
+//
+//	func BenchmarkMyEncoder(b *testing.B) {
+//		bench.RunAll(b, func(ws zapcore.WriteSyncer) zapcore.Core {
+//			return zapcore.NewCore(myEncoder, ws, zapcore.DebugLevel)
+//		})
+//	}
+//
+// See README.md for baseline numbers of the built-in formats.
+package bench
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/url"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	log "github.com/ipfs/go-log/v2"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// subsystem is the name of the logger used by the workloads.
+const subsystem = "bench"
+
+// Workload describes how entries are logged during a benchmark.
+type Workload struct {
+	Name string
+
+	// Goroutines is the number of goroutines logging concurrently.
+	Goroutines int
+
+	// SubsystemLevel is the level of the logger. Entries below it are
+	// filtered, as they would be in production.
+	SubsystemLevel log.LogLevel
+
+	// Levels are the levels of the logged entries, used round-robin.
+	Levels []log.LogLevel
+
+	// Fields returns the key-value pairs of the i-th entry.
+	Fields func(i int) []interface{}
+}
+
+var largeValue = strings.Repeat("QmYwAPJzv5CZsnA625s3Xf2nemtYgPpHdWEz79ojWnPbdG", 22) // ~1KiB
+
+var nestedValue = func() map[string]interface{} {
+	m := make(map[string]interface{})
+	for i := 0; i < 10; i++ {
+		m[fmt.Sprint("key", i)] = map[string]interface{}{"peer": "QmYwAPJzv5CZsnA625s3Xf2nemtYgPpHdWEz79ojWnPbdG", "n": i}
+	}
+	return m
+}()
+
+// Workloads are the standard workloads.
+var Workloads = []Workload{
+	{
+		Name:           "single/info",
+		Goroutines:     1,
+		SubsystemLevel: log.LevelInfo,
+		Levels:         []log.LogLevel{log.LevelInfo},
+		Fields: func(i int) []interface{} {
+			return []interface{}{"peer", "QmYwAPJzv5CZsnA625s3Xf2nemtYgPpHdWEz79ojWnPbdG", "attempt", i}
+		},
+	},
+	{
+		Name:           "single/disabled",
+		Goroutines:     1,
+		SubsystemLevel: log.LevelError,
+		Levels:         []log.LogLevel{log.LevelDebug},
+		Fields: func(i int) []interface{} {
+			return []interface{}{"attempt", i}
+		},
+	},
+	{
+		Name:           "parallel/mixed",
+		Goroutines:     16,
+		SubsystemLevel: log.LevelInfo,
+		Levels:         []log.LogLevel{log.LevelDebug, log.LevelInfo, log.LevelInfo, log.LevelWarn, log.LevelError},
+		Fields: func(i int) []interface{} {
+			return []interface{}{"peer", "QmYwAPJzv5CZsnA625s3Xf2nemtYgPpHdWEz79ojWnPbdG", "attempt", i, "proto", "/ipfs/bitswap/1.2.0"}
+		},
+	},
+	{
+		Name:           "parallel/large-fields",
+		Goroutines:     16,
+		SubsystemLevel: log.LevelInfo,
+		Levels:         []log.LogLevel{log.LevelInfo},
+		Fields: func(i int) []interface{} {
+			return []interface{}{"payload", largeValue, "nested", nestedValue, "attempt", i}
+		},
+	},
+}
+
+// Run runs the workload with core as the primary logging core. The logging
+// configuration is reset with SetupLogging when it is done.
+func Run(b *testing.B, w Workload, core zapcore.Core) {
+	cfg := log.GetConfig()
+	defer log.SetupLogging(cfg)
+
+	log.SetPrimaryCore(core)
+	run(b, w)
+}
+
+// run runs the workload against the current logging configuration.
+func run(b *testing.B, w Workload) {
+	logger := log.Logger(subsystem)
+	if err := log.SetLogLevel(subsystem, zapcore.Level(w.SubsystemLevel).String()); err != nil {
+		b.Fatal(err)
+	}
+
+	goroutines := w.Goroutines
+	if goroutines < 1 {
+		goroutines = 1
+	}
+
+	var next int64
+	var wg sync.WaitGroup
+	wg.Add(goroutines)
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for g := 0; g < goroutines; g++ {
+		go func() {
+			defer wg.Done()
+			for {
+				i := int(atomic.AddInt64(&next, 1) - 1)
+				if i >= b.N {
+					return
+				}
+				logAt(logger, w.Levels[i%len(w.Levels)], "benchmark entry", w.Fields(i))
+			}
+		}()
+	}
+	wg.Wait()
+	b.StopTimer()
+}
+
+// RunAll runs all standard workloads as sub-benchmarks, using cores built by
+// newCore on top of a discarding output.
+func RunAll(b *testing.B, newCore func(ws zapcore.WriteSyncer) zapcore.Core) {
+	for _, w := range Workloads {
+		w := w
+		b.Run(w.Name, func(b *testing.B) {
+			Run(b, w, newCore(zapcore.AddSync(ioutil.Discard)))
+		})
+	}
+}
+
+// RunSink runs all standard workloads as sub-benchmarks, writing JSON encoded
+// entries to the sinks returned by newSink.
+func RunSink(b *testing.B, newSink func() zapcore.WriteSyncer) {
+	for _, w := range Workloads {
+		w := w
+		b.Run(w.Name, func(b *testing.B) {
+			enc := zapcore.NewJSONEncoder(zap.NewProductionEncoderConfig())
+			Run(b, w, zapcore.NewCore(enc, newSink(), zapcore.DebugLevel))
+		})
+	}
+}
+
+// discardScheme is the scheme of a zap sink discarding everything, used to
+// benchmark go-log's own outputs.
+const discardScheme = "golog-bench-discard"
+
+var registerOnce sync.Once
+
+// RunFormat runs all standard workloads as sub-benchmarks through go-log's own
+// output pipeline for the given format, discarding the output.
+func RunFormat(b *testing.B, format log.LogFormat) {
+	registerOnce.Do(func() {
+		err := zap.RegisterSink(discardScheme, func(*url.URL) (zap.Sink, error) {
+			return nopCloser{zapcore.AddSync(ioutil.Discard)}, nil
+		})
+		if err != nil {
+			b.Fatal(err)
+		}
+	})
+
+	for _, w := range Workloads {
+		w := w
+		b.Run(w.Name, func(b *testing.B) {
+			cfg := log.GetConfig()
+			defer log.SetupLogging(cfg)
+
+			log.SetupLogging(log.Config{Format: format, URL: discardScheme + "://"})
+			run(b, w)
+		})
+	}
+}
+
+type nopCloser struct {
+	zapcore.WriteSyncer
+}
+
+func (nopCloser) Close() error { return nil }
+
+func logAt(l *log.ZapEventLogger, lvl log.LogLevel, msg string, kv []interface{}) {
+	switch lvl {
+	case log.LevelDebug:
+		l.Debugw(msg, kv...)
+	case log.LevelInfo:
+		l.Infow(msg, kv...)
+	case log.LevelWarn:
+		l.Warnw(msg, kv...)
+	default:
+		l.Errorw(msg, kv...)
+	}
+}
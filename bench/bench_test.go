@@ -0,0 +1,25 @@
+package bench
+
+import (
+	"testing"
+
+	log "github.com/ipfs/go-log/v2"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+func BenchmarkJSON(b *testing.B) {
+	RunFormat(b, log.JSONOutput)
+}
+
+func BenchmarkPlaintext(b *testing.B) {
+	RunFormat(b, log.PlaintextOutput)
+}
+
+// BenchmarkZapJSONCore measures a plain zap JSON core, as a downstream
+// encoder plugin would be measured.
+func BenchmarkZapJSONCore(b *testing.B) {
+	RunAll(b, func(ws zapcore.WriteSyncer) zapcore.Core {
+		return zapcore.NewCore(zapcore.NewJSONEncoder(zap.NewProductionEncoderConfig()), ws, zapcore.DebugLevel)
+	})
+}
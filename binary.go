@@ -0,0 +1,169 @@
+package log
+
+import (
+	"encoding/base32"
+	"encoding/hex"
+	"fmt"
+	"reflect"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// BinaryEncoding is how the values of byte slice fields are rendered, see
+// Config.BinaryEncoding.
+type BinaryEncoding int
+
+const (
+	// Base64Binary renders byte slices in standard base64 with padding, as
+	// zap does.
+	Base64Binary BinaryEncoding = iota
+	// HexBinary renders byte slices in lowercase hex.
+	HexBinary
+	// MultibaseBinary renders byte slices as multibase strings in lowercase
+	// base32 without padding, prefixed with "b", as CIDv1 are by default.
+	MultibaseBinary
+)
+
+// binaryEncoders render byte slices in a BinaryEncoding other than
+// Base64Binary.
+var binaryEncoders = map[BinaryEncoding]func([]byte) string{
+	HexBinary: hex.EncodeToString,
+	MultibaseBinary: func(b []byte) string {
+		return "b" + multibaseBase32.EncodeToString(b)
+	},
+}
+
+var multibaseBase32 = base32.NewEncoding("abcdefghijklmnopqrstuvwxyz234567").WithPadding(base32.NoPadding)
+
+// multihash is a multihash rendered in base58btc.
+type multihash []byte
+
+func (m multihash) String() string {
+	return base58Encode(m)
+}
+
+// Multihash constructs a field with a multihash, or another binary
+// identifier, rendered in base58btc, i.e. "QmYwAPJzv5CZsnA625s3Xf2nemtYgPpHdWEz79ojWnPbdG"
+// as CIDv0 and peer IDs are, regardless of Config.BinaryEncoding.
+func Multihash(key string, mh []byte) zapcore.Field {
+	return zap.Stringer(key, multihash(mh))
+}
+
+const base58Alphabet = "123456789ABCDEFGHJKLMNPQRSTUVWXYZabcdefghijkmnopqrstuvwxyz"
+
+// base58Encode encodes b in base58 with the bitcoin alphabet. Leading zero
+// bytes are encoded as "1" each.
+func base58Encode(b []byte) string {
+	zeros := 0
+	for zeros < len(b) && b[zeros] == 0 {
+		zeros++
+	}
+	// digits in base 58, most significant first. A byte takes at most
+	// log(256)/log(58) < 1.38 digits.
+	digits := make([]byte, (len(b)-zeros)*138/100+1)
+	high := len(digits) - 1
+	for _, c := range b[zeros:] {
+		carry := int(c)
+		j := len(digits) - 1
+		for ; j > high || carry != 0; j-- {
+			carry += 256 * int(digits[j])
+			digits[j] = byte(carry % 58)
+			carry /= 58
+		}
+		high = j
+	}
+	start := 0
+	for start < len(digits) && digits[start] == 0 {
+		start++
+	}
+
+	s := make([]byte, zeros+len(digits)-start)
+	for i := 0; i < zeros; i++ {
+		s[i] = base58Alphabet[0]
+	}
+	for i, d := range digits[start:] {
+		s[zeros+i] = base58Alphabet[d]
+	}
+	return string(s)
+}
+
+var _ zapcore.Core = (*binaryCore)(nil)
+
+// binaryCore renders the values of byte slice fields, i.e. of zap.Binary or
+// of named byte slice types without a String method, in a BinaryEncoding.
+// Fields added with zap.ByteString hold text and are not changed.
+type binaryCore struct {
+	next   zapcore.Core
+	encode func([]byte) string
+}
+
+func newBinaryCore(next zapcore.Core, encoding BinaryEncoding) zapcore.Core {
+	encode, ok := binaryEncoders[encoding]
+	if !ok {
+		return next
+	}
+	return &binaryCore{next: next, encode: encode}
+}
+
+func (c *binaryCore) With(fields []zapcore.Field) zapcore.Core {
+	return &binaryCore{next: c.next.With(c.render(fields)), encode: c.encode}
+}
+
+func (c *binaryCore) Enabled(lvl zapcore.Level) bool {
+	return c.next.Enabled(lvl)
+}
+
+func (c *binaryCore) Check(ent zapcore.Entry, ce *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if c.next.Enabled(ent.Level) {
+		return ce.AddCore(ent, c)
+	}
+	return ce
+}
+
+func (c *binaryCore) Write(ent zapcore.Entry, fields []zapcore.Field) error {
+	return writeChecked(c.next, ent, c.render(fields))
+}
+
+func (c *binaryCore) Sync() error {
+	return c.next.Sync()
+}
+
+// render returns fields with byte slices rendered as strings. fields is not
+// modified.
+func (c *binaryCore) render(fields []zapcore.Field) []zapcore.Field {
+	var rendered []zapcore.Field
+	for i, f := range fields {
+		b, ok := byteSlice(f)
+		if !ok {
+			continue
+		}
+		if rendered == nil {
+			rendered = append([]zapcore.Field(nil), fields...)
+		}
+		rendered[i] = zap.String(f.Key, c.encode(b))
+	}
+	if rendered == nil {
+		return fields
+	}
+	return rendered
+}
+
+// byteSlice returns the value of a field holding a byte slice.
+func byteSlice(f zapcore.Field) ([]byte, bool) {
+	switch f.Type {
+	case zapcore.BinaryType:
+		b, ok := f.Interface.([]byte)
+		return b, ok
+	case zapcore.ReflectType:
+		if _, ok := f.Interface.(fmt.Stringer); ok {
+			return nil, false
+		}
+		v := reflect.ValueOf(f.Interface)
+		if v.Kind() != reflect.Slice || v.Type().Elem().Kind() != reflect.Uint8 {
+			return nil, false
+		}
+		return v.Bytes(), true
+	}
+	return nil, false
+}
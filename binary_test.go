@@ -0,0 +1,81 @@
+package log
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+type testDigest []byte
+
+func TestBinaryEncoding(t *testing.T) {
+	for _, tc := range []struct {
+		encoding BinaryEncoding
+		want     string
+	}{
+		{Base64Binary, "AQL/"},
+		{HexBinary, "0102ff"},
+		{MultibaseBinary, "baebp6"},
+	} {
+		buf := &bytes.Buffer{}
+		core := newBinaryCore(newCore(JSONOutput, zapcore.AddSync(buf), LevelDebug, nil), tc.encoding)
+		logger := zap.New(core).With(zap.Binary("with", []byte{1, 2, 0xff}))
+		logger.Info("binary",
+			zap.Binary("raw", []byte{1, 2, 0xff}),
+			zap.Any("digest", testDigest{1, 2, 0xff}),
+			zap.ByteString("text", []byte("plain")),
+		)
+
+		var entry map[string]interface{}
+		if err := json.Unmarshal(buf.Bytes(), &entry); err != nil {
+			t.Fatal(err)
+		}
+		for _, key := range []string{"with", "raw", "digest"} {
+			if entry[key] != tc.want {
+				t.Errorf("encoding %d: got %s %v, want %q", tc.encoding, key, entry[key], tc.want)
+			}
+		}
+		if entry["text"] != "plain" {
+			t.Errorf("encoding %d: got text %v", tc.encoding, entry["text"])
+		}
+	}
+}
+
+func TestMultihash(t *testing.T) {
+	buf := &bytes.Buffer{}
+	core := newBinaryCore(newCore(PlaintextOutput, zapcore.AddSync(buf), LevelDebug, nil), HexBinary)
+	zap.New(core).Info("multihash", Multihash("mh", []byte("Hello World!")))
+
+	if !strings.Contains(buf.String(), `"mh": "2NEpo7TZRRrLZSi2U"`) {
+		t.Errorf("got %q", buf.String())
+	}
+}
+
+func TestBase58Encode(t *testing.T) {
+	for _, tc := range []struct {
+		in   []byte
+		want string
+	}{
+		{nil, ""},
+		{[]byte{0}, "1"},
+		{[]byte{0, 0, 1}, "112"},
+		{[]byte{57}, "z"},
+		{[]byte{58}, "21"},
+		{[]byte("Hello World!"), "2NEpo7TZRRrLZSi2U"},
+	} {
+		if got := base58Encode(tc.in); got != tc.want {
+			t.Errorf("base58Encode(%x) = %q, want %q", tc.in, got, tc.want)
+		}
+	}
+}
+
+func TestBinaryCoreWriteError(t *testing.T) {
+	core := newBinaryCore(newCore(JSONOutput, failingWriteSyncer{}, LevelDebug, nil), HexBinary)
+	if err := core.Write(zapcore.Entry{Level: zapcore.InfoLevel}, nil); err == nil {
+		t.Error("expected the write error of the output")
+	}
+}
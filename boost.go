@@ -0,0 +1,131 @@
+package log
+
+import (
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// levelBoost tracks the active boosts of a subsystem.
+type levelBoost struct {
+	base    zapcore.Level          // level to revert to
+	applied zapcore.Level          // level last set by a boost
+	active  map[*int]zapcore.Level // boost levels by token
+	pending bool                   // the level was added for a subsystem not created yet
+}
+
+var boostMu sync.Mutex // guards boosts
+var boosts = make(map[string]*levelBoost)
+
+// BoostLevel temporarily lowers the minimum level of a subsystem to level for
+// the given duration, e.g. to capture a window of debug logs. The returned
+// function ends the boost early. Overlapping boosts of a subsystem are
+// combined, using the most verbose level of all active boosts.
+//
+// When a boost ends, the level the subsystem had before is restored, unless
+// the level was changed in the meantime, e.g. by SetLogLevel.
+//
+// As with SetLogLevel, a subsystem that was not created yet is boosted once
+// it is created, unless Config.StrictSubsystemLevels is set.
+func BoostLevel(name, level string, d time.Duration) (cancel func(), err error) {
+	lvl, err := LevelFromString(level)
+	if err != nil {
+		return nil, err
+	}
+
+	loggerMutex.Lock()
+	leveler, ok := levels[name]
+	pending := !ok
+	if pending {
+		if config.StrictSubsystemLevels {
+			loggerMutex.Unlock()
+			return nil, ErrNoSuchLogger
+		}
+		leveler = zap.NewAtomicLevelAt(zapcore.Level(defaultLevel))
+		levels[name] = leveler
+	}
+	loggerMutex.Unlock()
+
+	if pending {
+		fmt.Fprintf(os.Stderr, "log level %s boosted for unknown subsystem %q, applying it once the subsystem is created\n",
+			zapcore.Level(lvl), name)
+	}
+
+	boostMu.Lock()
+	b, ok := boosts[name]
+	if !ok || leveler.Level() != b.applied {
+		// no boost is active, or the level was changed since a boost was
+		// applied.
+		b = &levelBoost{
+			base:    leveler.Level(),
+			active:  make(map[*int]zapcore.Level),
+			pending: pending,
+		}
+		boosts[name] = b
+	}
+	token := new(int)
+	b.active[token] = zapcore.Level(lvl)
+	b.apply(leveler)
+	boostMu.Unlock()
+
+	var once sync.Once
+	end := func() {
+		once.Do(func() {
+			boostMu.Lock()
+			defer boostMu.Unlock()
+
+			delete(b.active, token)
+			if boosts[name] != b {
+				// superseded by a later boost.
+				return
+			}
+			if leveler.Level() != b.applied {
+				// the level was changed by someone else, leave it alone.
+				delete(boosts, name)
+				return
+			}
+			if len(b.active) == 0 {
+				leveler.SetLevel(b.base)
+				delete(boosts, name)
+				if b.pending {
+					b.dropPending(name, leveler)
+				}
+				return
+			}
+			b.apply(leveler)
+		})
+	}
+	timer := time.AfterFunc(d, end)
+
+	return func() {
+		timer.Stop()
+		end()
+	}, nil
+}
+
+// dropPending removes the level added by a boost for a subsystem that was not
+// created yet, so that it gets the default level again. boostMu must be held.
+func (b *levelBoost) dropPending(name string, leveler zap.AtomicLevel) {
+	loggerMutex.Lock()
+	defer loggerMutex.Unlock()
+	if _, ok := loggers[name]; !ok && levels[name] == leveler {
+		delete(levels, name)
+	}
+}
+
+// apply sets the most verbose of the base and boost levels. boostMu must be
+// held.
+func (b *levelBoost) apply(leveler zap.AtomicLevel) {
+	lvl := b.base
+	for _, l := range b.active {
+		if l < lvl {
+			lvl = l
+		}
+	}
+	b.applied = lvl
+	leveler.SetLevel(lvl)
+}
@@ -0,0 +1,122 @@
+package log
+
+import (
+	"testing"
+	"time"
+
+	"go.uber.org/zap/zapcore"
+)
+
+func TestBoostLevel(t *testing.T) {
+	const subsystem = "boost-test"
+	getLogger(subsystem)
+	if err := SetLogLevel(subsystem, "error"); err != nil {
+		t.Fatal(err)
+	}
+
+	cancelInfo, err := BoostLevel(subsystem, "info", time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cancelDebug, err := BoostLevel(subsystem, "debug", time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if lvl := subsystemLevel(subsystem); lvl != zapcore.DebugLevel {
+		t.Errorf("got level %s, want debug", lvl)
+	}
+
+	cancelDebug()
+	if lvl := subsystemLevel(subsystem); lvl != zapcore.InfoLevel {
+		t.Errorf("got level %s, want info", lvl)
+	}
+
+	cancelInfo()
+	cancelInfo()
+	if lvl := subsystemLevel(subsystem); lvl != zapcore.ErrorLevel {
+		t.Errorf("got level %s, want error", lvl)
+	}
+}
+
+func TestBoostLevelExpires(t *testing.T) {
+	const subsystem = "boost-expire-test"
+	getLogger(subsystem)
+	if err := SetLogLevel(subsystem, "error"); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := BoostLevel(subsystem, "debug", 10*time.Millisecond); err != nil {
+		t.Fatal(err)
+	}
+	deadline := time.Now().Add(time.Second)
+	for subsystemLevel(subsystem) != zapcore.ErrorLevel {
+		if time.Now().After(deadline) {
+			t.Fatal("boost did not expire")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestBoostLevelExternalChange(t *testing.T) {
+	const subsystem = "boost-change-test"
+	getLogger(subsystem)
+	if err := SetLogLevel(subsystem, "error"); err != nil {
+		t.Fatal(err)
+	}
+
+	cancel, err := BoostLevel(subsystem, "debug", time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := SetLogLevel(subsystem, "warn"); err != nil {
+		t.Fatal(err)
+	}
+	cancel()
+	if lvl := subsystemLevel(subsystem); lvl != zapcore.WarnLevel {
+		t.Errorf("got level %s, want warn", lvl)
+	}
+}
+
+func TestBoostLevelPendingSubsystem(t *testing.T) {
+	SetupLogging(Config{Level: LevelError})
+	defer SetupLogging(Config{Stderr: true})
+
+	const subsystem = "boost-pending-test"
+	loggerMutex.Lock()
+	for _, name := range []string{subsystem, "boost-pending-ended-test"} {
+		delete(loggers, name)
+		delete(levels, name)
+	}
+	loggerMutex.Unlock()
+
+	cancel, err := BoostLevel(subsystem, "debug", time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	getLogger(subsystem)
+	if lvl := subsystemLevel(subsystem); lvl != zapcore.DebugLevel {
+		t.Errorf("got level %s once created, want debug", lvl)
+	}
+	cancel()
+	if lvl := subsystemLevel(subsystem); lvl != zapcore.ErrorLevel {
+		t.Errorf("got level %s, want error", lvl)
+	}
+
+	// a boost that ends before the subsystem is created leaves it the
+	// default level.
+	cancel, err = BoostLevel("boost-pending-ended-test", "debug", time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cancel()
+	SetDefaultLogLevel(LevelWarn)
+	getLogger("boost-pending-ended-test")
+	if lvl := subsystemLevel("boost-pending-ended-test"); lvl != zapcore.WarnLevel {
+		t.Errorf("got level %s, want warn", lvl)
+	}
+
+	SetupLogging(Config{Level: LevelError, StrictSubsystemLevels: true})
+	if _, err := BoostLevel("boost-no-such-logger", "debug", time.Hour); err != ErrNoSuchLogger {
+		t.Errorf("got error %v, want %v", err, ErrNoSuchLogger)
+	}
+}
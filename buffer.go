@@ -0,0 +1,182 @@
+package log
+
+import (
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"go.uber.org/zap/zapcore"
+)
+
+// SyncPolicy controls when buffered output is synced to stable storage.
+type SyncPolicy int
+
+const (
+	// SyncExplicit syncs only when Sync is called on a logger.
+	SyncExplicit SyncPolicy = iota
+	// SyncOnFlush syncs after every batch written to the underlying output.
+	SyncOnFlush
+	// SyncNever never syncs and leaves write-back entirely to the OS.
+	SyncNever
+)
+
+// defaultFlushInterval is used when BufferConfig.FlushInterval is unset.
+const defaultFlushInterval = time.Second
+
+// BufferConfig configures write coalescing for an output. Entries are
+// collected in memory and written with a single write call once Size bytes
+// are buffered or FlushInterval has passed, whichever comes first.
+type BufferConfig struct {
+	// Size is the maximum number of bytes to buffer. Buffering is disabled
+	// when Size is zero.
+	Size int
+
+	// FlushInterval bounds how long an entry may stay buffered. Defaults to
+	// one second.
+	FlushInterval time.Duration
+
+	// Sync is the fsync policy. Defaults to SyncExplicit.
+	Sync SyncPolicy
+}
+
+var _ zapcore.WriteSyncer = (*bufferedWriteSyncer)(nil)
+
+// bufferedWriteSyncer coalesces small writes into larger ones.
+type bufferedWriteSyncer struct {
+	mu     sync.Mutex // guards buf and writes to ws
+	ws     zapcore.WriteSyncer
+	buf    []byte
+	size   int
+	policy SyncPolicy
+
+	// onError is called with the errors of background flushes. They are
+	// printed to stderr when it is nil.
+	onError func(error)
+
+	stop     chan struct{}
+	done     chan struct{}
+	stopOnce sync.Once
+	stopErr  error
+}
+
+// newBufferedWriteSyncer returns a buffer in front of ws, reporting the errors
+// of background flushes to onError.
+func newBufferedWriteSyncer(ws zapcore.WriteSyncer, cfg BufferConfig, onError func(error)) *bufferedWriteSyncer {
+	interval := cfg.FlushInterval
+	if interval <= 0 {
+		interval = defaultFlushInterval
+	}
+
+	b := &bufferedWriteSyncer{
+		ws:      ws,
+		buf:     make([]byte, 0, cfg.Size),
+		size:    cfg.Size,
+		policy:  cfg.Sync,
+		onError: onError,
+		stop:    make(chan struct{}),
+		done:    make(chan struct{}),
+	}
+	go b.flushLoop(interval)
+	return b
+}
+
+func (b *bufferedWriteSyncer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if len(b.buf)+len(p) > b.size {
+		if err := b.flush(); err != nil {
+			return 0, err
+		}
+	}
+	// Entries that don't fit into an empty buffer are written straight
+	// through.
+	if len(p) > b.size {
+		return b.ws.Write(p)
+	}
+	b.buf = append(b.buf, p...)
+	return len(p), nil
+}
+
+// Sync writes out any buffered data and syncs the underlying output under the
+// SyncExplicit policy.
+func (b *bufferedWriteSyncer) Sync() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if err := b.flush(); err != nil {
+		return err
+	}
+	// SyncOnFlush has already synced in flush, SyncNever never does.
+	if b.policy != SyncExplicit {
+		return nil
+	}
+	return b.ws.Sync()
+}
+
+// Stop flushes the buffer and stops the background flush loop. Calls after
+// the first return the result of the first.
+func (b *bufferedWriteSyncer) Stop() error {
+	b.stopOnce.Do(func() {
+		close(b.stop)
+		<-b.done
+
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		b.stopErr = b.flush()
+	})
+	return b.stopErr
+}
+
+func (b *bufferedWriteSyncer) queued() int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return len(b.buf)
+}
+
+// flush writes out the buffer. b.mu must be held.
+func (b *bufferedWriteSyncer) flush() error {
+	if len(b.buf) == 0 {
+		return nil
+	}
+	_, err := b.ws.Write(b.buf)
+	b.buf = b.buf[:0]
+	if err != nil {
+		return err
+	}
+	if b.policy == SyncOnFlush {
+		return b.ws.Sync()
+	}
+	return nil
+}
+
+func (b *bufferedWriteSyncer) flushLoop(interval time.Duration) {
+	defer close(b.done)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			b.mu.Lock()
+			err := b.flush()
+			b.mu.Unlock()
+			if err != nil {
+				b.flushFailed(err)
+			}
+		case <-b.stop:
+			return
+		}
+	}
+}
+
+// flushFailed reports the error of a background flush.
+func (b *bufferedWriteSyncer) flushFailed(err error) {
+	if b.onError != nil {
+		b.onError(err)
+		return
+	}
+	fmt.Fprintf(os.Stderr, "failed to flush buffered log output: %s\n", err)
+}
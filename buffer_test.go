@@ -0,0 +1,154 @@
+package log
+
+import (
+	"bytes"
+	"testing"
+	"time"
+)
+
+type countingWriteSyncer struct {
+	bytes.Buffer
+	writes int
+	syncs  int
+}
+
+func (c *countingWriteSyncer) Write(p []byte) (int, error) {
+	c.writes++
+	return c.Buffer.Write(p)
+}
+
+func (c *countingWriteSyncer) Sync() error {
+	c.syncs++
+	return nil
+}
+
+func TestBufferedWriteSyncerCoalesces(t *testing.T) {
+	ws := &countingWriteSyncer{}
+	b := newBufferedWriteSyncer(ws, BufferConfig{Size: 16, FlushInterval: time.Hour}, nil)
+	defer b.Stop()
+
+	for i := 0; i < 3; i++ {
+		if _, err := b.Write([]byte("scooby\n")); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// the third write doesn't fit and flushes the first two in one go.
+	if ws.writes != 1 {
+		t.Errorf("got %d writes, want 1", ws.writes)
+	}
+	if got, want := ws.String(), "scooby\nscooby\n"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+
+	if err := b.Sync(); err != nil {
+		t.Fatal(err)
+	}
+	if ws.writes != 2 || ws.syncs != 1 {
+		t.Errorf("got %d writes and %d syncs, want 2 and 1", ws.writes, ws.syncs)
+	}
+}
+
+func TestBufferedWriteSyncerLargeWrite(t *testing.T) {
+	ws := &countingWriteSyncer{}
+	b := newBufferedWriteSyncer(ws, BufferConfig{Size: 4, FlushInterval: time.Hour}, nil)
+	defer b.Stop()
+
+	if _, err := b.Write([]byte("scooby\n")); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := ws.String(), "scooby\n"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+func TestBufferedWriteSyncerSyncPolicy(t *testing.T) {
+	testCases := []struct {
+		policy SyncPolicy
+		syncs  int
+	}{
+		{policy: SyncExplicit, syncs: 1},
+		{policy: SyncOnFlush, syncs: 1},
+		{policy: SyncNever, syncs: 0},
+	}
+
+	for _, tc := range testCases {
+		ws := &countingWriteSyncer{}
+		b := newBufferedWriteSyncer(ws, BufferConfig{Size: 64, FlushInterval: time.Hour, Sync: tc.policy}, nil)
+
+		if _, err := b.Write([]byte("scooby\n")); err != nil {
+			t.Fatal(err)
+		}
+		if err := b.Sync(); err != nil {
+			t.Fatal(err)
+		}
+		if ws.syncs != tc.syncs {
+			t.Errorf("policy %d: got %d syncs, want %d", tc.policy, ws.syncs, tc.syncs)
+		}
+		b.Stop()
+	}
+}
+
+func TestBufferedWriteSyncerFlushInterval(t *testing.T) {
+	ws := &countingWriteSyncer{}
+	b := newBufferedWriteSyncer(ws, BufferConfig{Size: 64, FlushInterval: 10 * time.Millisecond}, nil)
+
+	if _, err := b.Write([]byte("scooby\n")); err != nil {
+		t.Fatal(err)
+	}
+
+	deadline := time.Now().Add(time.Second)
+	for {
+		b.mu.Lock()
+		flushed := ws.Len() > 0
+		b.mu.Unlock()
+		if flushed {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("buffer was not flushed within the flush interval")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	b.Stop()
+}
+
+func TestBufferedWriteSyncerFlushError(t *testing.T) {
+	defer resetSinkMonitors()
+	resetSinkMonitors()
+
+	// the error happens in front of the monitored output, as for a failing
+	// compressor.
+	m := monitorSink("buffered", &countingWriteSyncer{})
+	b := newBufferedWriteSyncer(failingWriteSyncer{}, BufferConfig{Size: 64, FlushInterval: 10 * time.Millisecond}, m.backgroundError)
+	defer b.Stop()
+
+	if _, err := b.Write([]byte("scooby\n")); err != nil {
+		t.Fatal(err)
+	}
+
+	deadline := time.Now().Add(time.Second)
+	for Health()[0].LastError == nil {
+		if time.Now().After(deadline) {
+			t.Fatal("flush error was not recorded")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestBufferedWriteSyncerStopTwice(t *testing.T) {
+	ws := &countingWriteSyncer{}
+	b := newBufferedWriteSyncer(ws, BufferConfig{Size: 64, FlushInterval: time.Hour}, nil)
+	if _, err := b.Write([]byte("scooby\n")); err != nil {
+		t.Fatal(err)
+	}
+	if err := b.Stop(); err != nil {
+		t.Fatal(err)
+	}
+	if err := b.Stop(); err != nil {
+		t.Fatal(err)
+	}
+	if ws.writes != 1 {
+		t.Errorf("got %d writes, want 1", ws.writes)
+	}
+}
@@ -0,0 +1,89 @@
+package log
+
+import (
+	"runtime"
+	"runtime/debug"
+	"sync"
+	"sync/atomic"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+const (
+	// GoVersionKey is the field key of the Go version the binary was built
+	// with, see Config.BuildInfo.
+	GoVersionKey = "go_version"
+	// ModuleVersionKey is the field key of the version of the main module.
+	ModuleVersionKey = "module_version"
+	// VCSRevisionKey is the field key of the VCS revision the binary was
+	// built from. It is only known to binaries built with Go 1.18 or later.
+	VCSRevisionKey = "vcs_revision"
+)
+
+var buildInfoOnce sync.Once
+var buildInfo []zapcore.Field
+
+// buildInfoFields returns the build info of the binary as fields. Unknown
+// values are left out.
+func buildInfoFields() []zapcore.Field {
+	buildInfoOnce.Do(func() {
+		buildInfo = append(buildInfo, zap.String(GoVersionKey, runtime.Version()))
+		info, ok := debug.ReadBuildInfo()
+		if !ok {
+			return
+		}
+		if v := info.Main.Version; v != "" {
+			buildInfo = append(buildInfo, zap.String(ModuleVersionKey, v))
+		}
+		if rev := vcsRevision(info); rev != "" {
+			buildInfo = append(buildInfo, zap.String(VCSRevisionKey, rev))
+		}
+	})
+	return buildInfo
+}
+
+var _ zapcore.Core = (*buildInfoCore)(nil)
+
+// buildInfoCore adds the build info to the first entry written.
+type buildInfoCore struct {
+	next    zapcore.Core
+	written *uint32 // shared with derived cores, set once an entry is written
+}
+
+func newBuildInfoCore(next zapcore.Core) *buildInfoCore {
+	return &buildInfoCore{next: next, written: new(uint32)}
+}
+
+func (c *buildInfoCore) With(fields []zapcore.Field) zapcore.Core {
+	return &buildInfoCore{next: c.next.With(fields), written: c.written}
+}
+
+func (c *buildInfoCore) Enabled(lvl zapcore.Level) bool {
+	return c.next.Enabled(lvl)
+}
+
+func (c *buildInfoCore) Check(ent zapcore.Entry, ce *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if atomic.LoadUint32(c.written) != 0 {
+		return c.next.Check(ent, ce)
+	}
+	if c.next.Enabled(ent.Level) {
+		return ce.AddCore(ent, c)
+	}
+	return ce
+}
+
+func (c *buildInfoCore) Write(ent zapcore.Entry, fields []zapcore.Field) error {
+	if !c.next.Enabled(ent.Level) {
+		return nil
+	}
+	if atomic.CompareAndSwapUint32(c.written, 0, 1) {
+		info := buildInfoFields()
+		fields = append(info[:len(info):len(info)], fields...)
+	}
+	return writeChecked(c.next, ent, fields)
+}
+
+func (c *buildInfoCore) Sync() error {
+	return c.next.Sync()
+}
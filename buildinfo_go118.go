@@ -0,0 +1,25 @@
+//go:build go1.18
+// +build go1.18
+
+package log
+
+import "runtime/debug"
+
+// vcsRevision returns the VCS revision recorded in info, with a "-dirty"
+// suffix if the working tree had local changes.
+func vcsRevision(info *debug.BuildInfo) string {
+	var rev string
+	var modified bool
+	for _, s := range info.Settings {
+		switch s.Key {
+		case "vcs.revision":
+			rev = s.Value
+		case "vcs.modified":
+			modified = s.Value == "true"
+		}
+	}
+	if rev != "" && modified {
+		rev += "-dirty"
+	}
+	return rev
+}
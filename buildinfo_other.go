@@ -0,0 +1,12 @@
+//go:build !go1.18
+// +build !go1.18
+
+package log
+
+import "runtime/debug"
+
+// vcsRevision returns the VCS revision recorded in info, which requires
+// Go 1.18 or later.
+func vcsRevision(*debug.BuildInfo) string {
+	return ""
+}
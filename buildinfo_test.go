@@ -0,0 +1,40 @@
+package log
+
+import (
+	"bytes"
+	"runtime"
+	"strings"
+	"testing"
+
+	"go.uber.org/zap/zapcore"
+)
+
+func TestBuildInfo(t *testing.T) {
+	SetupLogging(Config{Level: LevelDebug, BuildInfo: true})
+	defer SetupLogging(Config{Stderr: true})
+
+	buf := &bytes.Buffer{}
+	SetPrimaryCore(newBuildInfoCore(newCore(JSONOutput, zapcore.AddSync(buf), LevelDebug, nil)))
+
+	log := getLogger("buildinfo-test")
+	log.Debug("first")
+	log.Debug("second")
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("got %q", buf.String())
+	}
+	if !strings.Contains(lines[0], `"`+GoVersionKey+`":"`+runtime.Version()+`"`) {
+		t.Errorf("first entry has no build info: %s", lines[0])
+	}
+	if strings.Contains(lines[1], GoVersionKey) {
+		t.Errorf("second entry has build info: %s", lines[1])
+	}
+}
+
+func TestBuildInfoCoreWriteError(t *testing.T) {
+	core := newBuildInfoCore(newCore(JSONOutput, failingWriteSyncer{}, LevelDebug, nil))
+	if err := core.Write(zapcore.Entry{Level: zapcore.InfoLevel}, nil); err == nil {
+		t.Error("expected the write error of the output")
+	}
+}
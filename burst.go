@@ -0,0 +1,131 @@
+package log
+
+import (
+	"strings"
+	"sync"
+	"time"
+
+	"go.uber.org/zap/zapcore"
+)
+
+// ErrorBurstConfig configures raising the level of a subsystem to debug when
+// it logs a burst of errors, to capture context around incidents.
+type ErrorBurstConfig struct {
+	// Threshold is the number of errors within Window that triggers a boost.
+	// Disabled when zero.
+	Threshold int
+
+	// Window is the period errors are counted in. Defaults to one minute.
+	Window time.Duration
+
+	// Duration is how long the subsystem logs at debug level. Defaults to
+	// five minutes.
+	Duration time.Duration
+}
+
+var _ zapcore.Core = (*burstCore)(nil)
+
+// burstCore watches the rate of errors per subsystem and boosts the level of
+// subsystems exceeding the threshold, see BoostLevel.
+type burstCore struct {
+	threshold int
+	window    time.Duration
+	duration  time.Duration
+	boost     func(name string, d time.Duration) error
+
+	mu     sync.Mutex
+	counts map[string]*errorCount
+}
+
+type errorCount struct {
+	start        time.Time // start of the current window
+	n            int
+	boostedUntil time.Time
+}
+
+func newBurstCore(cfg ErrorBurstConfig) *burstCore {
+	b := &burstCore{
+		threshold: cfg.Threshold,
+		window:    cfg.Window,
+		duration:  cfg.Duration,
+		counts:    make(map[string]*errorCount),
+		boost: func(name string, d time.Duration) error {
+			_, err := BoostLevel(name, "debug", d)
+			return err
+		},
+	}
+	if b.window <= 0 {
+		b.window = time.Minute
+	}
+	if b.duration <= 0 {
+		b.duration = 5 * time.Minute
+	}
+	return b
+}
+
+func (b *burstCore) With([]zapcore.Field) zapcore.Core {
+	return b
+}
+
+func (b *burstCore) Enabled(lvl zapcore.Level) bool {
+	return lvl >= zapcore.ErrorLevel
+}
+
+func (b *burstCore) Check(ent zapcore.Entry, ce *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if b.Enabled(ent.Level) {
+		return ce.AddCore(ent, b)
+	}
+	return ce
+}
+
+func (b *burstCore) Write(ent zapcore.Entry, _ []zapcore.Field) error {
+	name, ok := subsystemOf(ent.LoggerName)
+	if !ok {
+		// loggers not created through this package can't be boosted.
+		return nil
+	}
+
+	b.mu.Lock()
+	c, found := b.counts[name]
+	if !found {
+		c = &errorCount{}
+		b.counts[name] = c
+	}
+	if ent.Time.Sub(c.start) >= b.window {
+		c.start = ent.Time
+		c.n = 0
+	}
+	c.n++
+	trigger := c.n >= b.threshold && !ent.Time.Before(c.boostedUntil)
+	if trigger {
+		c.boostedUntil = ent.Time.Add(b.duration)
+	}
+	b.mu.Unlock()
+
+	if trigger {
+		_ = b.boost(name, b.duration)
+	}
+	return nil
+}
+
+func (b *burstCore) Sync() error {
+	return nil
+}
+
+// subsystemOf returns the subsystem a logger belongs to. Loggers derived with
+// Named ("bitswap.ledger") share the level of their subsystem.
+func subsystemOf(name string) (string, bool) {
+	loggerMutex.RLock()
+	defer loggerMutex.RUnlock()
+
+	for {
+		if _, ok := levels[name]; ok {
+			return name, true
+		}
+		dot := strings.LastIndexByte(name, '.')
+		if dot < 0 {
+			return "", false
+		}
+		name = name[:dot]
+	}
+}
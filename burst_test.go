@@ -0,0 +1,59 @@
+package log
+
+import (
+	"testing"
+	"time"
+
+	"go.uber.org/zap/zapcore"
+)
+
+func TestBurstCore(t *testing.T) {
+	getLogger("burst-test")
+
+	var boosted []string
+	b := newBurstCore(ErrorBurstConfig{Threshold: 3, Window: time.Minute, Duration: time.Hour})
+	b.boost = func(name string, d time.Duration) error {
+		boosted = append(boosted, name)
+		return nil
+	}
+
+	start := time.Date(2010, 5, 23, 15, 14, 0, 0, time.UTC)
+	write := func(name string, offset time.Duration) {
+		ent := zapcore.Entry{LoggerName: name, Level: zapcore.ErrorLevel, Time: start.Add(offset)}
+		if ce := b.Check(ent, nil); ce != nil {
+			ce.Write()
+		}
+	}
+
+	// two errors per window stay below the threshold.
+	write("burst-test", 0)
+	write("burst-test", 30*time.Second)
+	write("burst-test", 70*time.Second)
+	if len(boosted) != 0 {
+		t.Fatalf("boosted %v below the threshold", boosted)
+	}
+
+	write("burst-test.child", 80*time.Second)
+	write("burst-test", 90*time.Second)
+	// already boosted.
+	write("burst-test", 100*time.Second)
+	write("burst-no-such-logger", 0)
+	if len(boosted) != 1 || boosted[0] != "burst-test" {
+		t.Errorf("got boosts %v, want [burst-test]", boosted)
+	}
+}
+
+func TestErrorBurstBoostsLevel(t *testing.T) {
+	SetupLogging(Config{
+		Level:      LevelError,
+		ErrorBurst: ErrorBurstConfig{Threshold: 2, Duration: time.Hour},
+	})
+	defer SetupLogging(Config{Stderr: true})
+
+	logger := getLogger("burst-setup-test")
+	logger.Error("one")
+	logger.Error("two")
+	if lvl := subsystemLevel("burst-setup-test"); lvl != zapcore.DebugLevel {
+		t.Errorf("got level %s, want debug", lvl)
+	}
+}
@@ -0,0 +1,114 @@
+package log
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// CanonicalDurationKey is the field key of the time from NewCanonicalLine to
+// Emit in a canonical line.
+const CanonicalDurationKey = "duration"
+
+// CanonicalLine accumulates the fields of a request, added by the code
+// handling it, to log them in a single entry when the request is done. Such
+// "canonical lines" summarize requests without searching for all of their
+// entries. A CanonicalLine is bound to the context of the request with
+// ContextWithCanonicalLine, fields are added with AddCanonicalFields.
+//
+// The methods of a nil *CanonicalLine do nothing, and it is safe for
+// concurrent use.
+type CanonicalLine struct {
+	logger *ZapEventLogger
+	msg    string
+	start  time.Time
+
+	mu      sync.Mutex
+	fields  []zapcore.Field
+	index   map[string]int // of fields by key
+	emitted bool
+}
+
+// NewCanonicalLine returns a canonical line logged by logger with msg.
+func NewCanonicalLine(logger *ZapEventLogger, msg string) *CanonicalLine {
+	return &CanonicalLine{logger: logger, msg: msg, start: time.Now(), index: make(map[string]int)}
+}
+
+// Add adds loosely typed key-value pairs, as in Infow, or fields to the line.
+// A key added again replaces the earlier value, keeping its position. Pairs
+// with a key that is not a string and a key without a value are ignored, as
+// are fields added after the line was emitted. Values are encoded by Emit.
+func (l *CanonicalLine) Add(keysAndValues ...interface{}) {
+	if l == nil {
+		return
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.emitted {
+		return
+	}
+	for i := 0; i < len(keysAndValues); i++ {
+		if f, ok := keysAndValues[i].(zapcore.Field); ok {
+			l.set(f)
+			continue
+		}
+		if i == len(keysAndValues)-1 {
+			break
+		}
+		if key, ok := keysAndValues[i].(string); ok {
+			l.set(zap.Any(key, keysAndValues[i+1]))
+		}
+		i++
+	}
+}
+
+func (l *CanonicalLine) set(f zapcore.Field) {
+	if i, ok := l.index[f.Key]; ok {
+		l.fields[i] = f
+		return
+	}
+	l.index[f.Key] = len(l.fields)
+	l.fields = append(l.fields, f)
+}
+
+// Emit logs the line at info level, with the fields added so far and the
+// duration of the request in CanonicalDurationKey. Only the first call logs.
+func (l *CanonicalLine) Emit() {
+	if l == nil {
+		return
+	}
+	l.mu.Lock()
+	if l.emitted {
+		l.mu.Unlock()
+		return
+	}
+	l.emitted = true
+	fields := append(l.fields, zap.Duration(CanonicalDurationKey, time.Since(l.start)))
+	l.mu.Unlock()
+
+	l.logger.skipLogger.Desugar().Info(l.msg, fields...)
+}
+
+type canonicalLineKey struct{}
+
+// ContextWithCanonicalLine returns a copy of ctx bound to line, see
+// AddCanonicalFields.
+func ContextWithCanonicalLine(ctx context.Context, line *CanonicalLine) context.Context {
+	return context.WithValue(ctx, canonicalLineKey{}, line)
+}
+
+// CanonicalLineFromContext returns the canonical line ctx is bound to, or nil.
+func CanonicalLineFromContext(ctx context.Context) *CanonicalLine {
+	line, _ := ctx.Value(canonicalLineKey{}).(*CanonicalLine)
+	return line
+}
+
+// AddCanonicalFields adds key-value pairs or fields to the canonical line ctx
+// is bound to, if any, see CanonicalLine.Add.
+func AddCanonicalFields(ctx context.Context, keysAndValues ...interface{}) {
+	CanonicalLineFromContext(ctx).Add(keysAndValues...)
+}
@@ -0,0 +1,58 @@
+package log
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+func TestCanonicalLine(t *testing.T) {
+	defer SetupLogging(Config{Stderr: true})
+
+	buf := &bytes.Buffer{}
+	SetupLogging(Config{Level: LevelInfo})
+	SetPrimaryCore(newCore(JSONOutput, zapcore.AddSync(buf), LevelDebug, nil))
+
+	line := NewCanonicalLine(Logger("canonical-test"), "request done")
+	ctx := ContextWithCanonicalLine(context.Background(), line)
+	AddCanonicalFields(ctx, "method", "GET", "status", 500)
+	AddCanonicalFields(ctx, zap.Int("blocks", 3), "status", 200, "dangling")
+	line.Emit()
+	line.Emit()
+	AddCanonicalFields(ctx, "late", true)
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 1 {
+		t.Fatalf("got %d entries", len(lines))
+	}
+	var entry map[string]interface{}
+	if err := json.Unmarshal([]byte(lines[0]), &entry); err != nil {
+		t.Fatal(err)
+	}
+	if entry["msg"] != "request done" || entry["method"] != "GET" || entry["status"] != float64(200) || entry["blocks"] != float64(3) {
+		t.Errorf("got entry %v", entry)
+	}
+	if _, ok := entry[CanonicalDurationKey]; !ok {
+		t.Error("got no duration")
+	}
+	if _, ok := entry["late"]; ok {
+		t.Error("got a field added after emitting")
+	}
+	if caller, _ := entry["caller"].(string); !strings.Contains(caller, "canonical_test.go:") {
+		t.Errorf("got caller %q", caller)
+	}
+	if strings.Index(lines[0], `"method"`) > strings.Index(lines[0], `"status"`) {
+		t.Errorf("replaced field moved: %s", lines[0])
+	}
+}
+
+func TestCanonicalLineWithoutContext(t *testing.T) {
+	// a context without a line is fine, as is a nil line.
+	AddCanonicalFields(context.Background(), "k", "v")
+	CanonicalLineFromContext(context.Background()).Emit()
+}
@@ -0,0 +1,243 @@
+// Package capture re-emits the output of child processes through the logging
+// pipeline, so that the output of plugins and other subprocesses ends up in
+// the same outputs, with the same levels and filters, as the logs of the
+// node:
+//
+//	cmd := exec.Command("ipfs-plugin")
+//	c := capture.Output(cmd, capture.Config{Subsystem: "plugin"})
+//	err := cmd.Run()
+//	c.Close()
+//
+// Entries written by a child using go-log, in any format, are re-emitted with
+// their level, time, caller, message, fields and stack trace, and their
+// logger in the ChildLoggerKey field. The level of other lines is guessed
+// from keywords such as "WARN" or "[error]" at their start, or a logfmt level
+// field.
+package capture
+
+import (
+	"bytes"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	log "github.com/ipfs/go-log/v2"
+	"github.com/ipfs/go-log/v2/logparse"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// Keys of the fields added to re-emitted lines.
+const (
+	// StreamKey is "stdout" or "stderr".
+	StreamKey = "stream"
+	// ChildLoggerKey is the logger of entries logged by a child using
+	// go-log.
+	ChildLoggerKey = "child_logger"
+)
+
+// maxLineSize is the size of the longest line re-emitted whole, longer lines
+// are split.
+const maxLineSize = 64 << 10
+
+// Config configures Output.
+type Config struct {
+	// Subsystem is the logger the output is re-emitted with. Defaults to
+	// the base name of the command.
+	Subsystem string
+
+	// Level is the level of lines whose level is not recognized. Defaults
+	// to info.
+	Level log.LogLevel
+}
+
+// Capture re-emits the output of a child process.
+type Capture struct {
+	stdout, stderr *lineWriter
+}
+
+// Output sets the stdout and stderr of cmd, which must not be started yet,
+// so that every line the child writes is re-emitted through the logger of
+// cfg.Subsystem.
+//
+// Close must be called after cmd.Wait returns, to re-emit the last lines if
+// they don't end with a newline.
+func Output(cmd *exec.Cmd, cfg Config) *Capture {
+	if cfg.Subsystem == "" {
+		cfg.Subsystem = filepath.Base(cmd.Path)
+	}
+	c := &Capture{
+		stdout: &lineWriter{subsystem: cfg.Subsystem, level: cfg.Level, stream: "stdout"},
+		stderr: &lineWriter{subsystem: cfg.Subsystem, level: cfg.Level, stream: "stderr"},
+	}
+	cmd.Stdout = c.stdout
+	cmd.Stderr = c.stderr
+	return c
+}
+
+// Close re-emits the incomplete last lines of the output.
+func (c *Capture) Close() error {
+	c.stdout.flush()
+	c.stderr.flush()
+	return nil
+}
+
+// lineWriter re-emits the lines written to it.
+type lineWriter struct {
+	subsystem string
+	level     log.LogLevel
+	stream    string
+
+	mu  sync.Mutex
+	buf []byte // incomplete line
+}
+
+func (w *lineWriter) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	n := len(p)
+	for len(p) > 0 {
+		i := bytes.IndexByte(p, '\n')
+		if i < 0 {
+			w.buf = append(w.buf, p...)
+			if len(w.buf) >= maxLineSize {
+				w.emit(w.buf)
+				w.buf = w.buf[:0]
+			}
+			break
+		}
+		line := p[:i]
+		if len(w.buf) > 0 {
+			line = append(w.buf, line...)
+			w.buf = w.buf[:0]
+		}
+		w.emit(line)
+		p = p[i+1:]
+	}
+	return n, nil
+}
+
+func (w *lineWriter) flush() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if len(w.buf) > 0 {
+		w.emit(w.buf)
+		w.buf = w.buf[:0]
+	}
+}
+
+// emit re-emits a line.
+func (w *lineWriter) emit(line []byte) {
+	line = bytes.TrimRight(line, "\r")
+	if len(bytes.TrimSpace(line)) == 0 {
+		return
+	}
+
+	ent := zapcore.Entry{
+		Level:      zapcore.Level(w.level),
+		Time:       time.Now(),
+		LoggerName: w.subsystem,
+		Message:    string(line),
+	}
+	var fields []zapcore.Field
+	if e, err := logparse.Parse(line); err == nil {
+		if err := ent.Level.UnmarshalText([]byte(e.Level)); err != nil {
+			ent.Level = zapcore.Level(w.level)
+		}
+		if !e.Time.IsZero() {
+			ent.Time = e.Time
+		}
+		ent.Message = e.Message
+		ent.Caller = parseCaller(e.Caller)
+		ent.Stack = e.Stacktrace
+		fields = decodedFields(e.Fields)
+		if e.Logger != "" {
+			fields = append(fields, zap.String(ChildLoggerKey, e.Logger))
+		}
+	} else if level, ok := guessLevel(ent.Message); ok {
+		ent.Level = zapcore.Level(level)
+	}
+
+	// writing to the core directly keeps the caller of the child and does
+	// not panic or exit on its panic and fatal entries.
+	core := log.Logger(w.subsystem).Desugar().Core()
+	if ce := core.Check(ent, nil); ce != nil {
+		ce.Write(append(fields, zap.String(StreamKey, w.stream))...)
+	}
+}
+
+// decodedFields converts decoded fields, in the order of their keys.
+func decodedFields(values map[string]interface{}) []zapcore.Field {
+	keys := make([]string, 0, len(values))
+	for k := range values {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	fields := make([]zapcore.Field, 0, len(keys)+2)
+	for _, k := range keys {
+		fields = append(fields, zap.Any(k, values[k]))
+	}
+	return fields
+}
+
+// parseCaller parses a caller in the "path/file.go:line" form.
+func parseCaller(caller string) zapcore.EntryCaller {
+	i := strings.LastIndexByte(caller, ':')
+	if i < 0 {
+		return zapcore.EntryCaller{}
+	}
+	line, err := strconv.Atoi(caller[i+1:])
+	if err != nil {
+		return zapcore.EntryCaller{}
+	}
+	return zapcore.EntryCaller{Defined: true, File: caller[:i], Line: line}
+}
+
+var (
+	// levelPrefix matches a level keyword at the start of a line, after up
+	// to two date and time parts, optionally in brackets or followed by a
+	// colon.
+	levelPrefix = regexp.MustCompile(`^(?:[\d/:.,+\-TZ]+\s+){0,2}[\[<(]?([A-Za-z]+)[\]>):]?(?:\s|$)`)
+	// logfmtLevel matches the level field of logfmt.
+	logfmtLevel = regexp.MustCompile(`(?:^|\s)(?:level|lvl|severity)="?([A-Za-z]+)`)
+)
+
+// levelKeywords maps the level keywords of common logging libraries.
+var levelKeywords = map[string]log.LogLevel{
+	"trace":    log.LevelDebug,
+	"debug":    log.LevelDebug,
+	"dbug":     log.LevelDebug,
+	"dbg":      log.LevelDebug,
+	"info":     log.LevelInfo,
+	"inf":      log.LevelInfo,
+	"notice":   log.LevelInfo,
+	"warn":     log.LevelWarn,
+	"warning":  log.LevelWarn,
+	"wrn":      log.LevelWarn,
+	"error":    log.LevelError,
+	"eror":     log.LevelError,
+	"err":      log.LevelError,
+	"crit":     log.LevelError,
+	"critical": log.LevelError,
+	"fatal":    log.LevelFatal,
+	"panic":    log.LevelPanic,
+}
+
+// guessLevel guesses the level of a line from its keywords.
+func guessLevel(line string) (log.LogLevel, bool) {
+	for _, re := range []*regexp.Regexp{levelPrefix, logfmtLevel} {
+		if m := re.FindStringSubmatch(line); m != nil {
+			if level, ok := levelKeywords[strings.ToLower(m[1])]; ok {
+				return level, true
+			}
+		}
+	}
+	return 0, false
+}
@@ -0,0 +1,122 @@
+package capture
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"strings"
+	"testing"
+
+	log "github.com/ipfs/go-log/v2"
+	"github.com/ipfs/go-log/v2/logparse"
+)
+
+// childOutput is written by the child process of the tests.
+const childOutput = `{"level":"warn","ts":"2010-05-23T15:14:00.000Z","logger":"dht","caller":"dht/query.go:12","msg":"slow peer","peer":"QmPeer"}
+2010-05-23T15:14:00.050Z	ERROR	dht	query failed
+[DEBUG] connecting
+level=info msg="listening"
+panic: unreachable
+plain line
+unterminated`
+
+func TestMain(m *testing.M) {
+	if os.Getenv("GOLOG_CAPTURE_CHILD") != "" {
+		lines := strings.Split(childOutput, "\n")
+		fmt.Fprintln(os.Stdout, strings.Join(lines[:2], "\n"))
+		fmt.Fprint(os.Stderr, strings.Join(lines[2:], "\n"))
+		os.Exit(0)
+	}
+	os.Exit(m.Run())
+}
+
+func TestOutput(t *testing.T) {
+	f, err := ioutil.TempFile("", "go-log-capture")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(f.Name())
+	f.Close()
+	log.SetupLogging(log.Config{Format: log.JSONOutput, Level: log.LevelDebug, File: f.Name()})
+	defer log.SetupLogging(log.Config{Stderr: true})
+
+	cmd := exec.Command(os.Args[0])
+	cmd.Env = append(os.Environ(), "GOLOG_CAPTURE_CHILD=1")
+	c := Output(cmd, Config{Subsystem: "plugin", Level: log.LevelWarn})
+	if err := cmd.Run(); err != nil {
+		t.Fatal(err)
+	}
+	c.Close()
+
+	r, err := os.Open(f.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	var entries []logparse.Entry
+	dec := logparse.NewDecoder(r)
+	for {
+		e, err := dec.Next()
+		if err != nil {
+			break
+		}
+		entries = append(entries, e)
+	}
+
+	want := []struct{ level, msg, stream string }{
+		{"warn", "slow peer", "stdout"},
+		{"error", "query failed", "stdout"},
+		{"debug", "[DEBUG] connecting", "stderr"},
+		{"info", `level=info msg="listening"`, "stderr"},
+		{"panic", "panic: unreachable", "stderr"},
+		{"warn", "plain line", "stderr"},
+		{"warn", "unterminated", "stderr"},
+	}
+	// the streams are copied concurrently.
+	if len(entries) != len(want) {
+		t.Fatalf("got %d entries, want %d: %+v", len(entries), len(want), entries)
+	}
+	for _, w := range want {
+		found := false
+		for _, e := range entries {
+			if e.Message == w.msg {
+				found = true
+				if e.Level != w.level || e.Logger != "plugin" || e.Fields[StreamKey] != w.stream {
+					t.Errorf("got %+v for %q", e, w.msg)
+				}
+			}
+		}
+		if !found {
+			t.Errorf("no entry for %q", w.msg)
+		}
+	}
+	for _, e := range entries {
+		if e.Message == "slow peer" {
+			if e.Fields["peer"] != "QmPeer" || e.Fields[ChildLoggerKey] != "dht" || e.Caller != "dht/query.go:12" ||
+				e.Time.Format("15:04:05") != "15:14:00" {
+				t.Errorf("got %+v", e)
+			}
+		}
+	}
+}
+
+func TestGuessLevel(t *testing.T) {
+	for line, want := range map[string]log.LogLevel{
+		"WARN something":                       log.LevelWarn,
+		"warning: deprecated flag":             log.LevelWarn,
+		"2021/05/23 15:14:00 ERROR: failed":    log.LevelError,
+		"2021-05-23T15:14:00Z <info> started":  log.LevelInfo,
+		"[trace] step":                         log.LevelDebug,
+		`ts=2021-05-23 lvl=crit msg="on fire"`: log.LevelError,
+	} {
+		if got, ok := guessLevel(line); !ok || got != want {
+			t.Errorf("guessLevel(%q) = %v, %v, want %v", line, got, ok, want)
+		}
+	}
+	for _, line := range []string{"listening on 4001", "the error was ignored", "2021/05/23 15:14:00 started"} {
+		if got, ok := guessLevel(line); ok {
+			t.Errorf("guessLevel(%q) = %v", line, got)
+		}
+	}
+}
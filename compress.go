@@ -0,0 +1,161 @@
+package log
+
+import (
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+
+	"go.uber.org/zap/zapcore"
+)
+
+// CompressWriter is a streaming compressor. Flush must write all pending data
+// as a complete block, so that everything written so far can be decompressed
+// without closing the stream.
+//
+// Both *gzip.Writer and the zstd encoder of github.com/klauspost/compress
+// implement this interface.
+type CompressWriter interface {
+	io.WriteCloser
+	Flush() error
+}
+
+// Compressor wraps w in a streaming compressor.
+type Compressor func(w io.Writer) (CompressWriter, error)
+
+// GzipCompressor returns a Compressor producing gzip streams at the given
+// compression level (see compress/gzip).
+func GzipCompressor(level int) Compressor {
+	return func(w io.Writer) (CompressWriter, error) {
+		return gzip.NewWriterLevel(w, level)
+	}
+}
+
+// CompressionConfig configures stream compression for an output.
+type CompressionConfig struct {
+	// Compressor creates the compressor. Compression is disabled when nil.
+	Compressor Compressor
+
+	// FlushInterval is how often a flush point is written, which makes the
+	// entries logged so far readable from the compressed output. Defaults
+	// to one second.
+	FlushInterval time.Duration
+}
+
+var _ zapcore.WriteSyncer = (*compressedWriteSyncer)(nil)
+
+// compressedWriteSyncer compresses everything written to it.
+type compressedWriteSyncer struct {
+	mu    sync.Mutex // guards cw and dirty
+	ws    zapcore.WriteSyncer
+	cw    CompressWriter
+	dirty bool
+
+	// onError is called with the errors of background flushes. They are
+	// printed to stderr when it is nil.
+	onError func(error)
+
+	stop     chan struct{}
+	done     chan struct{}
+	stopOnce sync.Once
+	stopErr  error
+}
+
+// newCompressedWriteSyncer returns a compressor in front of ws, reporting the
+// errors of background flushes to onError.
+func newCompressedWriteSyncer(ws zapcore.WriteSyncer, cfg CompressionConfig, onError func(error)) (*compressedWriteSyncer, error) {
+	cw, err := cfg.Compressor(ws)
+	if err != nil {
+		return nil, err
+	}
+
+	interval := cfg.FlushInterval
+	if interval <= 0 {
+		interval = defaultFlushInterval
+	}
+
+	c := &compressedWriteSyncer{
+		ws:      ws,
+		cw:      cw,
+		onError: onError,
+		stop:    make(chan struct{}),
+		done:    make(chan struct{}),
+	}
+	go c.flushLoop(interval)
+	return c, nil
+}
+
+func (c *compressedWriteSyncer) Write(p []byte) (int, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.dirty = true
+	return c.cw.Write(p)
+}
+
+// Sync writes a flush point and syncs the underlying output.
+func (c *compressedWriteSyncer) Sync() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if err := c.flush(); err != nil {
+		return err
+	}
+	return c.ws.Sync()
+}
+
+// Stop terminates the compressed stream. The underlying output is left open.
+// Calls after the first return the result of the first.
+func (c *compressedWriteSyncer) Stop() error {
+	c.stopOnce.Do(func() {
+		close(c.stop)
+		<-c.done
+
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		c.stopErr = c.cw.Close()
+	})
+	return c.stopErr
+}
+
+// flush writes a flush point if anything was written since the last one.
+// c.mu must be held.
+func (c *compressedWriteSyncer) flush() error {
+	if !c.dirty {
+		return nil
+	}
+	c.dirty = false
+	return c.cw.Flush()
+}
+
+func (c *compressedWriteSyncer) flushLoop(interval time.Duration) {
+	defer close(c.done)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			c.mu.Lock()
+			err := c.flush()
+			c.mu.Unlock()
+			if err != nil {
+				c.flushFailed(err)
+			}
+		case <-c.stop:
+			return
+		}
+	}
+}
+
+// flushFailed reports the error of a background flush.
+func (c *compressedWriteSyncer) flushFailed(err error) {
+	if c.onError != nil {
+		c.onError(err)
+		return
+	}
+	fmt.Fprintf(os.Stderr, "failed to flush compressed log output: %s\n", err)
+}
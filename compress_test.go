@@ -0,0 +1,134 @@
+package log
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+// syncBuffer is a bytes.Buffer implementing zapcore.WriteSyncer.
+type syncBuffer struct {
+	bytes.Buffer
+}
+
+func (b *syncBuffer) Sync() error { return nil }
+
+func TestCompressedWriteSyncerFlushPoints(t *testing.T) {
+	buf := &syncBuffer{}
+	c, err := newCompressedWriteSyncer(buf, CompressionConfig{
+		Compressor:    GzipCompressor(gzip.DefaultCompression),
+		FlushInterval: time.Hour,
+	}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := "scooby\n"
+	if _, err := c.Write([]byte(want)); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Sync(); err != nil {
+		t.Fatal(err)
+	}
+
+	// the stream is not terminated yet, but everything written before the
+	// flush point is readable.
+	zr, err := gzip.NewReader(bytes.NewReader(buf.Bytes()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := make([]byte, len(want))
+	if _, err := io.ReadFull(zr, got); err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+
+	if err := c.Stop(); err != nil {
+		t.Fatal(err)
+	}
+	zr, err = gzip.NewReader(bytes.NewReader(buf.Bytes()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	all, err := ioutil.ReadAll(zr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(all) != want {
+		t.Errorf("got %q, want %q", all, want)
+	}
+}
+
+func TestLogToCompressedFile(t *testing.T) {
+	logfile, err := ioutil.TempFile("", "go-log-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(logfile.Name())
+
+	SetupLogging(Config{
+		Format:          PlaintextOutput,
+		File:            logfile.Name(),
+		FileBuffer:      BufferConfig{Size: 4096},
+		FileCompression: CompressionConfig{Compressor: GzipCompressor(gzip.BestSpeed)},
+	})
+
+	log := getLogger("test")
+	log.Error("grokgrokgrok")
+
+	// replacing the configuration terminates the compressed stream.
+	SetupLogging(Config{Stderr: true})
+
+	f, err := os.Open(logfile.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	zr, err := gzip.NewReader(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	content, err := ioutil.ReadAll(zr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(content), "grokgrokgrok") {
+		t.Errorf("got %q, wanted it to contain log output", content)
+	}
+}
+
+func TestCompressedWriteSyncerFlushError(t *testing.T) {
+	errs := make(chan error, 1)
+	c, err := newCompressedWriteSyncer(failingWriteSyncer{}, CompressionConfig{
+		Compressor:    GzipCompressor(gzip.DefaultCompression),
+		FlushInterval: 10 * time.Millisecond,
+	}, func(err error) {
+		select {
+		case errs <- err:
+		default:
+		}
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.Write([]byte("scooby\n")) // nolint:errcheck
+
+	select {
+	case <-errs:
+	case <-time.After(time.Second):
+		t.Fatal("flush error was not reported")
+	}
+
+	// Stop is idempotent.
+	err = c.Stop()
+	if err2 := c.Stop(); err2 != err {
+		t.Errorf("got %v from the second Stop, want %v", err2, err)
+	}
+}
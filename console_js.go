@@ -0,0 +1,93 @@
+//go:build js && wasm
+// +build js,wasm
+
+package log
+
+import (
+	"syscall/js"
+	"time"
+
+	"go.uber.org/zap/zapcore"
+)
+
+// platformOutputs replaces the outputs of cfg that don't exist in browsers.
+// Entries meant for stderr or stdout go to the browser console instead, and
+// files are not supported.
+func platformOutputs(cfg Config) (Config, zapcore.Core) {
+	var core zapcore.Core
+	if cfg.Stderr || cfg.Stdout {
+		core = newConsoleCore(cfg.TimeLocation)
+	}
+	if cfg.File != "" {
+		js.Global().Get("console").Call("warn", "go-log: logging to files is not supported in browsers, ignoring "+cfg.File)
+	}
+	cfg.Stderr, cfg.Stdout, cfg.File = false, false, ""
+	return cfg, core
+}
+
+var _ zapcore.Core = (*consoleCore)(nil)
+
+// consoleCore writes entries to the browser console, using the console
+// method matching their level.
+type consoleCore struct {
+	zapcore.LevelEnabler
+	enc     zapcore.Encoder
+	console js.Value
+}
+
+func newConsoleCore(loc *time.Location) *consoleCore {
+	return &consoleCore{
+		LevelEnabler: zapcore.DebugLevel,
+		// browsers don't render ANSI colors.
+		enc:     newEncoder(PlaintextOutput, loc),
+		console: js.Global().Get("console"),
+	}
+}
+
+func (c *consoleCore) With(fields []zapcore.Field) zapcore.Core {
+	enc := c.enc.Clone()
+	for i := range fields {
+		fields[i].AddTo(enc)
+	}
+	return &consoleCore{
+		LevelEnabler: c.LevelEnabler,
+		enc:          enc,
+		console:      c.console,
+	}
+}
+
+func (c *consoleCore) Check(ent zapcore.Entry, ce *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if c.Enabled(ent.Level) {
+		return ce.AddCore(ent, c)
+	}
+	return ce
+}
+
+func (c *consoleCore) Write(ent zapcore.Entry, fields []zapcore.Field) error {
+	buf, err := c.enc.EncodeEntry(ent, fields)
+	if err != nil {
+		return err
+	}
+	defer buf.Free()
+
+	method := "error"
+	switch ent.Level {
+	case zapcore.DebugLevel:
+		method = "debug"
+	case zapcore.InfoLevel:
+		method = "info"
+	case zapcore.WarnLevel:
+		method = "warn"
+	}
+	// the console adds its own line breaks.
+	msg := buf.String()
+	if n := len(msg); n > 0 && msg[n-1] == '\n' {
+		msg = msg[:n-1]
+	}
+	c.console.Call(method, msg)
+	return nil
+}
+
+func (c *consoleCore) Sync() error {
+	return nil
+}
@@ -0,0 +1,44 @@
+//go:build js && wasm
+// +build js,wasm
+
+package log
+
+import (
+	"strings"
+	"syscall/js"
+	"testing"
+
+	"go.uber.org/zap/zapcore"
+)
+
+func TestConsoleCore(t *testing.T) {
+	var calls []string
+	console := js.Global().Get("Object").New()
+	for _, method := range []string{"debug", "info", "warn", "error"} {
+		method := method
+		fn := js.FuncOf(func(this js.Value, args []js.Value) interface{} {
+			calls = append(calls, method+": "+args[0].String())
+			return nil
+		})
+		defer fn.Release()
+		console.Set(method, fn)
+	}
+
+	core := newConsoleCore(nil)
+	core.console = console
+	for _, lvl := range []zapcore.Level{zapcore.DebugLevel, zapcore.WarnLevel, zapcore.DPanicLevel} {
+		if ce := core.Check(zapcore.Entry{Level: lvl, Message: "msg"}, nil); ce != nil {
+			ce.Write()
+		}
+	}
+
+	want := []string{"debug: ", "warn: ", "error: "}
+	if len(calls) != len(want) {
+		t.Fatalf("got calls %q", calls)
+	}
+	for i := range want {
+		if !strings.HasPrefix(calls[i], want[i]) || !strings.HasSuffix(calls[i], "\tmsg") {
+			t.Errorf("got call %q, want %s...", calls[i], want[i])
+		}
+	}
+}
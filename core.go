@@ -1,121 +1,305 @@
 package log
 
 import (
+	"errors"
 	"reflect"
+	"strings"
 	"sync"
+	"sync/atomic"
+	"time"
 
 	"go.uber.org/multierr"
 	"go.uber.org/zap"
 	"go.uber.org/zap/zapcore"
 )
 
-var _ zapcore.Core = (*lockedMultiCore)(nil)
+var _ zapcore.Core = (*multiCore)(nil)
 
-type lockedMultiCore struct {
-	mu    sync.RWMutex // guards mutations to cores slice
-	cores []zapcore.Core
+// multiCore fans entries out to a set of cores. The set is an immutable slice
+// that is swapped atomically on updates, so logging never waits on a lock.
+type multiCore struct {
+	mu    sync.Mutex   // serializes updates of cores
+	cores atomic.Value // []zapcore.Core, never modified once stored
 }
 
-func (l *lockedMultiCore) With(fields []zapcore.Field) zapcore.Core {
-	l.mu.RLock()
-	defer l.mu.RUnlock()
-	sub := &lockedMultiCore{
-		cores: make([]zapcore.Core, len(l.cores)),
-	}
-	for i := range l.cores {
-		sub.cores[i] = l.cores[i].With(fields)
+func newMultiCore(cores []zapcore.Core) *multiCore {
+	mc := &multiCore{}
+	mc.cores.Store(cores)
+	return mc
+}
+
+func (l *multiCore) load() []zapcore.Core {
+	cores, _ := l.cores.Load().([]zapcore.Core)
+	return cores
+}
+
+func (l *multiCore) With(fields []zapcore.Field) zapcore.Core {
+	cores := l.load()
+	sub := make([]zapcore.Core, len(cores))
+	for i := range cores {
+		sub[i] = cores[i].With(fields)
 	}
-	return sub
+	return newMultiCore(sub)
 }
 
-func (l *lockedMultiCore) Enabled(lvl zapcore.Level) bool {
-	l.mu.RLock()
-	defer l.mu.RUnlock()
-	for i := range l.cores {
-		if l.cores[i].Enabled(lvl) {
+func (l *multiCore) Enabled(lvl zapcore.Level) bool {
+	for _, core := range l.load() {
+		if core.Enabled(lvl) {
 			return true
 		}
 	}
 	return false
 }
 
-func (l *lockedMultiCore) Check(ent zapcore.Entry, ce *zapcore.CheckedEntry) *zapcore.CheckedEntry {
-	l.mu.RLock()
-	defer l.mu.RUnlock()
-	for i := range l.cores {
-		ce = l.cores[i].Check(ent, ce)
+func (l *multiCore) Check(ent zapcore.Entry, ce *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	for _, core := range l.load() {
+		ce = core.Check(ent, ce)
 	}
 	return ce
 }
 
-func (l *lockedMultiCore) Write(ent zapcore.Entry, fields []zapcore.Field) error {
-	l.mu.RLock()
-	defer l.mu.RUnlock()
+// Write writes the entry to the cores enabled for its level, for wrappers
+// writing to a multiCore directly rather than through Check.
+func (l *multiCore) Write(ent zapcore.Entry, fields []zapcore.Field) error {
 	var err error
-	for i := range l.cores {
-		err = multierr.Append(err, l.cores[i].Write(ent, fields))
+	for _, core := range l.load() {
+		if !core.Enabled(ent.Level) {
+			continue
+		}
+		err = multierr.Append(err, core.Write(ent, fields))
 	}
 	return err
 }
 
-func (l *lockedMultiCore) Sync() error {
-	l.mu.RLock()
-	defer l.mu.RUnlock()
+func (l *multiCore) Sync() error {
 	var err error
-	for i := range l.cores {
-		err = multierr.Append(err, l.cores[i].Sync())
+	for _, core := range l.load() {
+		err = multierr.Append(err, core.Sync())
 	}
 	return err
 }
 
-func (l *lockedMultiCore) AddCore(core zapcore.Core) {
+func (l *multiCore) AddCore(core zapcore.Core) {
 	l.mu.Lock()
 	defer l.mu.Unlock()
 
-	l.cores = append(l.cores, core)
+	old := l.load()
+	cores := make([]zapcore.Core, len(old), len(old)+1)
+	copy(cores, old)
+	l.cores.Store(append(cores, core))
 }
 
-func (l *lockedMultiCore) DeleteCore(core zapcore.Core) {
+func (l *multiCore) DeleteCore(core zapcore.Core) {
 	l.mu.Lock()
 	defer l.mu.Unlock()
 
-	w := 0
-	for i := 0; i < len(l.cores); i++ {
-		if reflect.DeepEqual(l.cores[i], core) {
+	old := l.load()
+	cores := make([]zapcore.Core, 0, len(old))
+	for i := 0; i < len(old); i++ {
+		if reflect.DeepEqual(old[i], core) {
 			continue
 		}
-		l.cores[w] = l.cores[i]
-		w++
+		cores = append(cores, old[i])
 	}
-	l.cores = l.cores[:w]
+	l.cores.Store(cores)
 }
 
-func (l *lockedMultiCore) ReplaceCore(original, replacement zapcore.Core) {
+func (l *multiCore) ReplaceCore(original, replacement zapcore.Core) {
 	l.mu.Lock()
 	defer l.mu.Unlock()
 
-	for i := 0; i < len(l.cores); i++ {
-		if reflect.DeepEqual(l.cores[i], original) {
-			l.cores[i] = replacement
+	old := l.load()
+	cores := make([]zapcore.Core, len(old))
+	for i := 0; i < len(old); i++ {
+		if reflect.DeepEqual(old[i], original) {
+			cores[i] = replacement
+		} else {
+			cores[i] = old[i]
+		}
+	}
+	l.cores.Store(cores)
+}
+
+var _ zapcore.Core = (*levelCore)(nil)
+
+// levelCore filters the entries of a subsystem logger by the level of the
+// subsystem, or the level of the package logging the entry if one is set with
+// SetPackageLogLevel, and by the function set with SetLevelEnabler. While
+// muted, entries not on the allowlist of Mute are dropped first.
+type levelCore struct {
+	next   zapcore.Core
+	level  zap.AtomicLevel
+	fields []zapcore.Field // accumulated by With, for the level enabler
+}
+
+func (c *levelCore) With(fields []zapcore.Field) zapcore.Core {
+	return &levelCore{
+		next:   c.next.With(fields),
+		level:  c.level,
+		fields: append(c.fields[:len(c.fields):len(c.fields)], fields...),
+	}
+}
+
+func (c *levelCore) Enabled(lvl zapcore.Level) bool {
+	if c.level.Enabled(lvl) {
+		return true
+	}
+	pkgLevels := loadPackageLevels()
+	return pkgLevels != nil && lvl >= pkgLevels.min
+}
+
+func (c *levelCore) Check(ent zapcore.Entry, ce *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if allow := loadMute(); allow != nil && !allow.allows(ent.LoggerName, ent.Level) {
+		return ce
+	}
+	if loadPackageLevels() != nil || loadLevelEnabler() != nil {
+		if !c.Enabled(ent.Level) {
+			return ce
 		}
+		// the caller and fields are only known when writing.
+		return ce.AddCore(ent, c)
 	}
+	if !c.level.Enabled(ent.Level) {
+		return ce
+	}
+	return c.next.Check(ent, ce)
 }
 
-func newCore(format LogFormat, ws zapcore.WriteSyncer, level LogLevel) zapcore.Core {
+func (c *levelCore) Write(ent zapcore.Entry, fields []zapcore.Field) error {
+	enabled := c.level.Enabled(ent.Level)
+	if pkgLevels := loadPackageLevels(); pkgLevels != nil {
+		if lvl, ok := pkgLevels.level(ent.Caller); ok {
+			enabled = lvl.Enabled(ent.Level)
+		}
+	}
+	if !enabled {
+		return nil
+	}
+	if enabler := loadLevelEnabler(); enabler != nil {
+		all := append(c.fields[:len(c.fields):len(c.fields)], fields...)
+		enabled, errField := callLevelEnabler(enabler, ent.LoggerName, LogLevel(ent.Level), all)
+		if !enabled {
+			return nil
+		}
+		if errField != nil {
+			fields = append(fields[:len(fields):len(fields)], *errField)
+		}
+	}
+	if !c.next.Enabled(ent.Level) {
+		return nil
+	}
+	return c.next.Write(ent, fields)
+}
+
+func (c *levelCore) Sync() error {
+	return c.next.Sync()
+}
+
+// writeChecked writes an entry to the cores of next that accept it, as a
+// logger does, and returns their errors. A CheckedEntry writes them to the
+// error output of the logger, which wrappers of its core don't know.
+func writeChecked(next zapcore.Core, ent zapcore.Entry, fields []zapcore.Field) error {
+	ce := next.Check(ent, nil)
+	if ce == nil {
+		return nil
+	}
+	errs := &errorRecorder{}
+	ce.ErrorOutput = errs
+	ce.Write(fields...)
+	return errs.err
+}
+
+// errorRecorder is the error output of the CheckedEntries of writeChecked,
+// recording the error of the "<time> write error: <error>" line.
+type errorRecorder struct {
+	err error
+}
+
+func (r *errorRecorder) Write(p []byte) (int, error) {
+	msg := strings.TrimSpace(string(p))
+	if i := strings.Index(msg, "write error: "); i >= 0 {
+		msg = msg[i+len("write error: "):]
+	}
+	r.err = errors.New(msg)
+	return len(p), nil
+}
+
+func (r *errorRecorder) Sync() error {
+	return nil
+}
+
+// newCore builds a core writing entries in the given format to ws.
+func newCore(format LogFormat, ws zapcore.WriteSyncer, level LogLevel, loc *time.Location) zapcore.Core {
+	return zapcore.NewCore(newEncoder(format, loc), ws, zap.NewAtomicLevelAt(zapcore.Level(level)))
+}
+
+// newEncoder builds an encoder for the given format. Timestamps of
+// human-readable formats are rendered in loc (UTC when nil); JSON output is
+// always UTC. Human-readable formats render durations and the values of
+// Bytes and BytesPerSecond fields with units; JSON output has plain numbers.
+// Formats registered with RegisterFormat get the configuration of JSON output.
+// Panicking marshalers are encoded as errors, see safeEncoder.
+func newEncoder(format LogFormat, loc *time.Location) zapcore.Encoder {
+	if loc == nil {
+		loc = time.UTC
+	}
+
 	encCfg := zap.NewProductionEncoderConfig()
-	encCfg.EncodeTime = zapcore.ISO8601TimeEncoder
+	encCfg.EncodeTime = timeEncoder(loc)
 
-	var encoder zapcore.Encoder
+	if custom, ok := lookupCustomFormat(format); ok {
+		encCfg.EncodeTime = timeEncoder(time.UTC)
+		return &safeEncoder{custom.newEncoder(encCfg)}
+	}
 	switch format {
 	case PlaintextOutput:
 		encCfg.EncodeLevel = zapcore.CapitalLevelEncoder
-		encoder = zapcore.NewConsoleEncoder(encCfg)
 	case JSONOutput:
-		encoder = zapcore.NewJSONEncoder(encCfg)
+		encCfg.EncodeTime = timeEncoder(time.UTC)
+		return &safeEncoder{zapcore.NewJSONEncoder(encCfg)}
+	case GrepOutput:
+		encCfg.EncodeLevel = letterLevelEncoder
+		encCfg.EncodeName = paddedNameEncoder
 	default:
 		encCfg.EncodeLevel = zapcore.CapitalColorLevelEncoder
-		encoder = zapcore.NewConsoleEncoder(encCfg)
 	}
+	encCfg.EncodeDuration = zapcore.StringDurationEncoder
+	return &safeEncoder{&humanEncoder{zapcore.NewConsoleEncoder(encCfg)}}
+}
+
+// levelLetters are the levels of GrepOutput.
+var levelLetters = map[zapcore.Level]string{
+	zapcore.DebugLevel:  "D",
+	zapcore.InfoLevel:   "I",
+	zapcore.WarnLevel:   "W",
+	zapcore.ErrorLevel:  "E",
+	zapcore.DPanicLevel: "P",
+	zapcore.PanicLevel:  "P",
+	zapcore.FatalLevel:  "F",
+}
+
+func letterLevelEncoder(lvl zapcore.Level, enc zapcore.PrimitiveArrayEncoder) {
+	letter, ok := levelLetters[lvl]
+	if !ok {
+		letter = "?"
+	}
+	enc.AppendString(letter)
+}
 
-	return zapcore.NewCore(encoder, ws, zap.NewAtomicLevelAt(zapcore.Level(level)))
+// grepNameWidth is the width of the subsystem column of GrepOutput. Longer
+// names are not truncated.
+const grepNameWidth = 20
+
+func paddedNameEncoder(name string, enc zapcore.PrimitiveArrayEncoder) {
+	if pad := grepNameWidth - len(name); pad > 0 {
+		name += strings.Repeat(" ", pad)
+	}
+	enc.AppendString(name)
+}
+
+// timeEncoder returns an ISO8601 time encoder that renders timestamps in loc.
+func timeEncoder(loc *time.Location) zapcore.TimeEncoder {
+	return func(t time.Time, enc zapcore.PrimitiveArrayEncoder) {
+		zapcore.ISO8601TimeEncoder(t.In(loc), enc)
+	}
 }
@@ -2,6 +2,7 @@ package log
 
 import (
 	"bytes"
+	"io/ioutil"
 	"testing"
 	"time"
 
@@ -32,13 +33,17 @@ func TestNewCoreFormat(t *testing.T) {
 			format: PlaintextOutput,
 			want:   "2010-05-23T15:14:00.000Z\tINFO\tmain\tscooby\n",
 		},
+		{
+			format: GrepOutput,
+			want:   "2010-05-23T15:14:00.000Z\tI\tmain                \tscooby\n",
+		},
 	}
 
 	for _, tc := range testCases {
 		buf := &bytes.Buffer{}
 		ws := zapcore.AddSync(buf)
 
-		core := newCore(tc.format, ws, LevelDebug)
+		core := newCore(tc.format, ws, LevelDebug, nil)
 		if err := core.Write(entry, nil); err != nil {
 			t.Fatalf("unexpected error: %v", err)
 		}
@@ -51,15 +56,15 @@ func TestNewCoreFormat(t *testing.T) {
 
 }
 
-func TestLockedMultiCoreAddCore(t *testing.T) {
-	mc := &lockedMultiCore{}
+func TestMultiCoreAddCore(t *testing.T) {
+	mc := &multiCore{}
 
 	buf1 := &bytes.Buffer{}
-	core1 := newCore(PlaintextOutput, zapcore.AddSync(buf1), LevelDebug)
+	core1 := newCore(PlaintextOutput, zapcore.AddSync(buf1), LevelDebug, nil)
 	mc.AddCore(core1)
 
 	buf2 := &bytes.Buffer{}
-	core2 := newCore(ColorizedOutput, zapcore.AddSync(buf2), LevelDebug)
+	core2 := newCore(ColorizedOutput, zapcore.AddSync(buf2), LevelDebug, nil)
 	mc.AddCore(core2)
 
 	entry := zapcore.Entry{
@@ -86,12 +91,12 @@ func TestLockedMultiCoreAddCore(t *testing.T) {
 
 }
 
-func TestLockedMultiCoreDeleteCore(t *testing.T) {
+func TestMultiCoreDeleteCore(t *testing.T) {
 
-	mc := &lockedMultiCore{}
+	mc := &multiCore{}
 
 	buf1 := &bytes.Buffer{}
-	core1 := newCore(PlaintextOutput, zapcore.AddSync(buf1), LevelDebug)
+	core1 := newCore(PlaintextOutput, zapcore.AddSync(buf1), LevelDebug, nil)
 	mc.AddCore(core1)
 
 	// Write entry to just first core
@@ -106,7 +111,7 @@ func TestLockedMultiCoreDeleteCore(t *testing.T) {
 	}
 
 	buf2 := &bytes.Buffer{}
-	core2 := newCore(ColorizedOutput, zapcore.AddSync(buf2), LevelDebug)
+	core2 := newCore(ColorizedOutput, zapcore.AddSync(buf2), LevelDebug, nil)
 	mc.AddCore(core2)
 
 	// Remove the first core
@@ -138,11 +143,11 @@ func TestLockedMultiCoreDeleteCore(t *testing.T) {
 
 }
 
-func TestLockedMultiCoreReplaceCore(t *testing.T) {
-	mc := &lockedMultiCore{}
+func TestMultiCoreReplaceCore(t *testing.T) {
+	mc := &multiCore{}
 
 	buf1 := &bytes.Buffer{}
-	core1 := newCore(PlaintextOutput, zapcore.AddSync(buf1), LevelDebug)
+	core1 := newCore(PlaintextOutput, zapcore.AddSync(buf1), LevelDebug, nil)
 	mc.AddCore(core1)
 
 	// Write entry to just first core
@@ -157,7 +162,7 @@ func TestLockedMultiCoreReplaceCore(t *testing.T) {
 	}
 
 	buf2 := &bytes.Buffer{}
-	core2 := newCore(ColorizedOutput, zapcore.AddSync(buf2), LevelDebug)
+	core2 := newCore(ColorizedOutput, zapcore.AddSync(buf2), LevelDebug, nil)
 
 	// Replace the first core with the second
 	mc.ReplaceCore(core1, core2)
@@ -187,3 +192,66 @@ func TestLockedMultiCoreReplaceCore(t *testing.T) {
 	}
 
 }
+
+func TestNewCoreTimeLocation(t *testing.T) {
+	loc := time.FixedZone("UTC+2", 2*60*60)
+	entry := zapcore.Entry{
+		LoggerName: "main",
+		Level:      zapcore.InfoLevel,
+		Message:    "scooby",
+		Time:       time.Date(2010, 5, 23, 15, 14, 0, 0, time.UTC),
+	}
+
+	testCases := []struct {
+		format LogFormat
+		want   string
+	}{
+		{
+			format: PlaintextOutput,
+			want:   "2010-05-23T17:14:00.000+0200\tINFO\tmain\tscooby\n",
+		},
+		{
+			// JSON output stays in UTC regardless of the configured location.
+			format: JSONOutput,
+			want:   `{"level":"info","ts":"2010-05-23T15:14:00.000Z","logger":"main","msg":"scooby"}` + "\n",
+		},
+	}
+
+	for _, tc := range testCases {
+		buf := &bytes.Buffer{}
+		core := newCore(tc.format, zapcore.AddSync(buf), LevelDebug, loc)
+		if err := core.Write(entry, nil); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		if got := buf.String(); got != tc.want {
+			t.Errorf("got %q, want %q", got, tc.want)
+		}
+	}
+}
+
+func TestMultiCoreConcurrentUpdates(t *testing.T) {
+	mc := &multiCore{}
+	entry := zapcore.Entry{
+		LoggerName: "main",
+		Level:      zapcore.InfoLevel,
+		Message:    "scooby",
+	}
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 100; i++ {
+			core := newCore(JSONOutput, zapcore.AddSync(ioutil.Discard), LevelDebug, nil)
+			mc.AddCore(core)
+			mc.DeleteCore(core)
+		}
+	}()
+
+	for i := 0; i < 100; i++ {
+		if ce := mc.Check(entry, nil); ce != nil {
+			ce.Write()
+		}
+	}
+	<-done
+}
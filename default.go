@@ -0,0 +1,89 @@
+package log
+
+import "sync"
+
+// DefaultSubsystem is the subsystem of the package-level logging functions,
+// i.e. Infof. They are meant for small programs that don't need named
+// loggers; levels and outputs apply to them as to any other logger.
+const DefaultSubsystem = "default"
+
+var (
+	defaultLoggerOnce sync.Once
+	defaultLog        *ZapEventLogger
+)
+
+// defaultLogger returns the logger of DefaultSubsystem, created on first use.
+func defaultLogger() *ZapEventLogger {
+	defaultLoggerOnce.Do(func() {
+		defaultLog = Logger(DefaultSubsystem)
+	})
+	return defaultLog
+}
+
+// Warn logs to the DefaultSubsystem logger at warn level.
+func Warn(args ...interface{}) {
+	defaultLogger().skipLogger.Warn(args...)
+}
+
+// Warnf logs a formatted message to the DefaultSubsystem logger at warn level.
+func Warnf(format string, args ...interface{}) {
+	defaultLogger().skipLogger.Warnf(format, args...)
+}
+
+// Warnw logs a message with fields to the DefaultSubsystem logger at warn
+// level.
+func Warnw(msg string, keysAndValues ...interface{}) {
+	defaultLogger().skipLogger.Warnw(msg, keysAndValues...)
+}
+
+// Error logs to the DefaultSubsystem logger at error level.
+func Error(args ...interface{}) {
+	defaultLogger().skipLogger.Error(args...)
+}
+
+// Errorf logs a formatted message to the DefaultSubsystem logger at error
+// level.
+func Errorf(format string, args ...interface{}) {
+	defaultLogger().skipLogger.Errorf(format, args...)
+}
+
+// Errorw logs a message with fields to the DefaultSubsystem logger at error
+// level.
+func Errorw(msg string, keysAndValues ...interface{}) {
+	defaultLogger().skipLogger.Errorw(msg, keysAndValues...)
+}
+
+// Panic logs to the DefaultSubsystem logger at panic level, then panics.
+func Panic(args ...interface{}) {
+	defaultLogger().skipLogger.Panic(args...)
+}
+
+// Panicf logs a formatted message to the DefaultSubsystem logger at panic
+// level, then panics.
+func Panicf(format string, args ...interface{}) {
+	defaultLogger().skipLogger.Panicf(format, args...)
+}
+
+// Panicw logs a message with fields to the DefaultSubsystem logger at panic
+// level, then panics.
+func Panicw(msg string, keysAndValues ...interface{}) {
+	defaultLogger().skipLogger.Panicw(msg, keysAndValues...)
+}
+
+// Fatal logs to the DefaultSubsystem logger at fatal level, then calls
+// os.Exit(1).
+func Fatal(args ...interface{}) {
+	defaultLogger().skipLogger.Fatal(args...)
+}
+
+// Fatalf logs a formatted message to the DefaultSubsystem logger at fatal
+// level, then calls os.Exit(1).
+func Fatalf(format string, args ...interface{}) {
+	defaultLogger().skipLogger.Fatalf(format, args...)
+}
+
+// Fatalw logs a message with fields to the DefaultSubsystem logger at fatal
+// level, then calls os.Exit(1).
+func Fatalw(msg string, keysAndValues ...interface{}) {
+	defaultLogger().skipLogger.Fatalw(msg, keysAndValues...)
+}
@@ -0,0 +1,37 @@
+package log
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"go.uber.org/zap/zapcore"
+)
+
+func TestDefaultLogger(t *testing.T) {
+	defer SetupLogging(Config{Stderr: true})
+
+	buf := &bytes.Buffer{}
+	SetupLogging(Config{Level: LevelWarn})
+	SetPrimaryCore(newCore(JSONOutput, zapcore.AddSync(buf), LevelDebug, nil))
+
+	Infof("filtered %d", 1)
+	Warnw("kept", "n", 2)
+
+	var entry struct {
+		Logger  string `json:"logger"`
+		Message string `json:"msg"`
+		Caller  string `json:"caller"`
+		N       int    `json:"n"`
+	}
+	if err := json.Unmarshal(buf.Bytes(), &entry); err != nil {
+		t.Fatalf("%s: %q", err, buf.String())
+	}
+	if entry.Logger != DefaultSubsystem || entry.Message != "kept" || entry.N != 2 {
+		t.Errorf("got entry %+v", entry)
+	}
+	if !strings.Contains(entry.Caller, "default_test.go") {
+		t.Errorf("got caller %q, want the call site", entry.Caller)
+	}
+}
@@ -0,0 +1,38 @@
+//go:build golog_discard
+// +build golog_discard
+
+package log
+
+// Building with the golog_discard tag turns the debug and info methods of
+// ZapEventLogger into empty functions, which the compiler inlines away. This
+// is meant for builds where binary size and overhead matter, i.e. mobile and
+// WebAssembly. Loggers obtained otherwise, i.e. with Desugar, are not
+// affected.
+
+func (logger *ZapEventLogger) Debug(args ...interface{}) {}
+
+func (logger *ZapEventLogger) Debugf(format string, args ...interface{}) {}
+
+func (logger *ZapEventLogger) Debugw(msg string, keysAndValues ...interface{}) {}
+
+func (logger *ZapEventLogger) DebugID(id, msg string, keysAndValues ...interface{}) {}
+
+func (logger *ZapEventLogger) Info(args ...interface{}) {}
+
+func (logger *ZapEventLogger) Infof(format string, args ...interface{}) {}
+
+func (logger *ZapEventLogger) Infow(msg string, keysAndValues ...interface{}) {}
+
+func (logger *ZapEventLogger) InfoID(id, msg string, keysAndValues ...interface{}) {}
+
+func Debug(args ...interface{}) {}
+
+func Debugf(format string, args ...interface{}) {}
+
+func Debugw(msg string, keysAndValues ...interface{}) {}
+
+func Info(args ...interface{}) {}
+
+func Infof(format string, args ...interface{}) {}
+
+func Infow(msg string, keysAndValues ...interface{}) {}
@@ -0,0 +1,49 @@
+//go:build !golog_discard
+// +build !golog_discard
+
+package log
+
+// DebugID logs a message at debug level with a stable message ID, see InfoID.
+func (logger *ZapEventLogger) DebugID(id, msg string, keysAndValues ...interface{}) {
+	logger.skipLogger.Debugw(msg, withMessageID(id, keysAndValues)...)
+}
+
+// InfoID logs a message with a stable message ID, i.e. "dht.query.start", in
+// the MessageIDKey field. Unlike the wording of the message, the ID can be
+// relied on by translations, templates and alerts.
+func (logger *ZapEventLogger) InfoID(id, msg string, keysAndValues ...interface{}) {
+	logger.skipLogger.Infow(msg, withMessageID(id, keysAndValues)...)
+}
+
+// Debug logs to the DefaultSubsystem logger at debug level.
+func Debug(args ...interface{}) {
+	defaultLogger().skipLogger.Debug(args...)
+}
+
+// Debugf logs a formatted message to the DefaultSubsystem logger at debug
+// level.
+func Debugf(format string, args ...interface{}) {
+	defaultLogger().skipLogger.Debugf(format, args...)
+}
+
+// Debugw logs a message with fields to the DefaultSubsystem logger at debug
+// level.
+func Debugw(msg string, keysAndValues ...interface{}) {
+	defaultLogger().skipLogger.Debugw(msg, keysAndValues...)
+}
+
+// Info logs to the DefaultSubsystem logger at info level.
+func Info(args ...interface{}) {
+	defaultLogger().skipLogger.Info(args...)
+}
+
+// Infof logs a formatted message to the DefaultSubsystem logger at info level.
+func Infof(format string, args ...interface{}) {
+	defaultLogger().skipLogger.Infof(format, args...)
+}
+
+// Infow logs a message with fields to the DefaultSubsystem logger at info
+// level.
+func Infow(msg string, keysAndValues ...interface{}) {
+	defaultLogger().skipLogger.Infow(msg, keysAndValues...)
+}
@@ -0,0 +1,30 @@
+//go:build golog_discard
+// +build golog_discard
+
+package log
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"go.uber.org/zap/zapcore"
+)
+
+func TestDiscard(t *testing.T) {
+	defer SetupLogging(Config{Stderr: true})
+
+	buf := &bytes.Buffer{}
+	SetupLogging(Config{Level: LevelDebug})
+	SetPrimaryCore(newCore(PlaintextOutput, zapcore.AddSync(buf), LevelDebug, nil))
+
+	log := Logger("discard-test")
+	log.Debug("discarded")
+	log.Infof("discarded %d", 1)
+	log.InfoID("discard.test", "discarded")
+	log.Warn("kept")
+
+	if got := buf.String(); strings.Contains(got, "discarded") || !strings.Contains(got, "kept") {
+		t.Errorf("got %q", got)
+	}
+}
@@ -0,0 +1,246 @@
+package log
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"sync"
+	"time"
+
+	"go.uber.org/multierr"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// EmergencyConfig configures an emergency output for the time all outputs
+// of the primary core are failing, i.e. because the disk is full.
+type EmergencyConfig struct {
+	// Output is "stderr" or the path of a file that Error and higher
+	// entries are written to while all outputs are failing. Disabled when
+	// empty.
+	Output string
+
+	// Size, when greater than zero, preallocates the file Output as a ring
+	// of this many bytes, so that it can be written even when the disk is
+	// full. Once full, the oldest entries are overwritten. The end of the
+	// newest entry is marked by a NUL byte.
+	Size int64
+}
+
+// openEmergencyOutput opens the output of an emergency core.
+func openEmergencyOutput(cfg EmergencyConfig) (zapcore.WriteSyncer, stopper, error) {
+	if cfg.Output == "stderr" {
+		return zapcore.Lock(os.Stderr), nil, nil
+	}
+	path, err := normalizePath(cfg.Output)
+	if err != nil {
+		return nil, nil, err
+	}
+	if cfg.Size <= 0 {
+		ws, _, err := zap.Open(path)
+		return ws, nil, err
+	}
+	rf, err := openRingFile(path, cfg.Size)
+	if err != nil {
+		return nil, nil, err
+	}
+	return rf, rf, nil
+}
+
+var _ zapcore.WriteSyncer = (*ringFile)(nil)
+
+// ringFile is a preallocated file that is written round-robin.
+type ringFile struct {
+	mu   sync.Mutex
+	f    *os.File
+	size int64
+	pos  int64 // offset of the next write
+}
+
+func openRingFile(path string, size int64) (*ringFile, error) {
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0o644)
+	if err != nil {
+		return nil, err
+	}
+	r := &ringFile{f: f, size: size}
+	if err := r.init(); err != nil {
+		f.Close() // nolint:errcheck
+		return nil, err
+	}
+	return r, nil
+}
+
+// init preallocates the file, and continues after the newest entry of an
+// existing ring.
+func (r *ringFile) init() error {
+	data, err := ioutil.ReadAll(io.LimitReader(r.f, r.size))
+	if err != nil {
+		return err
+	}
+	if end := bytes.IndexByte(data, 0); end >= 0 {
+		r.pos = int64(end)
+	}
+	// write out the blocks instead of truncating, which would leave a
+	// sparse file.
+	zeros := make([]byte, 32<<10)
+	for off := int64(len(data)); off < r.size; off += int64(len(zeros)) {
+		n := r.size - off
+		if n > int64(len(zeros)) {
+			n = int64(len(zeros))
+		}
+		if _, err := r.f.WriteAt(zeros[:n], off); err != nil {
+			return err
+		}
+	}
+	return r.f.Sync()
+}
+
+func (r *ringFile) Write(p []byte) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	// keep room for the end marker.
+	if int64(len(p)) >= r.size {
+		p = p[:r.size-1]
+	}
+	if r.pos+int64(len(p)) >= r.size {
+		r.pos = 0
+	}
+	n, err := r.f.WriteAt(p, r.pos)
+	r.pos += int64(n)
+	if err != nil {
+		return n, err
+	}
+	_, err = r.f.WriteAt([]byte{0}, r.pos)
+	return n, err
+}
+
+func (r *ringFile) Sync() error {
+	return r.f.Sync()
+}
+
+func (r *ringFile) Stop() error {
+	return r.f.Close()
+}
+
+var _ zapcore.Core = (*emergencyCore)(nil)
+
+// emergencyCore writes Error and higher entries to an emergency output while
+// all outputs of the next core are failing. When an output recovers, an
+// entry recording the outage is written to the next core, with the number of
+// entries the outputs failed to take, and how many of them were rescued to
+// the emergency output.
+type emergencyCore struct {
+	next  zapcore.Core
+	enc   zapcore.Encoder
+	state *emergencyState
+}
+
+type emergencyState struct {
+	root  zapcore.Core // the next core without fields added by With
+	ws    zapcore.WriteSyncer
+	sinks []*sinkMonitor // outputs of the next core
+
+	mu      sync.Mutex
+	since   time.Time // start of the outage, zero if none
+	dropped int       // entries lost during the outage
+	rescued int       // entries written to the emergency output
+}
+
+func newEmergencyCore(next zapcore.Core, enc zapcore.Encoder, ws zapcore.WriteSyncer, sinks []*sinkMonitor) *emergencyCore {
+	return &emergencyCore{
+		next:  next,
+		enc:   enc,
+		state: &emergencyState{root: next, ws: ws, sinks: sinks},
+	}
+}
+
+func (c *emergencyCore) With(fields []zapcore.Field) zapcore.Core {
+	enc := c.enc.Clone()
+	for i := range fields {
+		fields[i].AddTo(enc)
+	}
+	return &emergencyCore{
+		next:  c.next.With(fields),
+		enc:   enc,
+		state: c.state,
+	}
+}
+
+func (c *emergencyCore) Enabled(lvl zapcore.Level) bool {
+	return c.next.Enabled(lvl)
+}
+
+func (c *emergencyCore) Check(ent zapcore.Entry, ce *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if c.next.Enabled(ent.Level) {
+		return ce.AddCore(ent, c)
+	}
+	return ce
+}
+
+func (c *emergencyCore) Write(ent zapcore.Entry, fields []zapcore.Field) error {
+	err := c.next.Write(ent, fields)
+	s := c.state
+	failing := s.failing()
+
+	s.mu.Lock()
+	if !failing {
+		if s.since.IsZero() {
+			s.mu.Unlock()
+			return err
+		}
+		since, dropped, rescued := s.since, s.dropped, s.rescued
+		s.since, s.dropped, s.rescued = time.Time{}, 0, 0
+		s.mu.Unlock()
+
+		s.root.Write(zapcore.Entry{ // nolint:errcheck
+			LoggerName: "log",
+			Level:      zapcore.WarnLevel,
+			Time:       time.Now(),
+			Message:    "log outputs recovered",
+		}, []zapcore.Field{
+			zap.Time("failingSince", since),
+			zap.Int("dropped", dropped),
+			zap.Int("rescued", rescued),
+		})
+		return err
+	}
+	defer s.mu.Unlock()
+
+	if s.since.IsZero() {
+		s.since = time.Now()
+	}
+	s.dropped++
+	if ent.Level < zapcore.ErrorLevel {
+		return err
+	}
+	buf, eerr := c.enc.EncodeEntry(ent, fields)
+	if eerr != nil {
+		return eerr
+	}
+	defer buf.Free()
+	if _, werr := s.ws.Write(buf.Bytes()); werr != nil {
+		return fmt.Errorf("all log outputs failed, emergency output too: %w", werr)
+	}
+	s.rescued++
+	return nil
+}
+
+func (c *emergencyCore) Sync() error {
+	return multierr.Append(c.next.Sync(), c.state.ws.Sync())
+}
+
+// failing reports whether all outputs failed their last write.
+func (s *emergencyState) failing() bool {
+	if len(s.sinks) == 0 {
+		return false
+	}
+	for _, m := range s.sinks {
+		if !m.failing() {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,110 @@
+package log
+
+import (
+	"bytes"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"go.uber.org/zap/zapcore"
+)
+
+// switchWriteSyncer fails writes while broken is set.
+type switchWriteSyncer struct {
+	bytes.Buffer
+	broken bool
+}
+
+func (w *switchWriteSyncer) Write(p []byte) (int, error) {
+	if w.broken {
+		return 0, errors.New("disk full")
+	}
+	return w.Buffer.Write(p)
+}
+
+func (w *switchWriteSyncer) Sync() error { return nil }
+
+func TestEmergencyCore(t *testing.T) {
+	defer resetSinkMonitors()
+
+	out := &switchWriteSyncer{}
+	m := monitorSink("out", out)
+	emergency := &bytes.Buffer{}
+	core := newEmergencyCore(
+		newCore(PlaintextOutput, m, LevelDebug, nil),
+		newEncoder(PlaintextOutput, nil),
+		zapcore.AddSync(emergency),
+		[]*sinkMonitor{m},
+	)
+	write := func(lvl zapcore.Level, msg string) {
+		if ce := core.Check(zapcore.Entry{Level: lvl, Message: msg}, nil); ce != nil {
+			ce.Write()
+		}
+	}
+
+	write(zapcore.ErrorLevel, "before")
+	out.broken = true
+	write(zapcore.ErrorLevel, "first")
+	write(zapcore.InfoLevel, "lost")
+	write(zapcore.ErrorLevel, "second")
+	out.broken = false
+	write(zapcore.InfoLevel, "after")
+
+	if got := emergency.String(); !strings.Contains(got, "first") || !strings.Contains(got, "second") || strings.Contains(got, "lost") || strings.Contains(got, "before") {
+		t.Errorf("emergency output got %q", got)
+	}
+	got := out.String()
+	for _, want := range []string{"before", "after", "log outputs recovered", `"dropped": 3`, `"rescued": 2`} {
+		if !strings.Contains(got, want) {
+			t.Errorf("missing %q in output %q", want, got)
+		}
+	}
+}
+
+func TestRingFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "go-log-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "emergency.log")
+
+	r, err := openRingFile(path, 16)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, entry := range []string{"one\n", "two\n"} {
+		if _, err := r.Write([]byte(entry)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := r.Stop(); err != nil {
+		t.Fatal(err)
+	}
+
+	// a reopened ring continues after the newest entry.
+	r, err = openRingFile(path, 16)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, entry := range []string{"three\n", "four\n"} {
+		if _, err := r.Write([]byte(entry)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := r.Stop(); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the last entry wrapped around.
+	if want := "four\n\x00o\nthree\n\x00\x00"; string(data) != want {
+		t.Errorf("got %q, want %q", data, want)
+	}
+}
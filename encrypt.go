@@ -0,0 +1,193 @@
+package log
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+
+	"go.uber.org/zap/zapcore"
+	"golang.org/x/crypto/nacl/box"
+)
+
+// Encrypted log streams start with a header made of encryptionMagic and an
+// ephemeral X25519 public key. Each write then produces a frame: the length of
+// the sealed data as a big-endian uint32, a 24 byte nonce, and the data sealed
+// with NaCl box. The nonce is the number of the frame in the stream, starting
+// at 0, as a big-endian integer; since the key is unique to the stream, nonces
+// are never reused, and readers detect dropped or reordered frames. A file may
+// contain several streams, one per time the file was opened for logging.
+var encryptionMagic = []byte("GLE1")
+
+const (
+	encryptionNonceSize = 24
+	// maxEncryptedFrame bounds the frame size. It is well below the value of
+	// encryptionMagic read as a length prefix, so that a new stream header can
+	// be told apart from a frame.
+	maxEncryptedFrame = 16 << 20
+)
+
+// ErrInvalidEncryptedLog is returned when reading a malformed or tampered
+// encrypted log.
+var ErrInvalidEncryptedLog = errors.New("invalid encrypted log")
+
+// GenerateEncryptionKey generates a key pair for encrypted logs. The public
+// key goes into Config.FileEncryptionKey, the private key is kept by whoever
+// is meant to read the logs.
+func GenerateEncryptionKey() (publicKey, privateKey *[32]byte, err error) {
+	return box.GenerateKey(rand.Reader)
+}
+
+var _ zapcore.WriteSyncer = (*encryptedWriteSyncer)(nil)
+
+// encryptedWriteSyncer seals everything written to it for a recipient.
+type encryptedWriteSyncer struct {
+	mu     sync.Mutex // guards writes to ws
+	ws     zapcore.WriteSyncer
+	shared [32]byte
+	header []byte // written before the first frame
+	frames uint64 // number of frames written
+}
+
+func newEncryptedWriteSyncer(ws zapcore.WriteSyncer, recipient *[32]byte) (*encryptedWriteSyncer, error) {
+	pub, priv, err := box.GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+
+	e := &encryptedWriteSyncer{
+		ws:     ws,
+		header: append(append([]byte{}, encryptionMagic...), pub[:]...),
+	}
+	box.Precompute(&e.shared, recipient, priv)
+	return e, nil
+}
+
+func (e *encryptedWriteSyncer) Write(p []byte) (int, error) {
+	if len(p) == 0 {
+		return 0, nil
+	}
+	if len(p)+box.Overhead > maxEncryptedFrame {
+		// split large writes into several frames.
+		n, err := e.Write(p[:maxEncryptedFrame-box.Overhead])
+		if err != nil {
+			return n, err
+		}
+		m, err := e.Write(p[n:])
+		return n + m, err
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	nonce := frameNonce(e.frames)
+	frame := make([]byte, 0, len(e.header)+4+encryptionNonceSize+len(p)+box.Overhead)
+	frame = append(frame, e.header...)
+	frame = append(frame, 0, 0, 0, 0)
+	binary.BigEndian.PutUint32(frame[len(frame)-4:], uint32(len(p)+box.Overhead))
+	frame = append(frame, nonce[:]...)
+	frame = box.SealAfterPrecomputation(frame, p, &nonce, &e.shared)
+
+	if _, err := e.ws.Write(frame); err != nil {
+		return 0, err
+	}
+	e.header = nil
+	e.frames++
+	return len(p), nil
+}
+
+// frameNonce returns the nonce of the frame with number n.
+func frameNonce(n uint64) [encryptionNonceSize]byte {
+	var nonce [encryptionNonceSize]byte
+	binary.BigEndian.PutUint64(nonce[encryptionNonceSize-8:], n)
+	return nonce
+}
+
+func (e *encryptedWriteSyncer) Sync() error {
+	return e.ws.Sync()
+}
+
+// NewDecryptingReader returns a reader yielding the plaintext of an encrypted
+// log read from r, using the recipient's private key. Tampered frames, frames
+// cut off in the middle, and dropped or reordered frames within a stream
+// result in ErrInvalidEncryptedLog. Streams have no end marker, since the
+// process may exit at any time, so frames removed from the end of a stream,
+// and removed or reordered streams, are not detected.
+func NewDecryptingReader(r io.Reader, privateKey *[32]byte) io.Reader {
+	return &decryptingReader{
+		r:    bufio.NewReader(r),
+		priv: privateKey,
+	}
+}
+
+type decryptingReader struct {
+	r       *bufio.Reader
+	priv    *[32]byte
+	shared  *[32]byte
+	frames  uint64 // number of frames read from the current stream
+	pending []byte
+	err     error
+}
+
+func (d *decryptingReader) Read(p []byte) (int, error) {
+	for len(d.pending) == 0 {
+		if d.err != nil {
+			return 0, d.err
+		}
+		d.pending, d.err = d.next()
+	}
+	n := copy(p, d.pending)
+	d.pending = d.pending[n:]
+	return n, nil
+}
+
+// next decrypts the next frame, reading stream headers along the way.
+func (d *decryptingReader) next() ([]byte, error) {
+	var prefix [4]byte
+	if _, err := io.ReadFull(d.r, prefix[:]); err != nil {
+		if err == io.EOF {
+			return nil, io.EOF
+		}
+		return nil, fmt.Errorf("%w: %s", ErrInvalidEncryptedLog, err)
+	}
+
+	if bytes.Equal(prefix[:], encryptionMagic) {
+		var peer [32]byte
+		if _, err := io.ReadFull(d.r, peer[:]); err != nil {
+			return nil, fmt.Errorf("%w: truncated header", ErrInvalidEncryptedLog)
+		}
+		d.shared = new([32]byte)
+		box.Precompute(d.shared, &peer, d.priv)
+		d.frames = 0
+		return nil, nil
+	}
+	if d.shared == nil {
+		return nil, fmt.Errorf("%w: missing header", ErrInvalidEncryptedLog)
+	}
+
+	size := binary.BigEndian.Uint32(prefix[:])
+	if size < box.Overhead || size > maxEncryptedFrame {
+		return nil, fmt.Errorf("%w: bad frame size %d", ErrInvalidEncryptedLog, size)
+	}
+	var nonce [encryptionNonceSize]byte
+	if _, err := io.ReadFull(d.r, nonce[:]); err != nil {
+		return nil, fmt.Errorf("%w: truncated frame", ErrInvalidEncryptedLog)
+	}
+	sealed := make([]byte, size)
+	if _, err := io.ReadFull(d.r, sealed); err != nil {
+		return nil, fmt.Errorf("%w: truncated frame", ErrInvalidEncryptedLog)
+	}
+	if nonce != frameNonce(d.frames) {
+		return nil, fmt.Errorf("%w: frame %d is missing or out of order", ErrInvalidEncryptedLog, d.frames)
+	}
+	plain, ok := box.OpenAfterPrecomputation(nil, sealed, &nonce, d.shared)
+	if !ok {
+		return nil, fmt.Errorf("%w: authentication failed", ErrInvalidEncryptedLog)
+	}
+	d.frames++
+	return plain, nil
+}
@@ -0,0 +1,141 @@
+package log
+
+import (
+	"bytes"
+	"errors"
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestEncryptedWriteSyncerRoundTrip(t *testing.T) {
+	pub, priv, err := GenerateEncryptionKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	buf := &syncBuffer{}
+	// two streams appended to the same output, as happens when a log file
+	// is reopened.
+	for _, msg := range []string{"scooby\n", "doo\n"} {
+		e, err := newEncryptedWriteSyncer(buf, pub)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := e.Write([]byte(msg)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if bytes.Contains(buf.Bytes(), []byte("scooby")) {
+		t.Fatal("output contains plaintext")
+	}
+
+	got, err := ioutil.ReadAll(NewDecryptingReader(bytes.NewReader(buf.Bytes()), priv))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "scooby\ndoo\n"; string(got) != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+func TestDecryptingReaderRejectsTampering(t *testing.T) {
+	pub, priv, err := GenerateEncryptionKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	buf := &syncBuffer{}
+	e, err := newEncryptedWriteSyncer(buf, pub)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := e.Write([]byte("scooby\n")); err != nil {
+		t.Fatal(err)
+	}
+
+	data := buf.Bytes()
+	data[len(data)-1] ^= 0xff
+
+	_, err = ioutil.ReadAll(NewDecryptingReader(bytes.NewReader(data), priv))
+	if !errors.Is(err, ErrInvalidEncryptedLog) {
+		t.Errorf("got error %v, want %v", err, ErrInvalidEncryptedLog)
+	}
+}
+
+func TestDecryptingReaderRejectsDroppedFrames(t *testing.T) {
+	pub, priv, err := GenerateEncryptionKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	buf := &syncBuffer{}
+	e, err := newEncryptedWriteSyncer(buf, pub)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, line := range []string{"a\n", "b\n", "c\n"} {
+		if _, err := e.Write([]byte(line)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	data := buf.Bytes()
+	header := len(encryptionMagic) + 32
+	frame := 4 + encryptionNonceSize + 2 + 16
+	frames := func(i ...int) []byte {
+		out := append([]byte(nil), data[:header]...)
+		for _, n := range i {
+			out = append(out, data[header+n*frame:header+(n+1)*frame]...)
+		}
+		return out
+	}
+
+	got, err := ioutil.ReadAll(NewDecryptingReader(bytes.NewReader(frames(0, 1, 2)), priv))
+	if err != nil || string(got) != "a\nb\nc\n" {
+		t.Fatalf("got %q, %v", got, err)
+	}
+	for _, order := range [][]int{{0, 2}, {1, 2}, {0, 2, 1}} {
+		_, err := ioutil.ReadAll(NewDecryptingReader(bytes.NewReader(frames(order...)), priv))
+		if !errors.Is(err, ErrInvalidEncryptedLog) {
+			t.Errorf("frames %v: got error %v, want %v", order, err, ErrInvalidEncryptedLog)
+		}
+	}
+}
+
+func TestLogToEncryptedFile(t *testing.T) {
+	pub, priv, err := GenerateEncryptionKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	logfile, err := ioutil.TempFile("", "go-log-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(logfile.Name())
+
+	SetupLogging(Config{
+		File:              logfile.Name(),
+		FileEncryptionKey: pub,
+	})
+	defer SetupLogging(Config{Stderr: true})
+
+	log := getLogger("test")
+	log.Error("grokgrokgrok")
+
+	f, err := os.Open(logfile.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	content, err := ioutil.ReadAll(NewDecryptingReader(f, priv))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(content), "grokgrokgrok") {
+		t.Errorf("got %q, wanted it to contain log output", content)
+	}
+}
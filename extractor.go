@@ -0,0 +1,64 @@
+package log
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+
+	"go.uber.org/zap/zapcore"
+)
+
+// ContextExtractor returns the fields to log for ctx, i.e. the ID of the
+// request ctx belongs to.
+type ContextExtractor func(ctx context.Context) []zapcore.Field
+
+var extractorsMu sync.Mutex // serializes registrations
+
+// contextExtractors holds the registered extractors as an immutable
+// []ContextExtractor.
+var contextExtractors atomic.Value
+
+// RegisterContextExtractor registers fn to be run by WithContext, so that
+// values carried by a context are logged without adding them at every call
+// site. Extractors run in the order they were registered.
+//
+// Extractors are called concurrently and must not log themselves.
+func RegisterContextExtractor(fn ContextExtractor) {
+	extractorsMu.Lock()
+	defer extractorsMu.Unlock()
+
+	old, _ := contextExtractors.Load().([]ContextExtractor)
+	extractors := make([]ContextExtractor, len(old), len(old)+1)
+	copy(extractors, old)
+	contextExtractors.Store(append(extractors, fn))
+}
+
+// WithContext returns a new logger that adds the fields of the registered
+// extractors for ctx to all entries, or l if there are none.
+func WithContext(l *ZapEventLogger, ctx context.Context) *ZapEventLogger {
+	extractors, _ := contextExtractors.Load().([]ContextExtractor)
+	var fields []interface{}
+	for _, fn := range extractors {
+		for _, f := range callContextExtractor(fn, ctx) {
+			fields = append(fields, f)
+		}
+	}
+	if len(fields) == 0 {
+		return l
+	}
+	copyLogger := *l
+	copyLogger.SugaredLogger = *copyLogger.SugaredLogger.With(fields...)
+	copyLogger.skipLogger = *copyLogger.skipLogger.With(fields...)
+	return &copyLogger
+}
+
+// callContextExtractor calls fn. When it panics, the panic is returned in an
+// error field.
+func callContextExtractor(fn ContextExtractor, ctx context.Context) (fields []zapcore.Field) {
+	defer func() {
+		if r := recover(); r != nil {
+			fields = []zapcore.Field{errorField("contextExtractor", panicError(r))}
+		}
+	}()
+	return fn(ctx)
+}
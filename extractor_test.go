@@ -0,0 +1,52 @@
+package log
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"testing"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+type requestIDKey struct{}
+
+func TestWithContext(t *testing.T) {
+	defer SetupLogging(Config{Stderr: true})
+	old, _ := contextExtractors.Load().([]ContextExtractor)
+	defer contextExtractors.Store(old)
+
+	buf := &bytes.Buffer{}
+	SetupLogging(Config{Level: LevelInfo})
+	SetPrimaryCore(newCore(JSONOutput, zapcore.AddSync(buf), LevelDebug, nil))
+
+	logger := Logger("extractor-test")
+	if l := WithContext(logger, context.Background()); l != logger {
+		t.Error("got a new logger without extractors")
+	}
+
+	RegisterContextExtractor(func(ctx context.Context) []zapcore.Field {
+		if id, ok := ctx.Value(requestIDKey{}).(string); ok {
+			return []zapcore.Field{zap.String("requestID", id)}
+		}
+		return nil
+	})
+	RegisterContextExtractor(func(ctx context.Context) []zapcore.Field {
+		panic("extractor")
+	})
+
+	ctx := context.WithValue(context.Background(), requestIDKey{}, "r1")
+	WithContext(logger, ctx).Warn("handling")
+
+	var entry map[string]interface{}
+	if err := json.Unmarshal(buf.Bytes(), &entry); err != nil {
+		t.Fatal(err)
+	}
+	if entry["requestID"] != "r1" {
+		t.Errorf("got request ID %v", entry["requestID"])
+	}
+	if entry["contextExtractorError"] != "PANIC=extractor" {
+		t.Errorf("got extractor error %v", entry["contextExtractorError"])
+	}
+}
@@ -0,0 +1,115 @@
+package log
+
+import (
+	"regexp"
+	"sync"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// FieldKeyConvention is a naming convention for field keys, see
+// Config.FieldKeyConvention.
+type FieldKeyConvention int
+
+const (
+	// AnyFieldKeys does not check field keys.
+	AnyFieldKeys FieldKeyConvention = iota
+	// SnakeCaseFieldKeys expects keys like "peer_id".
+	SnakeCaseFieldKeys
+	// CamelCaseFieldKeys expects keys like "peerID".
+	CamelCaseFieldKeys
+)
+
+var fieldKeyPatterns = map[FieldKeyConvention]*regexp.Regexp{
+	SnakeCaseFieldKeys: regexp.MustCompile(`^[a-z][a-z0-9]*(_[a-z0-9]+)*$`),
+	CamelCaseFieldKeys: regexp.MustCompile(`^[a-z][a-zA-Z0-9]*$`),
+}
+
+// reservedFieldKeys are the keys of the entry itself in encoded output, which
+// fields would collide with.
+var reservedFieldKeys = map[string]bool{
+	"ts":         true,
+	"time":       true,
+	"level":      true,
+	"logger":     true,
+	"caller":     true,
+	"msg":        true,
+	"stacktrace": true,
+}
+
+var _ zapcore.Core = (*fieldKeyCore)(nil)
+
+// fieldKeyCore warns about field keys that are reserved or don't match a
+// convention. Every key is reported once per subsystem, in a warning written
+// to the next core before the entry that has it. Entries of this package's
+// own "log" logger are not checked.
+type fieldKeyCore struct {
+	next    zapcore.Core
+	pattern *regexp.Regexp
+	bad     []string // keys of fields added with With that were not reported yet
+	warned  *sync.Map
+}
+
+func newFieldKeyCore(next zapcore.Core, convention FieldKeyConvention) *fieldKeyCore {
+	return &fieldKeyCore{next: next, pattern: fieldKeyPatterns[convention], warned: &sync.Map{}}
+}
+
+func (c *fieldKeyCore) With(fields []zapcore.Field) zapcore.Core {
+	return &fieldKeyCore{
+		next:    c.next.With(fields),
+		pattern: c.pattern,
+		bad:     c.badKeys(c.bad[:len(c.bad):len(c.bad)], fields),
+		warned:  c.warned,
+	}
+}
+
+func (c *fieldKeyCore) Enabled(lvl zapcore.Level) bool {
+	return c.next.Enabled(lvl)
+}
+
+func (c *fieldKeyCore) Check(ent zapcore.Entry, ce *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if c.next.Enabled(ent.Level) {
+		return ce.AddCore(ent, c)
+	}
+	return ce
+}
+
+func (c *fieldKeyCore) Write(ent zapcore.Entry, fields []zapcore.Field) error {
+	if ent.LoggerName != "log" {
+		for _, key := range c.badKeys(c.bad[:len(c.bad):len(c.bad)], fields) {
+			if _, warned := c.warned.LoadOrStore(ent.LoggerName+"\x00"+key, true); warned {
+				continue
+			}
+			warning := zapcore.Entry{
+				Level:      zapcore.WarnLevel,
+				Time:       ent.Time,
+				LoggerName: ent.LoggerName,
+				Caller:     ent.Caller,
+				Message:    "field key does not match the naming convention",
+			}
+			if ce := c.next.Check(warning, nil); ce != nil {
+				ce.Write(zap.String("key", key))
+			}
+		}
+	}
+	return writeChecked(c.next, ent, fields)
+}
+
+func (c *fieldKeyCore) Sync() error {
+	return c.next.Sync()
+}
+
+// badKeys appends the keys of fields that are reserved or don't match the
+// convention to bad.
+func (c *fieldKeyCore) badKeys(bad []string, fields []zapcore.Field) []string {
+	for _, f := range fields {
+		if f.Type == zapcore.SkipType {
+			continue
+		}
+		if reservedFieldKeys[f.Key] || (c.pattern != nil && !c.pattern.MatchString(f.Key)) {
+			bad = append(bad, f.Key)
+		}
+	}
+	return bad
+}
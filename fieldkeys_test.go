@@ -0,0 +1,33 @@
+package log
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+func TestFieldKeyConvention(t *testing.T) {
+	buf := &bytes.Buffer{}
+	core := newFieldKeyCore(newCore(PlaintextOutput, zapcore.AddSync(buf), LevelDebug, nil), SnakeCaseFieldKeys)
+	logger := zap.New(core).Sugar().Named("keys-test").With("peerID", "p")
+
+	logger.Infow("first", "peer_id", "p", "level", 1, "bad key", 2)
+	logger.Infow("second", "level", 1)
+
+	var warned []string
+	for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+		if strings.Contains(line, "naming convention") {
+			warned = append(warned, line[strings.Index(line, "{"):])
+		}
+	}
+	want := []string{`{"peerID": "p", "key": "peerID"}`, `{"peerID": "p", "key": "level"}`, `{"peerID": "p", "key": "bad key"}`}
+	if strings.Join(warned, "\n") != strings.Join(want, "\n") {
+		t.Errorf("got warnings\n%s\nwant\n%s", strings.Join(warned, "\n"), strings.Join(want, "\n"))
+	}
+	if !strings.Contains(buf.String(), "second") {
+		t.Error("entry with a bad key was not written")
+	}
+}
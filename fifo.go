@@ -0,0 +1,85 @@
+package log
+
+import (
+	"errors"
+	"os"
+	"sync"
+	"time"
+
+	"go.uber.org/zap/zapcore"
+)
+
+// fifoRetryInterval is how long entries are discarded after opening a named
+// pipe failed for want of a reader, before it is opened again. A variable for
+// testing.
+var fifoRetryInterval = time.Second
+
+// errNoReader is returned by openFIFO when no process reads the pipe.
+var errNoReader = errors.New("no reader attached to the named pipe")
+
+// fifoWriteSyncer writes to a named pipe that readers attach to on demand,
+// i.e. with "cat < node.log". Opening a pipe for writing blocks until there
+// is a reader, so the pipe is opened without blocking when the first entry
+// is written, and again when the reader detaches. Entries written while
+// there is no reader are discarded.
+type fifoWriteSyncer struct {
+	path string
+
+	mu       sync.Mutex
+	f        *os.File
+	nextOpen time.Time
+}
+
+var _ zapcore.WriteSyncer = (*fifoWriteSyncer)(nil)
+
+func newFIFOWriteSyncer(path string) *fifoWriteSyncer {
+	return &fifoWriteSyncer{path: path}
+}
+
+func (w *fifoWriteSyncer) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.f == nil {
+		now := time.Now()
+		if now.Before(w.nextOpen) {
+			return len(p), nil
+		}
+		f, err := openFIFO(w.path)
+		if errors.Is(err, errNoReader) {
+			w.nextOpen = now.Add(fifoRetryInterval)
+			return len(p), nil
+		} else if err != nil {
+			return 0, err
+		}
+		w.f = f
+	}
+
+	n, err := w.f.Write(p)
+	if err != nil {
+		// the reader detached, the entry is lost like those written before
+		// the next reader attaches.
+		w.f.Close() // nolint:errcheck
+		w.f = nil
+		w.nextOpen = time.Now().Add(fifoRetryInterval)
+		return len(p), nil
+	}
+	return n, nil
+}
+
+// Sync is a no-op, pipes are not buffered by the writer.
+func (w *fifoWriteSyncer) Sync() error {
+	return nil
+}
+
+// Stop closes the pipe.
+func (w *fifoWriteSyncer) Stop() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.f == nil {
+		return nil
+	}
+	err := w.f.Close()
+	w.f = nil
+	return err
+}
@@ -0,0 +1,16 @@
+//go:build !aix && !darwin && !dragonfly && !freebsd && !illumos && !linux && !netbsd && !openbsd && !solaris
+// +build !aix,!darwin,!dragonfly,!freebsd,!illumos,!linux,!netbsd,!openbsd,!solaris
+
+package log
+
+import "os"
+
+// isFIFO reports whether path is a named pipe. Named pipes are only
+// supported on unix.
+func isFIFO(path string) bool {
+	return false
+}
+
+func openFIFO(path string) (*os.File, error) {
+	return os.OpenFile(path, os.O_WRONLY, 0)
+}
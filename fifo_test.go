@@ -0,0 +1,77 @@
+//go:build aix || darwin || dragonfly || freebsd || illumos || linux || netbsd || openbsd || solaris
+// +build aix darwin dragonfly freebsd illumos linux netbsd openbsd solaris
+
+package log
+
+import (
+	"bufio"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestLogToFIFO(t *testing.T) {
+	defer func(d time.Duration) { fifoRetryInterval = d }(fifoRetryInterval)
+	fifoRetryInterval = 0
+
+	dir, err := ioutil.TempDir("", "go-log-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "fifo")
+	if err := syscall.Mkfifo(path, 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	// neither setting up nor logging blocks without a reader.
+	os.Setenv(envLoggingFile, path)
+	defer os.Unsetenv(envLoggingFile)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		SetupLogging(configFromEnv())
+		getLogger("test").Error("unread")
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("logging to a named pipe without reader blocked")
+	}
+	defer SetupLogging(Config{Stderr: true})
+
+	// a reader attaching later gets the following entries.
+	r, err := os.OpenFile(path, os.O_RDONLY|syscall.O_NONBLOCK, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	getLogger("test").Error("grokgrokgrok")
+	line, err := bufio.NewReader(r).ReadString('\n')
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(line, "grokgrokgrok") {
+		t.Errorf("got %q", line)
+	}
+
+	// so does a reader attaching after the first one detached.
+	r.Close()
+	getLogger("test").Error("lost")
+	r, err = os.OpenFile(path, os.O_RDONLY|syscall.O_NONBLOCK, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	getLogger("test").Error("again")
+	line, err = bufio.NewReader(r).ReadString('\n')
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(line, "again") {
+		t.Errorf("got %q", line)
+	}
+}
@@ -0,0 +1,26 @@
+//go:build aix || darwin || dragonfly || freebsd || illumos || linux || netbsd || openbsd || solaris
+// +build aix darwin dragonfly freebsd illumos linux netbsd openbsd solaris
+
+package log
+
+import (
+	"errors"
+	"os"
+	"syscall"
+)
+
+// isFIFO reports whether path is a named pipe.
+func isFIFO(path string) bool {
+	fi, err := os.Stat(path)
+	return err == nil && fi.Mode()&os.ModeNamedPipe != 0
+}
+
+// openFIFO opens a named pipe for writing without blocking, returning
+// errNoReader when there is no reader.
+func openFIFO(path string) (*os.File, error) {
+	f, err := os.OpenFile(path, os.O_WRONLY|syscall.O_NONBLOCK, 0)
+	if errors.Is(err, syscall.ENXIO) {
+		return nil, errNoReader
+	}
+	return f, err
+}
@@ -0,0 +1,94 @@
+package log
+
+import (
+	"fmt"
+	"sync"
+	"sync/atomic"
+
+	"go.uber.org/zap/zapcore"
+)
+
+// Names of the outputs in Config.OutputFormats, as in GOLOG_OUTPUT.
+const (
+	StderrOutputName = "stderr"
+	StdoutOutputName = "stdout"
+	FileOutputName   = "file"
+	URLOutputName    = "url"
+)
+
+// firstCustomFormat is the LogFormat of the first format registered with
+// RegisterFormat, leaving room for further built-in formats.
+const firstCustomFormat LogFormat = 100
+
+// EncoderConstructor returns an encoder for a format registered with
+// RegisterFormat. cfg is the configuration of the JSON format, i.e. its keys
+// and its time encoder.
+type EncoderConstructor func(cfg zapcore.EncoderConfig) zapcore.Encoder
+
+type customFormat struct {
+	name       string
+	newEncoder EncoderConstructor
+}
+
+var formatsMu sync.Mutex // serializes registrations
+
+// customFormats holds the registered formats as an immutable []customFormat,
+// indexed by their LogFormat minus firstCustomFormat.
+var customFormats atomic.Value
+
+// RegisterFormat registers an encoder, i.e. for CBOR, under name and returns
+// the LogFormat to select it in Config.Format, Config.OutputFormats and
+// Route.Format. The name can be used in GOLOG_LOG_FMT, once the format is
+// registered. Names must be unique and not clash with the built-in formats.
+func RegisterFormat(name string, newEncoder EncoderConstructor) (LogFormat, error) {
+	formatsMu.Lock()
+	defer formatsMu.Unlock()
+
+	if _, ok := formatFromName(name); ok || name == "" {
+		return 0, fmt.Errorf("log format %q is already registered", name)
+	}
+	old, _ := customFormats.Load().([]customFormat)
+	formats := make([]customFormat, len(old), len(old)+1)
+	copy(formats, old)
+	customFormats.Store(append(formats, customFormat{name: name, newEncoder: newEncoder}))
+	return firstCustomFormat + LogFormat(len(old)), nil
+}
+
+// lookupCustomFormat returns the registered format of format.
+func lookupCustomFormat(format LogFormat) (customFormat, bool) {
+	formats, _ := customFormats.Load().([]customFormat)
+	i := int(format - firstCustomFormat)
+	if i < 0 || i >= len(formats) {
+		return customFormat{}, false
+	}
+	return formats[i], true
+}
+
+// formatFromName returns the format of a name as used in GOLOG_LOG_FMT.
+func formatFromName(name string) (LogFormat, bool) {
+	switch name {
+	case "color":
+		return ColorizedOutput, true
+	case "nocolor":
+		return PlaintextOutput, true
+	case "json":
+		return JSONOutput, true
+	case "grep":
+		return GrepOutput, true
+	}
+	formats, _ := customFormats.Load().([]customFormat)
+	for i, f := range formats {
+		if f.name == name {
+			return firstCustomFormat + LogFormat(i), true
+		}
+	}
+	return 0, false
+}
+
+// outputFormat returns the format of an output, given its name.
+func outputFormat(cfg Config, name string) LogFormat {
+	if format, ok := cfg.OutputFormats[name]; ok {
+		return format
+	}
+	return cfg.Format
+}
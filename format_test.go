@@ -0,0 +1,103 @@
+package log
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sync"
+	"testing"
+
+	"go.uber.org/zap/zapcore"
+)
+
+var (
+	testFormatOnce sync.Once
+	testFormat     LogFormat
+)
+
+// registerTestFormat registers JSON with the message in the "message" key.
+func registerTestFormat(t *testing.T) LogFormat {
+	testFormatOnce.Do(func() {
+		var err error
+		testFormat, err = RegisterFormat("test-message", func(cfg zapcore.EncoderConfig) zapcore.Encoder {
+			cfg.MessageKey = "message"
+			return zapcore.NewJSONEncoder(cfg)
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	})
+	return testFormat
+}
+
+func TestOutputFormats(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("file URLs differ on windows")
+	}
+	format := registerTestFormat(t)
+	dir, err := ioutil.TempDir("", "go-log-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	file, urlFile := filepath.Join(dir, "file.log"), filepath.Join(dir, "url.log")
+
+	SetupLogging(Config{
+		Format:        PlaintextOutput,
+		Level:         LevelInfo,
+		File:          file,
+		URL:           "file://" + urlFile,
+		OutputFormats: map[string]LogFormat{FileOutputName: JSONOutput, URLOutputName: format},
+	})
+	defer SetupLogging(Config{Stderr: true})
+
+	getLogger("format-test").Infow("hello", "k", "v")
+
+	var entry map[string]interface{}
+	content, err := ioutil.ReadFile(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := json.Unmarshal(content, &entry); err != nil || entry["msg"] != "hello" || entry["k"] != "v" {
+		t.Errorf("got %q in the file: %v", content, err)
+	}
+	content, err = ioutil.ReadFile(urlFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	entry = nil
+	if err := json.Unmarshal(content, &entry); err != nil || entry["message"] != "hello" || entry["k"] != "v" {
+		t.Errorf("got %q in the URL output: %v", content, err)
+	}
+}
+
+func TestRegisterFormat(t *testing.T) {
+	format := registerTestFormat(t)
+	if _, err := RegisterFormat("test-message", nil); err == nil {
+		t.Error("registered a format twice")
+	}
+	if _, err := RegisterFormat("json", nil); err == nil {
+		t.Error("registered a built-in format")
+	}
+	if f, ok := formatFromName("test-message"); !ok || f != format {
+		t.Errorf("got format %d for the registered name", f)
+	}
+	if name := formatName(format); name != "test-message" {
+		t.Errorf("got name %q", name)
+	}
+}
+
+func TestOutputFormatsFromEnv(t *testing.T) {
+	os.Setenv(envLoggingFmt, "nocolor,file=json,url=bogus")
+	defer os.Unsetenv(envLoggingFmt)
+
+	cfg := configFromEnv()
+	if cfg.Format != PlaintextOutput {
+		t.Errorf("got format %d", cfg.Format)
+	}
+	if len(cfg.OutputFormats) != 1 || cfg.OutputFormats[FileOutputName] != JSONOutput {
+		t.Errorf("got output formats %v", cfg.OutputFormats)
+	}
+}
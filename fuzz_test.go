@@ -0,0 +1,66 @@
+//go:build go1.18
+// +build go1.18
+
+package log
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// hostileFields are field values known to be troublesome for encoders.
+func hostileFields(s string, f float64, n int) []zapcore.Field {
+	type node struct {
+		Next *node
+	}
+	cycle := &node{}
+	cycle.Next = cycle
+
+	nested := map[string]interface{}{}
+	for i, m := 0, nested; i < n%2000; i++ {
+		child := map[string]interface{}{}
+		m["a"] = child
+		m = child
+	}
+
+	return []zapcore.Field{
+		zap.String(s, s),
+		zap.ByteString("bytes", []byte(s)),
+		zap.Binary("binary", []byte(s)),
+		zap.Float64("float", f),
+		zap.Float64("nan", math.NaN()),
+		zap.Float64("inf", math.Inf(-1)),
+		zap.Duration("duration", time.Duration(n)),
+		zap.Any("cycle", cycle),
+		zap.Any("nested", nested),
+		Bytes("size", int64(n)),
+		BytesPerSecond("rate", f),
+	}
+}
+
+func FuzzEncoders(f *testing.F) {
+	f.Add("msg", 1.5, 10)
+	f.Add("\xff\xfe\t\n\x1b[31m", math.MaxFloat64, 5000)
+	f.Add("", -0.0, -1)
+
+	f.Fuzz(func(t *testing.T, s string, x float64, n int) {
+		ent := zapcore.Entry{Level: zapcore.InfoLevel, LoggerName: s, Message: s}
+		style := &ConsoleStyle{LevelLabels: map[LogLevel]string{LevelInfo: s}}
+		for _, enc := range []zapcore.Encoder{
+			newEncoder(ColorizedOutput, nil),
+			newEncoder(PlaintextOutput, nil),
+			newEncoder(JSONOutput, nil),
+			newStyledEncoder(ColorizedOutput, nil, style),
+		} {
+			buf, err := enc.EncodeEntry(ent, hostileFields(s, x, n))
+			if err != nil {
+				t.Fatal(err)
+			}
+			buf.Free()
+		}
+	})
+}
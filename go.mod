@@ -4,11 +4,12 @@ require (
 	github.com/mattn/go-isatty v0.0.14
 	go.uber.org/multierr v1.6.0
 	go.uber.org/zap v1.19.1
+	golang.org/x/crypto v0.14.0
 )
 
 require (
 	go.uber.org/atomic v1.7.0 // indirect
-	golang.org/x/sys v0.0.0-20210630005230-0f9fa26af87c // indirect
+	golang.org/x/sys v0.13.0 // indirect
 )
 
 go 1.17
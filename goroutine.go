@@ -0,0 +1,59 @@
+package log
+
+import (
+	"runtime"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+const (
+	// GoroutineKey is the field key of the ID of the goroutine that logged
+	// an entry, see Config.GoroutineID.
+	GoroutineKey = "goroutine"
+	// GoroutinesKey is the field key of the number of goroutines, see
+	// Config.GoroutineCount.
+	GoroutinesKey = "goroutines"
+)
+
+var _ zapcore.Core = (*goroutineCore)(nil)
+
+// goroutineCore adds the ID of the logging goroutine and optionally the
+// number of goroutines to entries. Entries are written on the logging
+// goroutine, so the ID is taken in Write.
+type goroutineCore struct {
+	next  zapcore.Core
+	id    bool
+	count bool
+}
+
+func (c *goroutineCore) With(fields []zapcore.Field) zapcore.Core {
+	return &goroutineCore{next: c.next.With(fields), id: c.id, count: c.count}
+}
+
+func (c *goroutineCore) Enabled(lvl zapcore.Level) bool {
+	return c.next.Enabled(lvl)
+}
+
+func (c *goroutineCore) Check(ent zapcore.Entry, ce *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if c.next.Enabled(ent.Level) {
+		return ce.AddCore(ent, c)
+	}
+	return ce
+}
+
+func (c *goroutineCore) Write(ent zapcore.Entry, fields []zapcore.Field) error {
+	tagged := make([]zapcore.Field, 0, len(fields)+2)
+	if c.id {
+		tagged = append(tagged, zap.Uint64(GoroutineKey, goroutineID()))
+	}
+	if c.count {
+		tagged = append(tagged, zap.Int(GoroutinesKey, runtime.NumGoroutine()))
+	}
+	tagged = append(tagged, fields...)
+	return writeChecked(c.next, ent, tagged)
+}
+
+func (c *goroutineCore) Sync() error {
+	return c.next.Sync()
+}
@@ -0,0 +1,37 @@
+package log
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+func TestGoroutineCore(t *testing.T) {
+	buf := &bytes.Buffer{}
+	core := &goroutineCore{next: newCore(JSONOutput, zapcore.AddSync(buf), LevelDebug, nil), id: true, count: true}
+	zap.New(core).Info("entry")
+
+	var entry struct {
+		Goroutine  uint64 `json:"goroutine"`
+		Goroutines int    `json:"goroutines"`
+	}
+	if err := json.Unmarshal(buf.Bytes(), &entry); err != nil {
+		t.Fatal(err)
+	}
+	if entry.Goroutine != goroutineID() {
+		t.Errorf("got goroutine %d, want %d", entry.Goroutine, goroutineID())
+	}
+	if entry.Goroutines < 1 {
+		t.Errorf("got %d goroutines", entry.Goroutines)
+	}
+}
+
+func TestGoroutineCoreWriteError(t *testing.T) {
+	core := &goroutineCore{next: newCore(JSONOutput, failingWriteSyncer{}, LevelDebug, nil), id: true}
+	if err := core.Write(zapcore.Entry{Level: zapcore.InfoLevel}, nil); err == nil {
+		t.Error("expected the write error of the output")
+	}
+}
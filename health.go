@@ -0,0 +1,197 @@
+package log
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"go.uber.org/zap/zapcore"
+)
+
+// SinkStatus reports the state of a log output.
+type SinkStatus struct {
+	// Name is the output as configured, i.e. "stderr" or a file path.
+	Name string
+
+	// LastWrite is the time of the last successful write.
+	LastWrite time.Time
+
+	// LastError is the last write or sync error, and LastErrorTime the time
+	// it occurred.
+	LastError     error
+	LastErrorTime time.Time
+
+	// Writes is the number of successful writes, Dropped the number of
+	// failed ones whose data was lost.
+	Writes  uint64
+	Dropped uint64
+
+	// Queued is the number of bytes buffered for the output but not yet
+	// written.
+	Queued int
+}
+
+// queuer is implemented by output wrappers that buffer data.
+type queuer interface {
+	queued() int
+}
+
+var healthMu sync.Mutex // guards sinkMonitors
+var sinkMonitors []*sinkMonitor
+
+// Health returns the status of the outputs set up by SetupLogging, so that
+// stalled or failing outputs can be detected.
+func Health() []SinkStatus {
+	healthMu.Lock()
+	monitors := sinkMonitors
+	healthMu.Unlock()
+
+	statuses := make([]SinkStatus, 0, len(monitors))
+	for _, m := range monitors {
+		statuses = append(statuses, m.status())
+	}
+	return statuses
+}
+
+// monitorSink returns ws wrapped to record its status under name, and
+// registers it with Health.
+func monitorSink(name string, ws zapcore.WriteSyncer) *sinkMonitor {
+	m := &sinkMonitor{ws: ws, st: SinkStatus{Name: name}}
+
+	healthMu.Lock()
+	sinkMonitors = append(sinkMonitors, m)
+	healthMu.Unlock()
+	return m
+}
+
+// registeredSinks returns the outputs registered so far.
+func registeredSinks() []*sinkMonitor {
+	healthMu.Lock()
+	defer healthMu.Unlock()
+	return append([]*sinkMonitor(nil), sinkMonitors...)
+}
+
+// resetSinkMonitors unregisters all outputs from Health.
+func resetSinkMonitors() {
+	healthMu.Lock()
+	sinkMonitors = nil
+	healthMu.Unlock()
+}
+
+// WriteErrorHandler is called with the name of an output, as in SinkStatus,
+// and the error of a failed write or sync.
+type WriteErrorHandler func(sink string, err error)
+
+// writeErrorHandler holds the active WriteErrorHandler, nil when none is set.
+var writeErrorHandler atomic.Value
+
+// OnWriteError installs a function that is called for every failed write or
+// sync of an output set up by SetupLogging, i.e. to raise an alert or switch
+// outputs once an output keeps failing. A nil function removes it.
+//
+// The function is called synchronously by the logging goroutine. Entries it
+// logs are written to stderr instead of the failing outputs.
+func OnWriteError(fn WriteErrorHandler) {
+	writeErrorHandler.Store(fn)
+}
+
+// reportWriteError calls the WriteErrorHandler, if any. A panic of the
+// handler is reported to stderr.
+func reportWriteError(sink string, err error) {
+	fn, _ := writeErrorHandler.Load().(WriteErrorHandler)
+	if fn == nil {
+		return
+	}
+	defer func() {
+		if r := recover(); r != nil {
+			fmt.Fprintf(os.Stderr, "log write error handler: %s\n", panicError(r))
+		}
+	}()
+	fn(sink, err)
+}
+
+var _ zapcore.WriteSyncer = (*sinkMonitor)(nil)
+
+// sinkMonitor records the status of an output.
+type sinkMonitor struct {
+	ws    zapcore.WriteSyncer
+	queue queuer // buffer in front of the output, may be nil
+
+	mu     sync.Mutex
+	st     SinkStatus
+	failed bool // whether the last write failed
+}
+
+func (m *sinkMonitor) Write(p []byte) (int, error) {
+	n, err := m.ws.Write(p)
+
+	m.mu.Lock()
+	m.failed = err != nil
+	if err != nil {
+		m.st.Dropped++
+		m.fail(err)
+	} else {
+		m.st.Writes++
+		m.st.LastWrite = time.Now()
+	}
+	m.mu.Unlock()
+
+	if err != nil {
+		reportWriteError(m.st.Name, err)
+	}
+	return n, err
+}
+
+func (m *sinkMonitor) Sync() error {
+	err := m.ws.Sync()
+	if err != nil {
+		m.mu.Lock()
+		m.fail(err)
+		m.mu.Unlock()
+		reportWriteError(m.st.Name, err)
+	}
+	return err
+}
+
+// fail records an error. m.mu must be held.
+func (m *sinkMonitor) fail(err error) {
+	m.st.LastError = err
+	m.st.LastErrorTime = time.Now()
+}
+
+// backgroundError records an error of a wrapper in front of the output that
+// happened outside of a write, e.g. of a background flush. Errors of the
+// output itself were already recorded by Write and Sync.
+func (m *sinkMonitor) backgroundError(err error) {
+	m.mu.Lock()
+	seen := m.st.LastError != nil && errors.Is(err, m.st.LastError)
+	if !seen {
+		m.fail(err)
+	}
+	m.mu.Unlock()
+
+	if !seen {
+		reportWriteError(m.st.Name, err)
+	}
+}
+
+// failing reports whether the last write to the output failed.
+func (m *sinkMonitor) failing() bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.failed
+}
+
+func (m *sinkMonitor) status() SinkStatus {
+	m.mu.Lock()
+	st := m.st
+	m.mu.Unlock()
+
+	if m.queue != nil {
+		st.Queued = m.queue.queued()
+	}
+	return st
+}
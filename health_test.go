@@ -0,0 +1,94 @@
+package log
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"testing"
+)
+
+type failingWriteSyncer struct{}
+
+func (failingWriteSyncer) Write([]byte) (int, error) { return 0, errors.New("disk full") }
+func (failingWriteSyncer) Sync() error               { return nil }
+
+func TestSinkMonitor(t *testing.T) {
+	defer resetSinkMonitors()
+	resetSinkMonitors()
+
+	m := monitorSink("failing", failingWriteSyncer{})
+	if _, err := m.Write([]byte("entry\n")); err == nil {
+		t.Fatal("expected write error")
+	}
+
+	health := Health()
+	if len(health) != 1 {
+		t.Fatalf("got %d sinks, want 1", len(health))
+	}
+	st := health[0]
+	if st.Name != "failing" || st.Dropped != 1 || st.Writes != 0 {
+		t.Errorf("got status %+v", st)
+	}
+	if st.LastError == nil || st.LastErrorTime.IsZero() || !st.LastWrite.IsZero() {
+		t.Errorf("got status %+v", st)
+	}
+}
+
+func TestHealth(t *testing.T) {
+	f, err := ioutil.TempFile("", "go-log-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(f.Name())
+
+	SetupLogging(Config{
+		Level:      LevelInfo,
+		File:       f.Name(),
+		FileBuffer: BufferConfig{Size: 1 << 10},
+	})
+	defer SetupLogging(Config{Stderr: true})
+
+	getLogger("health-test").Info("buffered")
+
+	health := Health()
+	if len(health) != 1 || health[0].Name != f.Name() {
+		t.Fatalf("got %+v, want the log file", health)
+	}
+	if health[0].Queued == 0 || health[0].Writes != 0 {
+		t.Errorf("got %+v, want a buffered entry", health[0])
+	}
+
+	if err := getLogger("health-test").Sync(); err != nil {
+		t.Fatal(err)
+	}
+	health = Health()
+	if health[0].Queued != 0 || health[0].Writes != 1 || health[0].LastWrite.IsZero() {
+		t.Errorf("got %+v, want a written entry", health[0])
+	}
+}
+
+func TestOnWriteError(t *testing.T) {
+	defer resetSinkMonitors()
+	defer OnWriteError(nil)
+
+	var sinks []string
+	OnWriteError(func(sink string, err error) {
+		if err == nil {
+			t.Error("called without an error")
+		}
+		sinks = append(sinks, sink)
+	})
+
+	m := monitorSink("failing", failingWriteSyncer{})
+	m.Write([]byte("entry\n"))
+	m.Sync()
+	if len(sinks) != 1 || sinks[0] != "failing" {
+		t.Errorf("got reports for %v, want one for the failing sink", sinks)
+	}
+
+	OnWriteError(nil)
+	m.Write([]byte("entry\n"))
+	if len(sinks) != 1 {
+		t.Error("called after removing the handler")
+	}
+}
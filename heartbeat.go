@@ -0,0 +1,176 @@
+package log
+
+import (
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// heartbeatMessage is the message of heartbeat entries.
+const heartbeatMessage = "heartbeat"
+
+// HeartbeatConfig configures periodic heartbeat entries, which tell a quiet
+// process apart from a wedged one.
+type HeartbeatConfig struct {
+	// Interval is the time between heartbeats. Every subsystem with info
+	// level enabled logs a heartbeat entry with the number of entries it
+	// logged since the last one, by level. Disabled when zero.
+	Interval time.Duration
+}
+
+// levelCounts counts entries by level.
+type levelCounts [zapcore.FatalLevel - zapcore.DebugLevel + 1]uint64
+
+func (c *levelCounts) MarshalLogObject(enc zapcore.ObjectEncoder) error {
+	for i, n := range c {
+		if n > 0 {
+			enc.AddUint64((zapcore.DebugLevel + zapcore.Level(i)).String(), n)
+		}
+	}
+	return nil
+}
+
+var _ zapcore.Core = (*countingCore)(nil)
+
+// countingCore counts the entries written by logger name.
+type countingCore struct {
+	mu     sync.Mutex
+	counts map[string]*levelCounts
+}
+
+func newCountingCore() *countingCore {
+	return &countingCore{counts: make(map[string]*levelCounts)}
+}
+
+func (c *countingCore) With([]zapcore.Field) zapcore.Core {
+	return c
+}
+
+func (c *countingCore) Enabled(zapcore.Level) bool {
+	return true
+}
+
+func (c *countingCore) Check(ent zapcore.Entry, ce *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	return ce.AddCore(ent, c)
+}
+
+func (c *countingCore) Write(ent zapcore.Entry, _ []zapcore.Field) error {
+	if ent.Level < zapcore.DebugLevel || ent.Level > zapcore.FatalLevel {
+		return nil
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	counts, ok := c.counts[ent.LoggerName]
+	if !ok {
+		counts = new(levelCounts)
+		c.counts[ent.LoggerName] = counts
+	}
+	counts[ent.Level-zapcore.DebugLevel]++
+	return nil
+}
+
+func (c *countingCore) Sync() error {
+	return nil
+}
+
+// reset returns the counts so far and starts over.
+func (c *countingCore) reset() map[string]*levelCounts {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	counts := c.counts
+	c.counts = make(map[string]*levelCounts)
+	return counts
+}
+
+// heartbeat logs heartbeat entries at an interval.
+type heartbeat struct {
+	counter *countingCore
+	stop    chan struct{}
+}
+
+func newHeartbeat(counter *countingCore, interval time.Duration) *heartbeat {
+	h := &heartbeat{
+		counter: counter,
+		stop:    make(chan struct{}),
+	}
+	go h.loop(interval)
+	return h
+}
+
+// Stop stops logging heartbeats. It does not wait for a heartbeat in
+// progress, which needs loggerMutex that the caller may hold.
+func (h *heartbeat) Stop() error {
+	close(h.stop)
+	return nil
+}
+
+func (h *heartbeat) loop(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	var beat map[string]bool // subsystems that logged the last heartbeat
+	for {
+		select {
+		case <-ticker.C:
+			beat = h.beat(beat)
+		case <-h.stop:
+			return
+		}
+	}
+}
+
+// beat logs a heartbeat for every subsystem with info level enabled. The
+// heartbeats logged last time are not counted.
+func (h *heartbeat) beat(last map[string]bool) map[string]bool {
+	counts := h.counter.reset()
+
+	loggerMutex.RLock()
+	bySubsystem := make(map[string]*levelCounts)
+	var names []string
+	for name, level := range levels {
+		if level.Enabled(zapcore.InfoLevel) && loggers[name] != nil {
+			bySubsystem[name] = new(levelCounts)
+			names = append(names, name)
+		}
+	}
+	beating := make(map[string]*zap.SugaredLogger, len(names))
+	for _, name := range names {
+		beating[name] = loggers[name]
+	}
+	loggerMutex.RUnlock()
+
+	// entries of loggers derived with Named count for their subsystem.
+	for name, c := range counts {
+		for sub := name; ; {
+			if total, ok := bySubsystem[sub]; ok {
+				for i := range c {
+					total[i] += c[i]
+				}
+				break
+			}
+			dot := strings.LastIndexByte(sub, '.')
+			if dot < 0 {
+				break
+			}
+			sub = sub[:dot]
+		}
+	}
+
+	sort.Strings(names)
+	beat := make(map[string]bool, len(names))
+	for _, name := range names {
+		total := bySubsystem[name]
+		if last[name] && total[zapcore.InfoLevel-zapcore.DebugLevel] > 0 {
+			total[zapcore.InfoLevel-zapcore.DebugLevel]--
+		}
+		beating[name].Infow(heartbeatMessage, "entries", total)
+		beat[name] = true
+	}
+	return beat
+}
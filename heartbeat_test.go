@@ -0,0 +1,83 @@
+package log
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"go.uber.org/zap/zapcore"
+)
+
+func TestHeartbeat(t *testing.T) {
+	SetupLogging(Config{Level: LevelError})
+	defer SetupLogging(Config{Stderr: true})
+
+	buf := &bytes.Buffer{}
+	counter := newCountingCore()
+	SetPrimaryCore(zapcore.NewTee(newCore(JSONOutput, zapcore.AddSync(buf), LevelDebug, nil), counter))
+
+	logger := getLogger("heartbeat-test")
+	getLogger("heartbeat-quiet-test")
+	if err := SetLogLevel("heartbeat-test", "info"); err != nil {
+		t.Fatal(err)
+	}
+	logger.Info("one")
+	logger.Named("child").Warn("two")
+	logger.Error("three")
+
+	h := &heartbeat{counter: counter}
+	beats := func(last map[string]bool) map[string]bool {
+		buf.Reset()
+		return h.beat(last)
+	}
+	entries := func() map[string]uint64 {
+		for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+			var entry struct {
+				Logger  string            `json:"logger"`
+				Msg     string            `json:"msg"`
+				Entries map[string]uint64 `json:"entries"`
+			}
+			if err := json.Unmarshal([]byte(line), &entry); err != nil {
+				t.Fatal(err)
+			}
+			if entry.Msg != heartbeatMessage {
+				t.Fatalf("got message %q, want %q", entry.Msg, heartbeatMessage)
+			}
+			if entry.Logger == "heartbeat-quiet-test" {
+				t.Fatal("got a heartbeat for a subsystem at error level")
+			}
+			if entry.Logger == "heartbeat-test" {
+				return entry.Entries
+			}
+		}
+		t.Fatal("no heartbeat logged")
+		return nil
+	}
+
+	last := beats(nil)
+	if got := entries(); len(got) != 3 || got["info"] != 1 || got["warn"] != 1 || got["error"] != 1 {
+		t.Errorf("got counts %v, want one each of info, warn and error", got)
+	}
+
+	// the heartbeat itself is not counted.
+	beats(last)
+	if got := entries(); len(got) != 0 {
+		t.Errorf("got counts %v, want none", got)
+	}
+}
+
+func TestHeartbeatSetup(t *testing.T) {
+	SetupLogging(Config{Level: LevelInfo, Heartbeat: HeartbeatConfig{Interval: time.Hour}})
+	defer SetupLogging(Config{Stderr: true})
+
+	loggerMutex.RLock()
+	defer loggerMutex.RUnlock()
+	for _, w := range primaryWrappers {
+		if _, ok := w.(*heartbeat); ok {
+			return
+		}
+	}
+	t.Error("heartbeat not started")
+}
@@ -0,0 +1,154 @@
+package log
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// errNoPinnedKey is returned when no certificate of a verified chain has a
+// pinned public key.
+var errNoPinnedKey = errors.New("no certificate in the chain matches a pinned public key")
+
+// HTTPClientConfig configures the TLS and authentication of the HTTP clients
+// used by this package, see ArchiveConfig.HTTPClient. For remote configs,
+// pass the result of Client to RemoteConfigClient. The zero value results in
+// a client with the default TLS settings and no authentication.
+type HTTPClientConfig struct {
+	// CAFile is a PEM file with the certificate authorities trusted for
+	// server certificates. Defaults to the system roots.
+	CAFile string
+
+	// CertFile and KeyFile are PEM files with a client certificate and its
+	// key, presented to servers that request one.
+	CertFile string
+	KeyFile  string
+
+	// PinnedKeys are SHA-256 hashes of DER-encoded public keys (SPKI), see
+	// PublicKeyPin. When set, a verified chain must contain a certificate
+	// with one of these keys, i.e. the expected CA or the server itself.
+	PinnedKeys [][]byte
+
+	// BearerToken is sent in the Authorization header of every request.
+	BearerToken string
+
+	// Username and Password are sent with HTTP basic authentication when
+	// Username is set and BearerToken is not.
+	Username string
+	Password string
+
+	// Proxy is the URL of the proxy requests are sent through, with the
+	// scheme http, https or socks5, i.e. "socks5://127.0.0.1:1080". Defaults
+	// to the proxy given by the HTTP_PROXY, HTTPS_PROXY and NO_PROXY
+	// environment variables.
+	Proxy string
+
+	// Timeout limits the duration of a request. Defaults to 30 seconds.
+	Timeout time.Duration
+}
+
+// PublicKeyPin returns the pin of the public key of cert for
+// HTTPClientConfig.PinnedKeys.
+func PublicKeyPin(cert *x509.Certificate) []byte {
+	sum := sha256.Sum256(cert.RawSubjectPublicKeyInfo)
+	return sum[:]
+}
+
+// Client returns an HTTP client configured by c.
+func (c HTTPClientConfig) Client() (*http.Client, error) {
+	tlsConfig, err := c.TLSConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = tlsConfig
+	if c.Proxy != "" {
+		proxy, err := url.Parse(c.Proxy)
+		if err != nil {
+			return nil, err
+		}
+		switch proxy.Scheme {
+		case "http", "https", "socks5":
+		default:
+			return nil, fmt.Errorf("unsupported proxy scheme %q", proxy.Scheme)
+		}
+		transport.Proxy = http.ProxyURL(proxy)
+	}
+
+	timeout := c.Timeout
+	if timeout <= 0 {
+		timeout = 30 * time.Second
+	}
+	client := &http.Client{Timeout: timeout, Transport: transport}
+	if c.BearerToken != "" || c.Username != "" {
+		client.Transport = &authTransport{next: transport, config: c}
+	}
+	return client, nil
+}
+
+// TLSConfig returns the TLS settings of c, i.e. for the clients of other
+// protocols than HTTP. Proxy, Timeout and the credentials are not part of
+// them.
+func (c HTTPClientConfig) TLSConfig() (*tls.Config, error) {
+	tlsConfig := &tls.Config{MinVersion: tls.VersionTLS12}
+	if c.CAFile != "" {
+		pem, err := ioutil.ReadFile(c.CAFile)
+		if err != nil {
+			return nil, err
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates found in %q", c.CAFile)
+		}
+		tlsConfig.RootCAs = pool
+	}
+	if c.CertFile != "" || c.KeyFile != "" {
+		cert, err := tls.LoadX509KeyPair(c.CertFile, c.KeyFile)
+		if err != nil {
+			return nil, err
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+	if len(c.PinnedKeys) > 0 {
+		pins := c.PinnedKeys
+		tlsConfig.VerifyPeerCertificate = func(_ [][]byte, chains [][]*x509.Certificate) error {
+			for _, chain := range chains {
+				for _, cert := range chain {
+					pin := PublicKeyPin(cert)
+					for _, p := range pins {
+						if bytes.Equal(pin, p) {
+							return nil
+						}
+					}
+				}
+			}
+			return errNoPinnedKey
+		}
+	}
+	return tlsConfig, nil
+}
+
+// authTransport adds the credentials of config to requests.
+type authTransport struct {
+	next   http.RoundTripper
+	config HTTPClientConfig
+}
+
+func (t *authTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	// a RoundTripper must not modify the request.
+	req = req.Clone(req.Context())
+	if t.config.BearerToken != "" {
+		req.Header.Set("Authorization", "Bearer "+t.config.BearerToken)
+	} else {
+		req.SetBasicAuth(t.config.Username, t.config.Password)
+	}
+	return t.next.RoundTrip(req)
+}
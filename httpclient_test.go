@@ -0,0 +1,107 @@
+package log
+
+import (
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestHTTPClientConfig(t *testing.T) {
+	var auth string
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		auth = r.Header.Get("Authorization")
+	}))
+	defer server.Close()
+
+	caFile := filepath.Join(t.TempDir(), "ca.pem")
+	ca := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw})
+	if err := os.WriteFile(caFile, ca, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tc := range []struct {
+		name     string
+		config   HTTPClientConfig
+		wantErr  bool
+		wantAuth string
+	}{
+		{name: "untrusted", config: HTTPClientConfig{}, wantErr: true},
+		{name: "trusted", config: HTTPClientConfig{CAFile: caFile}},
+		{name: "pinned", config: HTTPClientConfig{CAFile: caFile, PinnedKeys: [][]byte{PublicKeyPin(server.Certificate())}}},
+		{
+			name:    "wrong pin",
+			config:  HTTPClientConfig{CAFile: caFile, PinnedKeys: [][]byte{make([]byte, 32)}},
+			wantErr: true,
+		},
+		{
+			name:     "bearer",
+			config:   HTTPClientConfig{CAFile: caFile, BearerToken: "token"},
+			wantAuth: "Bearer token",
+		},
+		{
+			name:     "basic",
+			config:   HTTPClientConfig{CAFile: caFile, Username: "user", Password: "pass"},
+			wantAuth: "Basic dXNlcjpwYXNz",
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			auth = ""
+			client, err := tc.config.Client()
+			if err != nil {
+				t.Fatal(err)
+			}
+			resp, err := client.Get(server.URL)
+			if tc.wantErr {
+				if err == nil {
+					resp.Body.Close()
+					t.Fatal("expected an error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			resp.Body.Close()
+			if auth != tc.wantAuth {
+				t.Errorf("got authorization %q, want %q", auth, tc.wantAuth)
+			}
+		})
+	}
+}
+
+func TestHTTPClientConfigMissingCA(t *testing.T) {
+	if _, err := (HTTPClientConfig{CAFile: filepath.Join(t.TempDir(), "missing.pem")}).Client(); err == nil {
+		t.Error("expected an error for a missing CA file")
+	}
+}
+
+func TestHTTPClientConfigProxy(t *testing.T) {
+	var proxied string
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		proxied = r.URL.String()
+	}))
+	defer proxy.Close()
+
+	client, err := HTTPClientConfig{Proxy: proxy.URL}.Client()
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := client.Get("http://logs.example/config")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if proxied != "http://logs.example/config" {
+		t.Errorf("proxy got %q", proxied)
+	}
+
+	if _, err := (HTTPClientConfig{Proxy: "socks5://127.0.0.1:1080"}).Client(); err != nil {
+		t.Errorf("socks5 proxy: %s", err)
+	}
+	if _, err := (HTTPClientConfig{Proxy: "ftp://127.0.0.1"}).Client(); err == nil {
+		t.Error("expected an error for an unsupported proxy scheme")
+	}
+}
@@ -0,0 +1,30 @@
+package log
+
+import (
+	"sync/atomic"
+
+	"go.uber.org/zap/zapcore"
+)
+
+// LevelEnabler decides whether an entry is logged, given the name of the
+// logger, the level of the entry and its fields, including those added with
+// With. It is only consulted for entries enabled by the level of their
+// subsystem or package.
+type LevelEnabler func(subsystem string, level LogLevel, fields []zapcore.Field) bool
+
+// levelEnabler holds the active LevelEnabler, nil when none is set.
+var levelEnabler atomic.Value
+
+func loadLevelEnabler() LevelEnabler {
+	fn, _ := levelEnabler.Load().(LevelEnabler)
+	return fn
+}
+
+// SetLevelEnabler installs a function that is consulted for every enabled
+// entry, i.e. to allow debug logs only on canary nodes. A nil function
+// removes it.
+//
+// The function is called concurrently and must not log itself.
+func SetLevelEnabler(fn LevelEnabler) {
+	levelEnabler.Store(fn)
+}
@@ -0,0 +1,56 @@
+//go:build !golog_discard
+// +build !golog_discard
+
+package log
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"go.uber.org/zap/zapcore"
+)
+
+func TestLevelEnabler(t *testing.T) {
+	defer SetupLogging(Config{Stderr: true})
+
+	buf := &bytes.Buffer{}
+	SetupLogging(Config{Level: LevelDebug})
+	SetPrimaryCore(newCore(PlaintextOutput, zapcore.AddSync(buf), LevelDebug, nil))
+
+	// debug logs only for canary nodes.
+	SetLevelEnabler(func(subsystem string, level LogLevel, fields []zapcore.Field) bool {
+		if level > LevelDebug {
+			return true
+		}
+		for _, f := range fields {
+			if f.Key == "canary" && f.Type == zapcore.BoolType && f.Integer == 1 {
+				return true
+			}
+		}
+		return false
+	})
+	defer SetLevelEnabler(nil)
+
+	log := Logger("level-enabler-test")
+	log.Debug("plain")
+	log.Info("info")
+	log.With("canary", true).Debug("canary")
+	log.Debugw("inline", "canary", true)
+
+	out := buf.String()
+	for _, want := range []string{"info", "canary", "inline"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("missing %q in %q", want, out)
+		}
+	}
+	if strings.Contains(out, "plain") {
+		t.Errorf("filtered entry logged: %q", out)
+	}
+
+	SetLevelEnabler(nil)
+	log.Debug("removed")
+	if !strings.Contains(buf.String(), "removed") {
+		t.Errorf("entry not logged after removing the enabler: %q", buf.String())
+	}
+}
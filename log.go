@@ -26,12 +26,31 @@ type StandardLogger interface {
 	Warnf(format string, args ...interface{})
 }
 
+// StructuredLogger provides loosely typed structured logging, where the
+// message is followed by alternating keys and values.
+type StructuredLogger interface {
+	Debugw(msg string, keysAndValues ...interface{})
+	Errorw(msg string, keysAndValues ...interface{})
+	Fatalw(msg string, keysAndValues ...interface{})
+	Infow(msg string, keysAndValues ...interface{})
+	Panicw(msg string, keysAndValues ...interface{})
+	Warnw(msg string, keysAndValues ...interface{})
+}
+
 // EventLogger extends the StandardLogger interface to allow for log items
 // containing structured metadata
+//
+// It is kept for compatibility. Libraries should depend on the narrower
+// StandardLogger or StructuredLogger interfaces they actually use.
 type EventLogger interface {
 	StandardLogger
 }
 
+var (
+	_ EventLogger      = (*ZapEventLogger)(nil)
+	_ StructuredLogger = (*ZapEventLogger)(nil)
+)
+
 // Logger retrieves an event logger by name
 func Logger(system string) *ZapEventLogger {
 	if len(system) == 0 {
@@ -70,6 +89,23 @@ func (logger *ZapEventLogger) Warningf(format string, args ...interface{}) {
 	logger.skipLogger.Warnf(format, args...)
 }
 
+// MessageIDKey is the field key of message IDs, see InfoID.
+const MessageIDKey = "msg_id"
+
+// WarnID logs a message at warn level with a stable message ID, see InfoID.
+func (logger *ZapEventLogger) WarnID(id, msg string, keysAndValues ...interface{}) {
+	logger.skipLogger.Warnw(msg, withMessageID(id, keysAndValues)...)
+}
+
+// ErrorID logs a message at error level with a stable message ID, see InfoID.
+func (logger *ZapEventLogger) ErrorID(id, msg string, keysAndValues ...interface{}) {
+	logger.skipLogger.Errorw(msg, withMessageID(id, keysAndValues)...)
+}
+
+func withMessageID(id string, keysAndValues []interface{}) []interface{} {
+	return append([]interface{}{MessageIDKey, id}, keysAndValues...)
+}
+
 // FormatRFC3339 returns the given time in UTC with RFC3999Nano format.
 func FormatRFC3339(t time.Time) string {
 	return t.UTC().Format(time.RFC3339Nano)
@@ -0,0 +1,39 @@
+//go:build !golog_discard
+// +build !golog_discard
+
+package log
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"go.uber.org/zap/zapcore"
+)
+
+func TestInfoID(t *testing.T) {
+	defer SetupLogging(Config{Stderr: true})
+
+	buf := &bytes.Buffer{}
+	SetupLogging(Config{Level: LevelInfo})
+	SetPrimaryCore(newCore(JSONOutput, zapcore.AddSync(buf), LevelDebug, nil))
+
+	Logger("msg-id-test").InfoID("dht.query.start", "starting query", "peers", 3)
+
+	var entry struct {
+		Message string `json:"msg"`
+		ID      string `json:"msg_id"`
+		Caller  string `json:"caller"`
+		Peers   int    `json:"peers"`
+	}
+	if err := json.Unmarshal(buf.Bytes(), &entry); err != nil {
+		t.Fatal(err)
+	}
+	if entry.Message != "starting query" || entry.ID != "dht.query.start" || entry.Peers != 3 {
+		t.Errorf("got entry %+v", entry)
+	}
+	if !strings.Contains(entry.Caller, "log_test.go") {
+		t.Errorf("got caller %q, want the call site", entry.Caller)
+	}
+}
@@ -0,0 +1,105 @@
+package log
+
+import (
+	"fmt"
+	"time"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// LogConfig logs the effective logging configuration as a single entry of the
+// "log" subsystem: format, levels, outputs, routes and sampling rules. The
+// entry is logged at info level regardless of the levels set, so that it is
+// always on record what a process was logging. The build info of the binary
+// is included, see Config.BuildInfo. Call it at startup; it is
+// logged again whenever a RemoteConfigPuller applies a new configuration.
+func LogConfig() {
+	loggerMutex.RLock()
+	cfg := config
+	sampled := withRemoteRules(cfg.Rules)
+	format := primaryFormat
+	level := defaultLevel
+	subsystemLevels := make(map[string]string)
+	for name, l := range levels {
+		if l.Level() != zapcore.Level(level) {
+			subsystemLevels[name] = l.Level().String()
+		}
+	}
+	loggerMutex.RUnlock()
+
+	packageLevels := make(map[string]string)
+	if set := loadPackageLevels(); set != nil {
+		for pkg, l := range set.exact {
+			packageLevels[pkg] = l.String()
+		}
+		for pkg, l := range set.prefixes {
+			packageLevels[pkg+"/..."] = l.String()
+		}
+	}
+
+	var outputs []string
+	for _, m := range registeredSinks() {
+		outputs = append(outputs, m.st.Name)
+	}
+	routes := make(map[string][]string)
+	for _, route := range cfg.Routes {
+		routes[route.Output] = append(routes[route.Output], route.Subsystems...)
+	}
+	var sampling []string
+	for _, rule := range sampled {
+		if rule.Action == SampleAction {
+			sampling = append(sampling, fmt.Sprintf("level=%q subsystem=%q 1/%d", rule.Level, rule.Subsystem, rule.SampleRate))
+		}
+	}
+	outputFormats := make(map[string]string)
+	for name, f := range cfg.OutputFormats {
+		outputFormats[name] = formatName(f)
+	}
+	loc := time.UTC
+	if cfg.TimeLocation != nil {
+		loc = cfg.TimeLocation
+	}
+
+	fields := []zapcore.Field{
+		zap.String("format", formatName(format)),
+		zap.Any("outputFormats", outputFormats),
+		zap.Stringer("defaultLevel", zapcore.Level(level)),
+		zap.Any("subsystemLevels", subsystemLevels),
+		zap.Any("packageLevels", packageLevels),
+		zap.Strings("outputs", outputs),
+		zap.Any("routes", routes),
+		zap.Int("rules", len(cfg.Rules)),
+		zap.Strings("sampling", sampling),
+		zap.Any("labels", cfg.Labels),
+		zap.Stringer("timeZone", loc),
+	}
+	fields = append(fields, buildInfoFields()...)
+	ent := zapcore.Entry{
+		LoggerName: "log",
+		Level:      zapcore.InfoLevel,
+		Time:       time.Now(),
+		Message:    "logging configuration",
+	}
+	if ce := loggerCore.Check(ent, nil); ce != nil {
+		ce.Write(fields...)
+	}
+}
+
+// formatName returns the name of a format as used in GOLOG_LOG_FMT.
+func formatName(format LogFormat) string {
+	switch format {
+	case PlaintextOutput:
+		return "nocolor"
+	case JSONOutput:
+		return "json"
+	case GrepOutput:
+		return "grep"
+	case ColorizedOutput:
+		return "color"
+	}
+	if custom, ok := lookupCustomFormat(format); ok {
+		return custom.name
+	}
+	return "color"
+}
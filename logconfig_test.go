@@ -0,0 +1,56 @@
+package log
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"testing"
+)
+
+func TestLogConfig(t *testing.T) {
+	f, err := ioutil.TempFile("", "go-log-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(f.Name())
+
+	SetupLogging(Config{
+		Format:          JSONOutput,
+		Level:           LevelError,
+		File:            f.Name(),
+		SubsystemLevels: map[string]LogLevel{"logconfig-test": LevelDebug},
+		Rules:           []Rule{{Subsystem: "dht*", Action: SampleAction, SampleRate: 10}},
+	})
+	defer SetupLogging(Config{Stderr: true})
+
+	LogConfig()
+
+	content, err := ioutil.ReadFile(f.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	var entry struct {
+		Logger          string            `json:"logger"`
+		Message         string            `json:"msg"`
+		Format          string            `json:"format"`
+		DefaultLevel    string            `json:"defaultLevel"`
+		SubsystemLevels map[string]string `json:"subsystemLevels"`
+		Outputs         []string          `json:"outputs"`
+		Sampling        []string          `json:"sampling"`
+	}
+	if err := json.Unmarshal(content, &entry); err != nil {
+		t.Fatalf("%s: %q", err, content)
+	}
+	if entry.Logger != "log" || entry.Message != "logging configuration" || entry.Format != "json" || entry.DefaultLevel != "error" {
+		t.Errorf("got entry %+v", entry)
+	}
+	if entry.SubsystemLevels["logconfig-test"] != "debug" {
+		t.Errorf("got subsystem levels %v", entry.SubsystemLevels)
+	}
+	if len(entry.Outputs) != 1 || entry.Outputs[0] != f.Name() {
+		t.Errorf("got outputs %v", entry.Outputs)
+	}
+	if len(entry.Sampling) != 1 {
+		t.Errorf("got sampling %v", entry.Sampling)
+	}
+}
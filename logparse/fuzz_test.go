@@ -0,0 +1,27 @@
+//go:build go1.18
+// +build go1.18
+
+package logparse
+
+import (
+	"io"
+	"strings"
+	"testing"
+)
+
+func FuzzDecoder(f *testing.F) {
+	f.Add("2010-05-23T15:14:00.000Z\tINFO\tdht\tdht/query.go:12\tmsg\t{\"a\": 1}\n")
+	f.Add(`{"level":"warn","ts":1.5,"msg":"m"}` + "\n")
+	f.Add("2010-05-23T15:14:00.000Z\tERROR\t\t\n\tstack\n{")
+
+	f.Fuzz(func(t *testing.T, input string) {
+		dec := NewDecoder(strings.NewReader(input))
+		for i := 0; i < 1000; i++ {
+			if _, err := dec.Next(); err == io.EOF {
+				return
+			} else if err != nil {
+				return
+			}
+		}
+	})
+}
@@ -0,0 +1,314 @@
+// Package logparse decodes the output of go-log, so that tools and tests can
+// work with entries instead of parsing lines themselves:
+//
+//	dec := logparse.NewDecoder(os.Stdin)
+//	dec.SetFilter(logparse.Filter{Level: "warn", Logger: "dht"})
+//	for {
+//		e, err := dec.Next()
+//		if err == io.EOF {
+//			break
+//		} else if err != nil {
+//			return err
+//		}
+//		fmt.Println(e.Time, e.Message)
+//	}
+//
+// Both the json and the human-readable formats (colorized or not) are
+// supported, and can be mixed in the same stream.
+package logparse
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// maxLineSize is the size of the longest line that can be decoded.
+const maxLineSize = 1 << 20
+
+// Entry is a decoded log entry.
+type Entry struct {
+	Time time.Time
+	// Level is the lower-case level name, i.e. "warn".
+	Level   string
+	Logger  string
+	Caller  string
+	Message string
+	// Fields are the fields of the entry as decoded from JSON, where numbers
+	// are float64.
+	Fields     map[string]interface{}
+	Stacktrace string
+}
+
+// levels ranks the level names.
+var levels = map[string]int{
+	"debug":  -1,
+	"info":   0,
+	"warn":   1,
+	"error":  2,
+	"dpanic": 3,
+	"panic":  4,
+	"fatal":  5,
+}
+
+// letterLevels maps the one letter levels of the grep format to level names.
+var letterLevels = map[string]string{
+	"d": "debug",
+	"i": "info",
+	"w": "warn",
+	"e": "error",
+	"p": "panic",
+	"f": "fatal",
+}
+
+// Filter selects entries. Zero values match all entries.
+type Filter struct {
+	// Level is the minimum level of matching entries, i.e. "error".
+	Level string
+
+	// Logger matches entries of the logger and of loggers derived from it
+	// with Named ("bitswap.ledger").
+	Logger string
+
+	// Since and Until bound the time of matching entries, inclusively.
+	Since time.Time
+	Until time.Time
+
+	// Fields match entries that have all of the given fields, comparing
+	// their values as formatted by fmt.Sprint.
+	Fields map[string]string
+}
+
+// Match reports whether e matches f.
+func (f Filter) Match(e Entry) bool {
+	if f.Level != "" && levels[e.Level] < levels[strings.ToLower(f.Level)] {
+		return false
+	}
+	if f.Logger != "" && e.Logger != f.Logger && !strings.HasPrefix(e.Logger, f.Logger+".") {
+		return false
+	}
+	if !f.Since.IsZero() && e.Time.Before(f.Since) || !f.Until.IsZero() && e.Time.After(f.Until) {
+		return false
+	}
+	for k, want := range f.Fields {
+		v, ok := e.Fields[k]
+		if !ok || fmt.Sprint(v) != want {
+			return false
+		}
+	}
+	return true
+}
+
+// Decoder reads entries from a stream.
+type Decoder struct {
+	scanner *bufio.Scanner
+	line    int
+	filter  *Filter
+
+	// pending is the last human-readable entry, which is held back until
+	// the next entry starts because a stack trace may follow it.
+	pending *Entry
+	stack   []string // lines of the stack trace of pending
+	ready   []Entry
+}
+
+// NewDecoder returns a decoder reading from r.
+func NewDecoder(r io.Reader) *Decoder {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(nil, maxLineSize)
+	return &Decoder{scanner: scanner}
+}
+
+// SetFilter makes Next skip entries that don't match f.
+func (d *Decoder) SetFilter(f Filter) {
+	d.filter = &f
+}
+
+// Next returns the next entry, or io.EOF at the end of the stream.
+// Human-readable entries are returned once the line after them has been
+// read, or the stream ended.
+func (d *Decoder) Next() (Entry, error) {
+	for {
+		if len(d.ready) > 0 {
+			e := d.ready[0]
+			d.ready = d.ready[1:]
+			if d.filter == nil || d.filter.Match(e) {
+				return e, nil
+			}
+			continue
+		}
+
+		if !d.scanner.Scan() {
+			if err := d.scanner.Err(); err != nil {
+				return Entry{}, err
+			}
+			if !d.flush() {
+				return Entry{}, io.EOF
+			}
+			continue
+		}
+		d.line++
+		line := d.scanner.Text()
+
+		if strings.HasPrefix(line, "{") {
+			e, err := parseJSON(line)
+			if err != nil {
+				return Entry{}, fmt.Errorf("line %d: %w", d.line, err)
+			}
+			d.flush()
+			d.ready = append(d.ready, e)
+			continue
+		}
+		if e, ok := parseConsole(line); ok {
+			d.flush()
+			d.pending = &e
+			continue
+		}
+		// a line of a stack trace, or unrelated output.
+		if d.pending != nil {
+			d.stack = append(d.stack, line)
+		}
+	}
+}
+
+// errNotEntry is returned by Parse for output that is not a log entry.
+var errNotEntry = errors.New("not a log entry")
+
+// Parse decodes a single entry as written by go-log in one write, i.e. by a
+// sink registered with zap.RegisterSink. The lines following the first line
+// of a human-readable entry are its stack trace.
+func Parse(data []byte) (Entry, error) {
+	text := strings.TrimSuffix(string(data), "\n")
+	if strings.HasPrefix(text, "{") {
+		return parseJSON(text)
+	}
+	first, stack := text, ""
+	if i := strings.IndexByte(text, '\n'); i >= 0 {
+		first, stack = text[:i], text[i+1:]
+	}
+	e, ok := parseConsole(first)
+	if !ok {
+		return Entry{}, errNotEntry
+	}
+	e.Stacktrace = stack
+	return e, nil
+}
+
+// flush moves the pending entry to the ready ones, and reports whether
+// there was one.
+func (d *Decoder) flush() bool {
+	if d.pending == nil {
+		return false
+	}
+	if len(d.stack) > 0 {
+		d.pending.Stacktrace = strings.Join(d.stack, "\n")
+		d.stack = nil
+	}
+	d.ready = append(d.ready, *d.pending)
+	d.pending = nil
+	return true
+}
+
+// timeLayout is the layout of timestamps written by go-log.
+const timeLayout = "2006-01-02T15:04:05.000Z0700"
+
+func parseTime(s string) (time.Time, bool) {
+	for _, layout := range []string{timeLayout, time.RFC3339Nano} {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t, true
+		}
+	}
+	return time.Time{}, false
+}
+
+func parseJSON(line string) (Entry, error) {
+	var fields map[string]interface{}
+	if err := json.Unmarshal([]byte(line), &fields); err != nil {
+		return Entry{}, err
+	}
+	take := func(key string) string {
+		v, _ := fields[key].(string)
+		delete(fields, key)
+		return v
+	}
+
+	e := Entry{
+		Level:      strings.ToLower(take("level")),
+		Logger:     take("logger"),
+		Caller:     take("caller"),
+		Message:    take("msg"),
+		Stacktrace: take("stacktrace"),
+	}
+	switch ts := fields["ts"].(type) {
+	case string:
+		e.Time, _ = parseTime(ts)
+	case float64:
+		// seconds since the epoch, as written by zap's default encoder.
+		e.Time = time.Unix(0, int64(ts*float64(time.Second))).UTC()
+	}
+	delete(fields, "ts")
+	e.Fields = fields
+	return e, nil
+}
+
+var (
+	ansiEscape = regexp.MustCompile("\x1b\\[[0-9;]*m")
+	callerPath = regexp.MustCompile(`^\S+\.go:\d+$`)
+)
+
+// parseConsole parses a line in the human-readable format: timestamp, level,
+// logger and caller if any, message, and fields as a JSON object if any,
+// separated by tabs. Levels may be single letters and loggers padded with
+// spaces, as in the grep format.
+func parseConsole(line string) (Entry, bool) {
+	parts := strings.Split(line, "\t")
+	if len(parts) < 3 {
+		return Entry{}, false
+	}
+	t, ok := parseTime(parts[0])
+	if !ok {
+		return Entry{}, false
+	}
+	level := strings.ToLower(ansiEscape.ReplaceAllString(parts[1], ""))
+	if name, ok := letterLevels[level]; ok {
+		level = name
+	}
+	if _, ok := levels[level]; !ok {
+		return Entry{}, false
+	}
+	e := Entry{Time: t, Level: level}
+
+	rest := parts[2:]
+	if last := rest[len(rest)-1]; len(rest) > 1 && strings.HasPrefix(last, "{") {
+		var fields map[string]interface{}
+		if err := json.Unmarshal([]byte(last), &fields); err == nil {
+			e.Fields = fields
+			rest = rest[:len(rest)-1]
+		}
+	}
+
+	caller := -1
+	for i, part := range rest[:len(rest)-1] {
+		if callerPath.MatchString(part) {
+			caller = i
+			break
+		}
+	}
+	switch {
+	case caller >= 0:
+		e.Logger = strings.TrimRight(strings.Join(rest[:caller], "\t"), " ")
+		e.Caller = rest[caller]
+		e.Message = strings.Join(rest[caller+1:], "\t")
+	case len(rest) > 1:
+		e.Logger = strings.TrimRight(rest[0], " ")
+		e.Message = strings.Join(rest[1:], "\t")
+	default:
+		e.Message = rest[0]
+	}
+	return e, true
+}
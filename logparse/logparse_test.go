@@ -0,0 +1,120 @@
+package logparse
+
+import (
+	"io"
+	"io/ioutil"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	log "github.com/ipfs/go-log/v2"
+)
+
+func decodeAll(t *testing.T, dec *Decoder) []Entry {
+	t.Helper()
+	var entries []Entry
+	for {
+		e, err := dec.Next()
+		if err == io.EOF {
+			return entries
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		entries = append(entries, e)
+	}
+}
+
+func TestDecoder(t *testing.T) {
+	ts := time.Date(2010, 5, 23, 15, 14, 0, 0, time.UTC)
+	input := strings.Join([]string{
+		"2010-05-23T15:14:00.000Z\tINFO\tdht\tdht/query.go:12\tstarting query\t{\"peers\": 3}",
+		"2010-05-23T15:14:00.000Z\t\x1b[31mERROR\x1b[0m\tdht.query\tfailed",
+		"main.main()",
+		"\t/src/main.go:10",
+		`{"level":"warn","ts":"2010-05-23T15:14:00.000Z","logger":"swarm","caller":"swarm/dial.go:7","msg":"dial backoff","peer":"p"}`,
+		"2010-05-23T15:14:00.000Z\tDEBUG\tno logger",
+		"2010-05-23T15:14:00.000Z\tW\tbitswap             \tslow peer",
+	}, "\n")
+
+	want := []Entry{
+		{Time: ts, Level: "info", Logger: "dht", Caller: "dht/query.go:12", Message: "starting query", Fields: map[string]interface{}{"peers": 3.0}},
+		{Time: ts, Level: "error", Logger: "dht.query", Message: "failed", Stacktrace: "main.main()\n\t/src/main.go:10"},
+		{Time: ts, Level: "warn", Logger: "swarm", Caller: "swarm/dial.go:7", Message: "dial backoff", Fields: map[string]interface{}{"peer": "p"}},
+		{Time: ts, Level: "debug", Message: "no logger"},
+		{Time: ts, Level: "warn", Logger: "bitswap", Message: "slow peer"},
+	}
+	got := decodeAll(t, NewDecoder(strings.NewReader(input)))
+	if len(got) != len(want) {
+		t.Fatalf("got %d entries, want %d: %+v", len(got), len(want), got)
+	}
+	for i := range want {
+		if !got[i].Time.Equal(want[i].Time) {
+			t.Errorf("%d: got time %s, want %s", i, got[i].Time, want[i].Time)
+		}
+		got[i].Time = want[i].Time
+		if !reflect.DeepEqual(got[i], want[i]) {
+			t.Errorf("%d: got %+v, want %+v", i, got[i], want[i])
+		}
+	}
+
+	dec := NewDecoder(strings.NewReader(input))
+	dec.SetFilter(Filter{Level: "warn", Logger: "dht"})
+	if got := decodeAll(t, dec); len(got) != 1 || got[0].Message != "failed" {
+		t.Errorf("got filtered entries %+v", got)
+	}
+}
+
+func TestDecoderInvalidJSON(t *testing.T) {
+	if _, err := NewDecoder(strings.NewReader("{not json")).Next(); err == nil || err == io.EOF {
+		t.Errorf("got %v, want a decoding error", err)
+	}
+}
+
+func TestDecodeLogOutput(t *testing.T) {
+	for _, format := range []log.LogFormat{log.JSONOutput, log.PlaintextOutput} {
+		f, err := ioutil.TempFile("", "go-log-test")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer os.Remove(f.Name())
+
+		log.SetupLogging(log.Config{Format: format, Level: log.LevelInfo, File: f.Name()})
+		log.Logger("logparse-test").Named("child").Warnw("decoded", "n", 1)
+		log.SetupLogging(log.Config{Stderr: true})
+
+		got := decodeAll(t, NewDecoder(f))
+		if len(got) != 1 {
+			t.Fatalf("%v: got %d entries, want 1", format, len(got))
+		}
+		e := got[0]
+		if e.Level != "warn" || e.Logger != "logparse-test.child" || e.Message != "decoded" ||
+			e.Fields["n"] != 1.0 || !strings.HasPrefix(e.Caller, "logparse/logparse_test.go:") {
+			t.Errorf("%v: got %+v", format, e)
+		}
+	}
+}
+
+func TestParse(t *testing.T) {
+	e, err := Parse([]byte("2010-05-23T15:14:00.000Z\tERROR\tdht\tfailed\nmain.main()\n\t/src/main.go:10\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if e.Level != "error" || e.Logger != "dht" || e.Message != "failed" || e.Stacktrace != "main.main()\n\t/src/main.go:10" {
+		t.Errorf("got %+v", e)
+	}
+
+	e, err = Parse([]byte(`{"level":"info","ts":"2010-05-23T15:14:00.000Z","msg":"started","peers":3}` + "\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if e.Level != "info" || e.Message != "started" || e.Fields["peers"] != 3.0 {
+		t.Errorf("got %+v", e)
+	}
+
+	if _, err := Parse([]byte("unrelated output\n")); err == nil {
+		t.Error("parsed unrelated output")
+	}
+}
@@ -0,0 +1,81 @@
+//go:build (android || ios) && cgo
+// +build android ios
+// +build cgo
+
+package log
+
+import (
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// platformOutputs sends the entries meant for stderr or stdout, which are
+// discarded on mobile platforms, to the system log instead.
+func platformOutputs(cfg Config) (Config, zapcore.Core) {
+	if !cfg.Stderr && !cfg.Stdout {
+		return cfg, nil
+	}
+	cfg.Stderr, cfg.Stdout = false, false
+	return cfg, newSystemLogCore(systemLogWrite)
+}
+
+var _ zapcore.Core = (*systemLogCore)(nil)
+
+// systemLogCore writes entries to the system log, with the subsystem as the
+// tag. The system log records time and level itself.
+type systemLogCore struct {
+	zapcore.LevelEnabler
+	enc   zapcore.Encoder
+	write func(lvl zapcore.Level, tag, msg string)
+}
+
+func newSystemLogCore(write func(lvl zapcore.Level, tag, msg string)) *systemLogCore {
+	encCfg := zap.NewProductionEncoderConfig()
+	encCfg.TimeKey = ""
+	encCfg.LevelKey = ""
+	encCfg.NameKey = ""
+	encCfg.LineEnding = " "
+	return &systemLogCore{
+		LevelEnabler: zapcore.DebugLevel,
+		enc:          zapcore.NewConsoleEncoder(encCfg),
+		write:        write,
+	}
+}
+
+func (c *systemLogCore) With(fields []zapcore.Field) zapcore.Core {
+	enc := c.enc.Clone()
+	for i := range fields {
+		fields[i].AddTo(enc)
+	}
+	return &systemLogCore{
+		LevelEnabler: c.LevelEnabler,
+		enc:          enc,
+		write:        c.write,
+	}
+}
+
+func (c *systemLogCore) Check(ent zapcore.Entry, ce *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if c.Enabled(ent.Level) {
+		return ce.AddCore(ent, c)
+	}
+	return ce
+}
+
+func (c *systemLogCore) Write(ent zapcore.Entry, fields []zapcore.Field) error {
+	buf, err := c.enc.EncodeEntry(ent, fields)
+	if err != nil {
+		return err
+	}
+	defer buf.Free()
+
+	tag := ent.LoggerName
+	if tag == "" {
+		tag = "go-log"
+	}
+	c.write(ent.Level, tag, buf.String())
+	return nil
+}
+
+func (c *systemLogCore) Sync() error {
+	return nil
+}
@@ -0,0 +1,40 @@
+//go:build android && cgo
+// +build android,cgo
+
+package log
+
+/*
+#cgo LDFLAGS: -llog
+#include <android/log.h>
+#include <stdlib.h>
+*/
+import "C"
+
+import (
+	"unsafe"
+
+	"go.uber.org/zap/zapcore"
+)
+
+// systemLogWrite writes to logcat with the priority matching lvl.
+func systemLogWrite(lvl zapcore.Level, tag, msg string) {
+	var prio C.int
+	switch lvl {
+	case zapcore.DebugLevel:
+		prio = C.ANDROID_LOG_DEBUG
+	case zapcore.InfoLevel:
+		prio = C.ANDROID_LOG_INFO
+	case zapcore.WarnLevel:
+		prio = C.ANDROID_LOG_WARN
+	case zapcore.ErrorLevel:
+		prio = C.ANDROID_LOG_ERROR
+	default:
+		prio = C.ANDROID_LOG_FATAL
+	}
+
+	ctag := C.CString(tag)
+	defer C.free(unsafe.Pointer(ctag))
+	cmsg := C.CString(msg)
+	defer C.free(unsafe.Pointer(cmsg))
+	C.__android_log_write(prio, ctag, cmsg)
+}
@@ -0,0 +1,73 @@
+//go:build ios && cgo
+// +build ios,cgo
+
+package log
+
+/*
+#include <os/log.h>
+#include <stdlib.h>
+
+static os_log_t golog_os_log_create(const char *subsystem, const char *category) {
+	return os_log_create(subsystem, category);
+}
+
+// os_log_with_type is a macro that requires a literal format string.
+static void golog_os_log(os_log_t log, os_log_type_t type, const char *msg) {
+	os_log_with_type(log, type, "%{public}s", msg);
+}
+*/
+import "C"
+
+import (
+	"sync"
+	"unsafe"
+
+	"go.uber.org/zap/zapcore"
+)
+
+// osLogSubsystem is the subsystem of the unified logging system that entries
+// are logged to. The go-log subsystem is used as the category.
+const osLogSubsystem = "io.ipfs.go-log"
+
+var osLogsMu sync.Mutex // guards osLogs
+var osLogs = make(map[string]C.os_log_t)
+
+// systemLogWrite writes to the unified logging system with the type matching
+// lvl.
+func systemLogWrite(lvl zapcore.Level, tag, msg string) {
+	var typ C.os_log_type_t
+	switch lvl {
+	case zapcore.DebugLevel:
+		typ = C.OS_LOG_TYPE_DEBUG
+	case zapcore.InfoLevel:
+		typ = C.OS_LOG_TYPE_INFO
+	case zapcore.WarnLevel:
+		typ = C.OS_LOG_TYPE_DEFAULT
+	case zapcore.ErrorLevel:
+		typ = C.OS_LOG_TYPE_ERROR
+	default:
+		typ = C.OS_LOG_TYPE_FAULT
+	}
+
+	cmsg := C.CString(msg)
+	defer C.free(unsafe.Pointer(cmsg))
+	C.golog_os_log(osLog(tag), typ, cmsg)
+}
+
+// osLog returns the log object of a category. Log objects live as long as
+// the process.
+func osLog(category string) C.os_log_t {
+	osLogsMu.Lock()
+	defer osLogsMu.Unlock()
+
+	log, ok := osLogs[category]
+	if !ok {
+		csub := C.CString(osLogSubsystem)
+		defer C.free(unsafe.Pointer(csub))
+		ccat := C.CString(category)
+		defer C.free(unsafe.Pointer(ccat))
+		log = C.golog_os_log_create(csub, ccat)
+		osLogs[category] = log
+	}
+	return log
+}
@@ -0,0 +1,74 @@
+package log
+
+import (
+	"sync"
+	"time"
+
+	"go.uber.org/zap/zapcore"
+)
+
+var _ zapcore.Core = (*monotonicCore)(nil)
+
+// monotonicCore makes the timestamps of the entries written to the next core
+// non-decreasing. When the wall clock steps backwards, timestamps advance by
+// the time elapsed on the monotonic clock instead, until the wall clock has
+// caught up again.
+type monotonicCore struct {
+	next  zapcore.Core
+	clock *monotonicClock
+}
+
+type monotonicClock struct {
+	mu   sync.Mutex // also serializes writes, to keep them in timestamp order
+	last time.Time  // timestamp of the last entry as taken
+	out  time.Time  // timestamp the last entry was written with
+}
+
+func newMonotonicCore(next zapcore.Core) *monotonicCore {
+	return &monotonicCore{next: next, clock: &monotonicClock{}}
+}
+
+func (c *monotonicCore) With(fields []zapcore.Field) zapcore.Core {
+	return &monotonicCore{next: c.next.With(fields), clock: c.clock}
+}
+
+func (c *monotonicCore) Enabled(lvl zapcore.Level) bool {
+	return c.next.Enabled(lvl)
+}
+
+func (c *monotonicCore) Check(ent zapcore.Entry, ce *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if c.next.Enabled(ent.Level) {
+		return ce.AddCore(ent, c)
+	}
+	return ce
+}
+
+func (c *monotonicCore) Write(ent zapcore.Entry, fields []zapcore.Field) error {
+	c.clock.mu.Lock()
+	defer c.clock.mu.Unlock()
+
+	ent.Time = c.clock.correct(ent.Time)
+	return c.next.Write(ent, fields)
+}
+
+func (c *monotonicCore) Sync() error {
+	return c.next.Sync()
+}
+
+// correct returns the timestamp to write an entry taken at t with. clock.mu
+// must be held.
+func (clock *monotonicClock) correct(t time.Time) time.Time {
+	out := t.Round(0) // strip the monotonic reading
+	if out.Before(clock.out) {
+		// Sub uses the monotonic clock when both times have a reading.
+		elapsed := t.Sub(clock.last)
+		if elapsed < 0 {
+			// taken before the last entry, but written after it.
+			elapsed = 0
+		}
+		out = clock.out.Add(elapsed)
+	}
+	clock.last = t
+	clock.out = out
+	return out
+}
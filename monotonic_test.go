@@ -0,0 +1,50 @@
+package log
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"go.uber.org/zap/zapcore"
+)
+
+func TestMonotonicClock(t *testing.T) {
+	base := time.Date(2010, 5, 23, 15, 14, 0, 0, time.UTC)
+	clock := &monotonicClock{}
+
+	for i, tc := range []struct {
+		taken, want time.Duration
+	}{
+		{0, 0},
+		// the clock stepped back by a second.
+		{-time.Second, 0},
+		{-time.Second + 500*time.Millisecond, 500 * time.Millisecond},
+		// taken before the previous entry.
+		{-time.Second + 400*time.Millisecond, 500 * time.Millisecond},
+		// the clock caught up.
+		{time.Second, time.Second},
+	} {
+		if got := clock.correct(base.Add(tc.taken)); !got.Equal(base.Add(tc.want)) {
+			t.Errorf("%d: got %s, want %s", i, got, base.Add(tc.want))
+		}
+	}
+}
+
+func TestMonotonicCore(t *testing.T) {
+	buf := &bytes.Buffer{}
+	core := newMonotonicCore(newCore(PlaintextOutput, zapcore.AddSync(buf), LevelDebug, nil))
+
+	base := time.Date(2010, 5, 23, 15, 14, 0, 0, time.UTC)
+	for _, offset := range []time.Duration{0, -time.Minute} {
+		ent := zapcore.Entry{Level: zapcore.InfoLevel, Time: base.Add(offset), Message: "entry"}
+		if ce := core.With(nil).Check(ent, nil); ce != nil {
+			ce.Write()
+		}
+	}
+
+	want := strings.Repeat("2010-05-23T15:14:00.000Z\tINFO\tentry\n", 2)
+	if got := buf.String(); got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,56 @@
+package log
+
+import (
+	"strings"
+	"sync/atomic"
+
+	"go.uber.org/zap/zapcore"
+)
+
+// muted holds the allowlist of Mute, nil when logging is not muted.
+var muted atomic.Value
+
+type muteAllowlist map[string]zapcore.Level
+
+func loadMute() muteAllowlist {
+	allow, _ := muted.Load().(muteAllowlist)
+	return allow
+}
+
+// Mute silences all loggers except the subsystems in allow, which keep
+// logging entries at or above the given level, i.e. during benchmarks where
+// logging would skew the results but errors must remain visible. The key
+// "*" allows the given level for all subsystems. Loggers derived with Named
+// follow their subsystem.
+//
+// Muting does not change the levels of subsystems, Unmute restores logging as
+// it was.
+func Mute(allow map[string]LogLevel) {
+	list := make(muteAllowlist, len(allow))
+	for name, lvl := range allow {
+		list[name] = zapcore.Level(lvl)
+	}
+	muted.Store(list)
+}
+
+// Unmute ends Mute.
+func Unmute() {
+	muted.Store(muteAllowlist(nil))
+}
+
+// allows reports whether an entry of the named logger at lvl is logged
+// while muted.
+func (allow muteAllowlist) allows(name string, lvl zapcore.Level) bool {
+	for {
+		if min, ok := allow[name]; ok {
+			return lvl >= min
+		}
+		dot := strings.LastIndexByte(name, '.')
+		if dot < 0 {
+			break
+		}
+		name = name[:dot]
+	}
+	min, ok := allow["*"]
+	return ok && lvl >= min
+}
@@ -0,0 +1,39 @@
+package log
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"go.uber.org/zap/zapcore"
+)
+
+func TestMute(t *testing.T) {
+	SetupLogging(Config{Level: LevelDebug})
+	defer SetupLogging(Config{Stderr: true})
+	buf := &bytes.Buffer{}
+	SetPrimaryCore(newCore(PlaintextOutput, zapcore.AddSync(buf), LevelDebug, nil))
+
+	Mute(map[string]LogLevel{"mute-allowed-test": LevelInfo, "*": LevelError})
+	defer Unmute()
+
+	allowed := getLogger("mute-allowed-test")
+	other := getLogger("mute-other-test")
+	allowed.Debug("muted debug")
+	allowed.Named("child").Info("allowed info")
+	other.Warn("muted warn")
+	other.Error("allowed error")
+
+	Unmute()
+	other.Debug("unmuted debug")
+
+	out := buf.String()
+	for _, msg := range []string{"allowed info", "allowed error", "unmuted debug"} {
+		if !strings.Contains(out, msg) {
+			t.Errorf("missing %q in %q", msg, out)
+		}
+	}
+	if strings.Contains(out, "\tmuted debug") || strings.Contains(out, "muted warn") {
+		t.Errorf("muted entries logged: %q", out)
+	}
+}
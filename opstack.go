@@ -0,0 +1,46 @@
+package log
+
+import (
+	"context"
+	"strings"
+)
+
+// OpStackKey is the field key of the stack of operations an entry was logged
+// in, i.e. "dht.Query > dht.findPeer > swarm.Dial", see ContextWithOp.
+const OpStackKey = "op_stack"
+
+// opStackSeparator separates the operations in the OpStackKey field.
+const opStackSeparator = " > "
+
+type opStackKey struct{}
+
+// ContextWithOp returns a copy of ctx with op pushed onto its operation
+// stack. Operations started with the returned context nest under op, see
+// WithOpStackFromContext.
+func ContextWithOp(ctx context.Context, op string) context.Context {
+	parent := OpStackFromContext(ctx)
+	stack := make([]string, len(parent), len(parent)+1)
+	copy(stack, parent)
+	return context.WithValue(ctx, opStackKey{}, append(stack, op))
+}
+
+// OpStackFromContext returns the operation stack of ctx, outermost first.
+func OpStackFromContext(ctx context.Context) []string {
+	stack, _ := ctx.Value(opStackKey{}).([]string)
+	return stack
+}
+
+// WithOpStackFromContext returns a new logger that adds the operation stack
+// of ctx to all entries, in the OpStackKey field, or l if ctx has no
+// operations.
+func WithOpStackFromContext(l *ZapEventLogger, ctx context.Context) *ZapEventLogger {
+	stack := OpStackFromContext(ctx)
+	if len(stack) == 0 {
+		return l
+	}
+	ops := strings.Join(stack, opStackSeparator)
+	copyLogger := *l
+	copyLogger.SugaredLogger = *copyLogger.SugaredLogger.With(OpStackKey, ops)
+	copyLogger.skipLogger = *copyLogger.skipLogger.With(OpStackKey, ops)
+	return &copyLogger
+}
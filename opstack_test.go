@@ -0,0 +1,39 @@
+package log
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"testing"
+
+	"go.uber.org/zap/zapcore"
+)
+
+func TestOpStack(t *testing.T) {
+	defer SetupLogging(Config{Stderr: true})
+
+	buf := &bytes.Buffer{}
+	SetupLogging(Config{Level: LevelInfo})
+	SetPrimaryCore(newCore(JSONOutput, zapcore.AddSync(buf), LevelDebug, nil))
+
+	logger := Logger("op-stack-test")
+	ctx := ContextWithOp(context.Background(), "dht.Query")
+	// siblings don't see each other.
+	ContextWithOp(ctx, "dht.provide")
+	ctx = ContextWithOp(ctx, "dht.findPeer")
+	ctx = ContextWithOp(ctx, "swarm.Dial")
+
+	WithOpStackFromContext(logger, ctx).Warn("dialing")
+
+	var entry map[string]interface{}
+	if err := json.Unmarshal(buf.Bytes(), &entry); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := entry[OpStackKey], "dht.Query > dht.findPeer > swarm.Dial"; got != want {
+		t.Errorf("got op stack %q, want %q", got, want)
+	}
+
+	if l := WithOpStackFromContext(logger, context.Background()); l != logger {
+		t.Error("got a new logger for a context without operations")
+	}
+}
@@ -0,0 +1,148 @@
+package log
+
+import (
+	"runtime"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"go.uber.org/zap/zapcore"
+)
+
+// packageLevelSet is an immutable set of package levels.
+type packageLevelSet struct {
+	exact    map[string]zapcore.Level
+	prefixes map[string]zapcore.Level // "pkg/..." patterns, without the "/..."
+	min      zapcore.Level            // most verbose level in the set
+}
+
+// packageLevels holds the active *packageLevelSet, nil when none are set.
+var packageLevels atomic.Value
+
+// packageLevelsMu serializes updates of packageLevels.
+var packageLevelsMu sync.Mutex
+
+// callerPackages caches the package of call sites, by program counter.
+var callerPackages sync.Map
+
+func loadPackageLevels() *packageLevelSet {
+	set, _ := packageLevels.Load().(*packageLevelSet)
+	return set
+}
+
+// SetPackageLogLevel sets the level of entries logged from code in the given
+// package (e.g. "github.com/ipfs/go-bitswap/network"), overriding the level of
+// the subsystem they are logged to. A path ending in "/..." matches the
+// package and all packages below it.
+//
+// Entries of subsystems with a package level set somewhere are filtered after
+// their call site is known, which makes disabled log calls slightly more
+// expensive. Use ClearPackageLogLevel to remove the level again.
+func SetPackageLogLevel(pkg, level string) error {
+	lvl, err := LevelFromString(level)
+	if err != nil {
+		return err
+	}
+
+	packageLevelsMu.Lock()
+	defer packageLevelsMu.Unlock()
+
+	updatePackageLevels(func(all map[string]zapcore.Level) {
+		all[pkg] = zapcore.Level(lvl)
+	})
+	return nil
+}
+
+// ClearPackageLogLevel removes the level set for a package by
+// SetPackageLogLevel.
+func ClearPackageLogLevel(pkg string) {
+	packageLevelsMu.Lock()
+	defer packageLevelsMu.Unlock()
+
+	updatePackageLevels(func(all map[string]zapcore.Level) {
+		delete(all, pkg)
+	})
+}
+
+// updatePackageLevels applies update to a copy of the active package levels
+// and stores the result. packageLevelsMu must be held.
+func updatePackageLevels(update func(map[string]zapcore.Level)) {
+	all := make(map[string]zapcore.Level)
+	if old := loadPackageLevels(); old != nil {
+		for pkg, lvl := range old.exact {
+			all[pkg] = lvl
+		}
+		for pkg, lvl := range old.prefixes {
+			all[pkg+"/..."] = lvl
+		}
+	}
+	update(all)
+
+	if len(all) == 0 {
+		packageLevels.Store((*packageLevelSet)(nil))
+		return
+	}
+	set := &packageLevelSet{
+		exact:    make(map[string]zapcore.Level),
+		prefixes: make(map[string]zapcore.Level),
+		min:      zapcore.FatalLevel,
+	}
+	for pkg, lvl := range all {
+		if prefix := strings.TrimSuffix(pkg, "/..."); prefix != pkg {
+			set.prefixes[prefix] = lvl
+		} else {
+			set.exact[pkg] = lvl
+		}
+		if lvl < set.min {
+			set.min = lvl
+		}
+	}
+	packageLevels.Store(set)
+}
+
+// level returns the level set for the package of the given call site.
+func (s *packageLevelSet) level(caller zapcore.EntryCaller) (zapcore.Level, bool) {
+	if !caller.Defined {
+		return 0, false
+	}
+	pkg := callerPackage(caller.PC)
+	if lvl, ok := s.exact[pkg]; ok {
+		return lvl, true
+	}
+	// the longest matching prefix wins.
+	for p := pkg; ; {
+		if lvl, ok := s.prefixes[p]; ok {
+			return lvl, true
+		}
+		slash := strings.LastIndexByte(p, '/')
+		if slash < 0 {
+			return 0, false
+		}
+		p = p[:slash]
+	}
+}
+
+// callerPackage returns the import path of the package containing pc.
+func callerPackage(pc uintptr) string {
+	if pkg, ok := callerPackages.Load(pc); ok {
+		return pkg.(string)
+	}
+
+	var pkg string
+	if fn := runtime.FuncForPC(pc); fn != nil {
+		pkg = packageOfFunc(fn.Name())
+	}
+	callerPackages.Store(pc, pkg)
+	return pkg
+}
+
+// packageOfFunc extracts the import path from a fully qualified function name
+// such as "github.com/ipfs/go-log/v2.(*ZapEventLogger).Warning".
+func packageOfFunc(name string) string {
+	slash := strings.LastIndexByte(name, '/')
+	if dot := strings.IndexByte(name[slash+1:], '.'); dot >= 0 {
+		name = name[:slash+1+dot]
+	}
+	// the linker escapes dots in the last path element.
+	return strings.ReplaceAll(name, "%2e", ".")
+}
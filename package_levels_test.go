@@ -0,0 +1,73 @@
+//go:build !golog_discard
+// +build !golog_discard
+
+package log
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"go.uber.org/zap/zapcore"
+)
+
+func TestPackageOfFunc(t *testing.T) {
+	testCases := map[string]string{
+		"github.com/ipfs/go-log/v2.(*ZapEventLogger).Warning": "github.com/ipfs/go-log/v2",
+		"github.com/ipfs/go-log/v2.TestPackageOfFunc.func1":   "github.com/ipfs/go-log/v2",
+		"gopkg.in/yaml%2ev2.Unmarshal":                        "gopkg.in/yaml.v2",
+		"main.main":                                           "main",
+	}
+	for name, want := range testCases {
+		if got := packageOfFunc(name); got != want {
+			t.Errorf("%s: got %q, want %q", name, got, want)
+		}
+	}
+}
+
+func TestPackageLogLevel(t *testing.T) {
+	defer SetupLogging(Config{Stderr: true})
+
+	buf := &bytes.Buffer{}
+	SetupLogging(Config{Level: LevelError})
+	SetPrimaryCore(newCore(PlaintextOutput, zapcore.AddSync(buf), LevelDebug, nil))
+
+	log := Logger("package-level-test")
+	log.Debug("before")
+
+	const pkg = "github.com/ipfs/go-log/v2"
+	if err := SetPackageLogLevel(pkg, "debug"); err != nil {
+		t.Fatal(err)
+	}
+	log.Debug("exact")
+
+	ClearPackageLogLevel(pkg)
+	if err := SetPackageLogLevel("github.com/ipfs/...", "debug"); err != nil {
+		t.Fatal(err)
+	}
+	log.Debug("prefix")
+
+	if err := SetPackageLogLevel("github.com/other/...", "debug"); err != nil {
+		t.Fatal(err)
+	}
+	if err := SetPackageLogLevel(pkg, "fatal"); err != nil {
+		t.Fatal(err)
+	}
+	log.Error("silenced")
+
+	ClearPackageLogLevel(pkg)
+	ClearPackageLogLevel("github.com/ipfs/...")
+	log.Debug("cleared")
+
+	out := buf.String()
+	for _, want := range []string{"exact", "prefix"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("got %q, wanted it to contain %q", out, want)
+		}
+	}
+	for _, unwanted := range []string{"before", "silenced", "cleared"} {
+		if strings.Contains(out, unwanted) {
+			t.Errorf("got %q, wanted it to not contain %q", out, unwanted)
+		}
+	}
+}
@@ -47,12 +47,16 @@ func NewPipeReader(opts ...PipeReaderOption) *PipeReader {
 		o.setOption(&opt)
 	}
 
+	loggerMutex.RLock()
+	loc := config.TimeLocation
+	loggerMutex.RUnlock()
+
 	r, w := io.Pipe()
 
 	p := &PipeReader{
 		r:      r,
 		closer: w,
-		core:   newCore(opt.format, zapcore.AddSync(w), opt.level),
+		core:   newCore(opt.format, zapcore.AddSync(w), opt.level, loc),
 	}
 
 	loggerCore.AddCore(p.core)
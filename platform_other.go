@@ -0,0 +1,14 @@
+//go:build !(js && wasm) && !(android && cgo) && !(ios && cgo)
+// +build !js !wasm
+// +build !android !cgo
+// +build !ios !cgo
+
+package log
+
+import "go.uber.org/zap/zapcore"
+
+// platformOutputs replaces the outputs of cfg that don't exist on the
+// platform. All outputs exist on this platform.
+func platformOutputs(cfg Config) (Config, zapcore.Core) {
+	return cfg, nil
+}
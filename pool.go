@@ -0,0 +1,202 @@
+package log
+
+import (
+	"sync"
+
+	"go.uber.org/multierr"
+	"go.uber.org/zap/buffer"
+	"go.uber.org/zap/zapcore"
+)
+
+// encodePool encodes entries on a bounded set of workers.
+type encodePool struct {
+	mu     sync.RWMutex // guards closed against concurrent submits
+	closed bool
+	jobs   chan encodeJob
+	wg     sync.WaitGroup
+}
+
+type encodeJob struct {
+	sink   *orderedSink
+	seq    uint64
+	enc    zapcore.Encoder
+	ent    zapcore.Entry
+	fields []zapcore.Field
+}
+
+func newEncodePool(workers int) *encodePool {
+	p := &encodePool{
+		jobs: make(chan encodeJob, workers*64),
+	}
+	p.wg.Add(workers)
+	for i := 0; i < workers; i++ {
+		go p.work()
+	}
+	return p
+}
+
+func (p *encodePool) work() {
+	defer p.wg.Done()
+	for job := range p.jobs {
+		buf, err := job.enc.EncodeEntry(job.ent, job.fields)
+		job.sink.deliver(job.seq, buf, err)
+	}
+}
+
+// submit queues an entry for encoding. It reports false if the pool was
+// stopped, in which case the caller has to encode the entry itself.
+func (p *encodePool) submit(job encodeJob) bool {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	if p.closed {
+		return false
+	}
+	p.jobs <- job
+	return true
+}
+
+// Stop encodes the queued entries and stops the workers.
+func (p *encodePool) Stop() error {
+	p.mu.Lock()
+	p.closed = true
+	close(p.jobs)
+	p.mu.Unlock()
+
+	p.wg.Wait()
+	return nil
+}
+
+// orderedSink writes encoded entries in the order they were submitted,
+// regardless of the order in which they finish encoding.
+type orderedSink struct {
+	mu      sync.Mutex
+	done    *sync.Cond // signaled when entries are written
+	ws      zapcore.WriteSyncer
+	next    uint64 // sequence number of the next submitted entry
+	written uint64 // sequence number of the next entry to write
+	pending map[uint64]*buffer.Buffer
+	err     error // write and encoding errors since the last Sync
+}
+
+func newOrderedSink(ws zapcore.WriteSyncer) *orderedSink {
+	s := &orderedSink{
+		ws:      ws,
+		pending: make(map[uint64]*buffer.Buffer),
+	}
+	s.done = sync.NewCond(&s.mu)
+	return s
+}
+
+// reserve returns the sequence number for a new entry.
+func (s *orderedSink) reserve() uint64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	seq := s.next
+	s.next++
+	return seq
+}
+
+// deliver hands over an encoded entry and writes all entries that are due.
+func (s *orderedSink) deliver(seq uint64, buf *buffer.Buffer, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.err = multierr.Append(s.err, err)
+	// failed entries are skipped, but still take up their slot.
+	s.pending[seq] = buf
+	for {
+		buf, ok := s.pending[s.written]
+		if !ok {
+			break
+		}
+		delete(s.pending, s.written)
+		s.written++
+		if buf != nil {
+			_, werr := s.ws.Write(buf.Bytes())
+			s.err = multierr.Append(s.err, werr)
+			buf.Free()
+		}
+	}
+	s.done.Broadcast()
+}
+
+// Sync waits until all entries submitted so far are written and syncs the
+// output. It returns the errors that occurred since the last Sync.
+func (s *orderedSink) Sync() error {
+	s.mu.Lock()
+	target := s.next
+	for s.written < target {
+		s.done.Wait()
+	}
+	err := s.err
+	s.err = nil
+	s.mu.Unlock()
+
+	return multierr.Append(err, s.ws.Sync())
+}
+
+var _ zapcore.Core = (*pooledCore)(nil)
+
+// pooledCore encodes entries on an encodePool and writes them to an
+// orderedSink.
+type pooledCore struct {
+	zapcore.LevelEnabler
+	enc  zapcore.Encoder
+	sink *orderedSink
+	pool *encodePool
+}
+
+func newPooledCore(enc zapcore.Encoder, ws zapcore.WriteSyncer, pool *encodePool) *pooledCore {
+	return &pooledCore{
+		LevelEnabler: zapcore.DebugLevel,
+		enc:          enc,
+		sink:         newOrderedSink(ws),
+		pool:         pool,
+	}
+}
+
+func (c *pooledCore) With(fields []zapcore.Field) zapcore.Core {
+	enc := c.enc.Clone()
+	for i := range fields {
+		fields[i].AddTo(enc)
+	}
+	return &pooledCore{
+		LevelEnabler: c.LevelEnabler,
+		enc:          enc,
+		sink:         c.sink,
+		pool:         c.pool,
+	}
+}
+
+func (c *pooledCore) Check(ent zapcore.Entry, ce *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if c.Enabled(ent.Level) {
+		return ce.AddCore(ent, c)
+	}
+	return ce
+}
+
+func (c *pooledCore) Write(ent zapcore.Entry, fields []zapcore.Field) error {
+	job := encodeJob{
+		sink:   c.sink,
+		seq:    c.sink.reserve(),
+		enc:    c.enc,
+		ent:    ent,
+		fields: snapshotFields(fields),
+	}
+	if !c.pool.submit(job) {
+		buf, err := job.enc.EncodeEntry(job.ent, job.fields)
+		job.sink.deliver(job.seq, buf, err)
+	}
+
+	if ent.Level > zapcore.ErrorLevel {
+		// Since we may be crashing the program, sync the output.
+		return c.Sync()
+	}
+	return nil
+}
+
+func (c *pooledCore) Sync() error {
+	return c.sink.Sync()
+}
@@ -0,0 +1,106 @@
+package log
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// slowMarshaler takes longer to encode the lower its index is, so that
+// entries finish encoding out of order.
+type slowMarshaler int
+
+func (m slowMarshaler) MarshalLogObject(enc zapcore.ObjectEncoder) error {
+	time.Sleep(time.Duration(10-m) * time.Millisecond)
+	enc.AddInt("i", int(m))
+	return nil
+}
+
+func TestPooledCoreOrdering(t *testing.T) {
+	pool := newEncodePool(4)
+	defer pool.Stop()
+
+	buf := &bytes.Buffer{}
+	core := newPooledCore(newEncoder(PlaintextOutput, nil), zapcore.AddSync(buf), pool)
+	log := zap.New(core).With(zap.String("sink", "test"))
+
+	for i := 0; i < 10; i++ {
+		log.Info(fmt.Sprint("entry-", i), zap.Object("obj", slowMarshaler(i)))
+	}
+	if err := log.Sync(); err != nil {
+		t.Fatal(err)
+	}
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 10 {
+		t.Fatalf("got %d lines, want 10: %q", len(lines), buf.String())
+	}
+	for i, line := range lines {
+		if !strings.Contains(line, fmt.Sprintf("entry-%d\t", i)) || !strings.Contains(line, `"sink": "test"`) {
+			t.Errorf("line %d: got %q", i, line)
+		}
+	}
+}
+
+func TestPooledCoreConcurrent(t *testing.T) {
+	pool := newEncodePool(4)
+
+	buf := &bytes.Buffer{}
+	log := zap.New(newPooledCore(newEncoder(JSONOutput, nil), zapcore.AddSync(buf), pool))
+
+	var wg sync.WaitGroup
+	for g := 0; g < 8; g++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < 100; i++ {
+				log.Info("scooby")
+			}
+		}()
+	}
+	wg.Wait()
+
+	// entries logged after the pool was stopped are encoded inline.
+	pool.Stop()
+	log.Info("doo")
+
+	if err := log.Sync(); err != nil {
+		t.Fatal(err)
+	}
+	if n := strings.Count(buf.String(), "\n"); n != 801 {
+		t.Errorf("got %d entries, want 801", n)
+	}
+}
+
+func TestLogWithEncoderWorkers(t *testing.T) {
+	logfile, err := ioutil.TempFile("", "go-log-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(logfile.Name())
+
+	SetupLogging(Config{File: logfile.Name(), EncoderWorkers: 2})
+	defer SetupLogging(Config{Stderr: true})
+
+	log := getLogger("test")
+	log.Error("grokgrokgrok")
+	if err := log.Sync(); err != nil {
+		t.Fatal(err)
+	}
+
+	content, err := ioutil.ReadFile(logfile.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(content), "grokgrokgrok") {
+		t.Errorf("got %q, wanted it to contain log output", content)
+	}
+}
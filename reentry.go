@@ -0,0 +1,89 @@
+package log
+
+import (
+	"bytes"
+	"os"
+	"runtime"
+	"strconv"
+	"sync"
+	"sync/atomic"
+
+	"go.uber.org/zap/zapcore"
+)
+
+// detectReentry is 1 when reentrant log calls are detected, see
+// Config.DetectReentrantLogging.
+var detectReentry int32
+
+// writingGoroutines is the set of goroutines writing an entry, by ID.
+var writingGoroutines sync.Map
+
+// fallbackEncoder encodes entries written to stderr by reentrant log calls.
+var fallbackEncoder = newEncoder(PlaintextOutput, nil)
+
+var _ zapcore.Core = (*reentrantCore)(nil)
+
+// reentrantCore detects log calls made by a goroutine that is already writing
+// an entry, e.g. from an output or hook that logs itself. Such entries are
+// written straight to stderr, rather than deadlocking on the locks of the
+// outputs or recursing forever. Detection obtains the goroutine ID from a
+// stack trace for every entry, so it is only done when enabled; otherwise
+// the core passes entries through.
+type reentrantCore struct {
+	next zapcore.Core
+}
+
+func (c *reentrantCore) With(fields []zapcore.Field) zapcore.Core {
+	return &reentrantCore{next: c.next.With(fields)}
+}
+
+func (c *reentrantCore) Enabled(lvl zapcore.Level) bool {
+	return c.next.Enabled(lvl)
+}
+
+func (c *reentrantCore) Check(ent zapcore.Entry, ce *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if atomic.LoadInt32(&detectReentry) == 0 {
+		return c.next.Check(ent, ce)
+	}
+	if c.next.Enabled(ent.Level) {
+		return ce.AddCore(ent, c)
+	}
+	return ce
+}
+
+func (c *reentrantCore) Write(ent zapcore.Entry, fields []zapcore.Field) error {
+	if atomic.LoadInt32(&detectReentry) == 0 {
+		return c.next.Write(ent, fields)
+	}
+	id := goroutineID()
+	if _, writing := writingGoroutines.LoadOrStore(id, struct{}{}); writing {
+		buf, err := fallbackEncoder.EncodeEntry(ent, fields)
+		if err != nil {
+			return err
+		}
+		defer buf.Free()
+		_, err = os.Stderr.Write(buf.Bytes())
+		return err
+	}
+	// the mark is removed even if an output panics.
+	defer writingGoroutines.Delete(id)
+
+	return c.next.Write(ent, fields)
+}
+
+func (c *reentrantCore) Sync() error {
+	return c.next.Sync()
+}
+
+// goroutineID returns the ID of the calling goroutine, parsed from the header
+// of its stack trace ("goroutine 18 [running]:").
+func goroutineID() uint64 {
+	var buf [64]byte
+	b := buf[:runtime.Stack(buf[:], false)]
+	b = bytes.TrimPrefix(b, []byte("goroutine "))
+	if i := bytes.IndexByte(b, ' '); i >= 0 {
+		b = b[:i]
+	}
+	id, _ := strconv.ParseUint(string(b), 10, 64)
+	return id
+}
@@ -0,0 +1,104 @@
+package log
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// loggingWriteSyncer logs from within Write while holding its lock.
+type loggingWriteSyncer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (w *loggingWriteSyncer) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	getLogger("reentry-test").Error("from the output")
+	return w.buf.Write(p)
+}
+
+func (w *loggingWriteSyncer) Sync() error { return nil }
+
+func TestReentrantLogging(t *testing.T) {
+	defer SetupLogging(Config{Stderr: true})
+
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	stderr := os.Stderr
+	os.Stderr = w
+	defer func() { os.Stderr = stderr }()
+
+	ws := &loggingWriteSyncer{}
+	SetupLogging(Config{Level: LevelError, DetectReentrantLogging: true})
+	SetPrimaryCore(newCore(PlaintextOutput, ws, LevelDebug, nil))
+
+	getLogger("reentry-test").Error("outer")
+
+	os.Stderr = stderr
+	w.Close()
+	fallback, err := ioutil.ReadAll(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if out := ws.buf.String(); !strings.Contains(out, "outer") || strings.Contains(out, "from the output") {
+		t.Errorf("output got %q", out)
+	}
+	if !strings.Contains(string(fallback), "from the output") {
+		t.Errorf("stderr got %q, want the reentrant entry", fallback)
+	}
+}
+
+func TestWriteErrorReported(t *testing.T) {
+	defer SetupLogging(Config{Stderr: true})
+
+	for _, detect := range []bool{false, true} {
+		r, w, err := os.Pipe()
+		if err != nil {
+			t.Fatal(err)
+		}
+		stderr := os.Stderr
+		os.Stderr = w
+
+		SetupLogging(Config{Level: LevelError, DetectReentrantLogging: detect})
+		SetPrimaryCore(newCore(PlaintextOutput, failingWriteSyncer{}, LevelDebug, nil))
+		// the error output of a logger is stderr when it is created.
+		name := fmt.Sprintf("write-error-test-%v", detect)
+		getLogger(name).Error("lost")
+		// written by levelCore once the caller is known.
+		SetPackageLogLevel("github.com/ipfs/go-log/v2", "error")
+		getLogger(name).Error("lost")
+		ClearPackageLogLevel("github.com/ipfs/go-log/v2")
+
+		os.Stderr = stderr
+		w.Close()
+		out, err := ioutil.ReadAll(r)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if n := strings.Count(string(out), "write error: disk full"); n != 2 {
+			t.Errorf("detecting reentry %v: got %d write errors in %q", detect, n, out)
+		}
+	}
+}
+
+func TestGoroutineID(t *testing.T) {
+	id := goroutineID()
+	if id == 0 {
+		t.Fatal("got no goroutine ID")
+	}
+	other := make(chan uint64)
+	go func() { other <- goroutineID() }()
+	if id == <-other {
+		t.Error("goroutines got the same ID")
+	}
+}
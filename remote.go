@@ -0,0 +1,308 @@
+package log
+
+import (
+	"context"
+	"crypto/ed25519"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ErrInvalidSignature is returned when a remote config is not signed by the
+// expected key.
+var ErrInvalidSignature = errors.New("invalid remote config signature")
+
+// maxRemoteConfigSize bounds the size of a remote config document.
+const maxRemoteConfigSize = 1 << 20
+
+// RemoteLevelConfig is a set of levels distributed to a fleet of nodes. Level
+// strings are parsed with LevelFromString.
+type RemoteLevelConfig struct {
+	// Version must increase with every published config. Configs with a
+	// version lower than or equal to the last applied one are ignored, which
+	// prevents replaying old signed configs. The applied version is only
+	// kept across restarts with RemoteConfigStateFile.
+	Version uint64 `json:"version"`
+
+	// Level, when set, is applied to all subsystems before Subsystems.
+	Level string `json:"level,omitempty"`
+
+	// Subsystems are per-subsystem levels.
+	Subsystems map[string]string `json:"subsystems,omitempty"`
+
+	// Sampling, unless missing or null, replaces the sampling of the
+	// previous remote config; an empty list removes it. It is applied after
+	// Config.Rules.
+	Sampling []RemoteSampling `json:"sampling"`
+}
+
+// RemoteSampling keeps one in Rate entries matching Level and Subsystem and
+// discards the others, as a Rule with SampleAction.
+type RemoteSampling struct {
+	Level     string `json:"level,omitempty"`
+	Subsystem string `json:"subsystem,omitempty"`
+	Rate      int    `json:"rate"`
+}
+
+// signedRemoteConfig is the document served at the remote config URL.
+type signedRemoteConfig struct {
+	Config    json.RawMessage `json:"config"`
+	Signature []byte          `json:"signature"`
+}
+
+// SignRemoteConfig encodes and signs a config for distribution by a
+// RemoteConfigPuller.
+func SignRemoteConfig(cfg RemoteLevelConfig, key ed25519.PrivateKey) ([]byte, error) {
+	raw, err := json.Marshal(cfg)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(signedRemoteConfig{
+		Config:    raw,
+		Signature: ed25519.Sign(key, raw),
+	})
+}
+
+// RemoteConfigPuller periodically fetches a signed RemoteLevelConfig from a
+// URL and applies it. The URL can point to an IPFS gateway to distribute the
+// config through an IPNS name, i.e. https://ipfs.io/ipns/<name>.
+type RemoteConfigPuller struct {
+	url      string
+	key      ed25519.PublicKey
+	client   *http.Client
+	interval time.Duration
+	state    string // path of the file keeping version, if any
+
+	mu      sync.Mutex // guards version
+	version uint64
+
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+// StartRemoteConfig starts pulling the config at url, which must be signed by
+// key. The config is fetched immediately and then at every interval. The
+// caller must call Close on the returned puller when done.
+func StartRemoteConfig(url string, key ed25519.PublicKey, opts ...RemoteConfigOption) (*RemoteConfigPuller, error) {
+	opt := remoteConfigOptions{
+		interval: time.Minute,
+		client:   &http.Client{Timeout: 30 * time.Second},
+	}
+
+	for _, o := range opts {
+		o.setOption(&opt)
+	}
+	if len(key) != ed25519.PublicKeySize {
+		return nil, fmt.Errorf("remote config key has %d bytes, want %d", len(key), ed25519.PublicKeySize)
+	}
+	if opt.interval <= 0 {
+		return nil, fmt.Errorf("invalid remote config interval %s", opt.interval)
+	}
+	var version uint64
+	if opt.state != "" {
+		var err error
+		if version, err = readRemoteConfigVersion(opt.state); err != nil {
+			return nil, err
+		}
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	p := &RemoteConfigPuller{
+		url:      url,
+		key:      key,
+		client:   opt.client,
+		interval: opt.interval,
+		state:    opt.state,
+		version:  version,
+		cancel:   cancel,
+		done:     make(chan struct{}),
+	}
+	go p.loop(ctx)
+	return p, nil
+}
+
+// readRemoteConfigVersion reads the version kept in a state file, 0 when the
+// file does not exist yet.
+func readRemoteConfigVersion(path string) (uint64, error) {
+	data, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	version, err := strconv.ParseUint(strings.TrimSpace(string(data)), 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid remote config state file %s: %w", path, err)
+	}
+	return version, nil
+}
+
+// Close stops pulling the config. Applied levels stay in effect.
+func (p *RemoteConfigPuller) Close() error {
+	p.cancel()
+	<-p.done
+	return nil
+}
+
+// Pull fetches and applies the config right away.
+func (p *RemoteConfigPuller) Pull(ctx context.Context) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, p.url, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close() // nolint:errcheck
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("fetching remote config: %s", resp.Status)
+	}
+	body, err := ioutil.ReadAll(io.LimitReader(resp.Body, maxRemoteConfigSize))
+	if err != nil {
+		return err
+	}
+
+	var signed signedRemoteConfig
+	if err := json.Unmarshal(body, &signed); err != nil {
+		return err
+	}
+	if !ed25519.Verify(p.key, signed.Config, signed.Signature) {
+		return ErrInvalidSignature
+	}
+	var cfg RemoteLevelConfig
+	if err := json.Unmarshal(signed.Config, &cfg); err != nil {
+		return err
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if cfg.Version <= p.version {
+		return nil
+	}
+	if err := applyRemoteLevels(cfg); err != nil {
+		return err
+	}
+	p.version = cfg.Version
+	LogConfig()
+	if p.state != "" {
+		data := []byte(strconv.FormatUint(cfg.Version, 10) + "\n")
+		if err := writeFileAtomic(filepath.Dir(p.state), filepath.Base(p.state), data); err != nil {
+			return fmt.Errorf("keeping remote config version: %w", err)
+		}
+	}
+	return nil
+}
+
+func (p *RemoteConfigPuller) loop(ctx context.Context) {
+	defer close(p.done)
+
+	ticker := time.NewTicker(p.interval)
+	defer ticker.Stop()
+
+	for {
+		if err := p.Pull(ctx); err != nil && ctx.Err() == nil {
+			getLogger("remote-config").Errorf("failed to pull log config from %s: %s", p.url, err)
+		}
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// applyRemoteLevels validates all levels and sampling rules of cfg before
+// applying any of them.
+func applyRemoteLevels(cfg RemoteLevelConfig) error {
+	var all *LogLevel
+	if cfg.Level != "" {
+		lvl, err := LevelFromString(cfg.Level)
+		if err != nil {
+			return err
+		}
+		all = &lvl
+	}
+	subsystems := make(map[string]LogLevel, len(cfg.Subsystems))
+	for name, level := range cfg.Subsystems {
+		lvl, err := LevelFromString(level)
+		if err != nil {
+			return fmt.Errorf("subsystem %q: %w", name, err)
+		}
+		subsystems[name] = lvl
+	}
+	var sampling []Rule
+	for _, s := range cfg.Sampling {
+		sampling = append(sampling, Rule{Level: s.Level, Subsystem: s.Subsystem, Action: SampleAction, SampleRate: s.Rate})
+	}
+
+	loggerMutex.Lock()
+	defer loggerMutex.Unlock()
+
+	if cfg.Sampling != nil {
+		compiled, err := compileRules(append(config.Rules[:len(config.Rules):len(config.Rules)], sampling...), config)
+		if err != nil {
+			return fmt.Errorf("sampling: %w", err)
+		}
+		remoteRules = sampling
+		replaceRules(compiled)
+	}
+	if all != nil {
+		setAllLoggers(*all)
+	}
+	for name, lvl := range subsystems {
+		setSubsystemLevel(name, lvl)
+	}
+	return nil
+}
+
+type remoteConfigOptions struct {
+	interval time.Duration
+	client   *http.Client
+	state    string
+}
+
+type RemoteConfigOption interface {
+	setOption(*remoteConfigOptions)
+}
+
+type remoteConfigOptionFunc func(*remoteConfigOptions)
+
+func (r remoteConfigOptionFunc) setOption(o *remoteConfigOptions) {
+	r(o)
+}
+
+// RemoteConfigInterval sets how often the remote config is fetched. Defaults
+// to one minute.
+func RemoteConfigInterval(interval time.Duration) RemoteConfigOption {
+	return remoteConfigOptionFunc(func(o *remoteConfigOptions) {
+		o.interval = interval
+	})
+}
+
+// RemoteConfigClient sets the HTTP client used to fetch the remote config.
+func RemoteConfigClient(client *http.Client) RemoteConfigOption {
+	return remoteConfigOptionFunc(func(o *remoteConfigOptions) {
+		o.client = client
+	})
+}
+
+// RemoteConfigStateFile keeps the version of the last applied config in the
+// file at path, so that old signed configs are not applied again after a
+// restart.
+func RemoteConfigStateFile(path string) RemoteConfigOption {
+	return remoteConfigOptionFunc(func(o *remoteConfigOptions) {
+		o.state = path
+	})
+}
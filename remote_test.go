@@ -0,0 +1,194 @@
+package log
+
+import (
+	"bytes"
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"go.uber.org/zap/zapcore"
+)
+
+type remoteConfigServer struct {
+	mu   sync.Mutex
+	body []byte
+}
+
+func (s *remoteConfigServer) set(t *testing.T, cfg RemoteLevelConfig, key ed25519.PrivateKey) {
+	body, err := SignRemoteConfig(cfg, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.mu.Lock()
+	s.body = body
+	s.mu.Unlock()
+}
+
+func (s *remoteConfigServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, _ = w.Write(s.body)
+}
+
+func TestRemoteConfigPull(t *testing.T) {
+	pub, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	const subsystem = "remote-config-test"
+	getLogger(subsystem)
+
+	srv := &remoteConfigServer{}
+	srv.set(t, RemoteLevelConfig{Version: 2, Subsystems: map[string]string{subsystem: "debug"}}, priv)
+	ts := httptest.NewServer(srv)
+	defer ts.Close()
+
+	dir, err := ioutil.TempDir("", "go-log-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	state := filepath.Join(dir, "remote-config-version")
+
+	p, err := StartRemoteConfig(ts.URL, pub, RemoteConfigInterval(time.Hour), RemoteConfigStateFile(state))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer p.Close()
+
+	if err := p.Pull(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if lvl := subsystemLevel(subsystem); lvl != zapcore.DebugLevel {
+		t.Errorf("got level %s, want debug", lvl)
+	}
+
+	// older versions are ignored.
+	srv.set(t, RemoteLevelConfig{Version: 1, Subsystems: map[string]string{subsystem: "error"}}, priv)
+	if err := p.Pull(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if lvl := subsystemLevel(subsystem); lvl != zapcore.DebugLevel {
+		t.Errorf("got level %s, want debug", lvl)
+	}
+
+	// configs signed by someone else are rejected.
+	_, otherPriv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv.set(t, RemoteLevelConfig{Version: 3, Subsystems: map[string]string{subsystem: "error"}}, otherPriv)
+	if err := p.Pull(context.Background()); err != ErrInvalidSignature {
+		t.Errorf("got error %v, want %v", err, ErrInvalidSignature)
+	}
+	if lvl := subsystemLevel(subsystem); lvl != zapcore.DebugLevel {
+		t.Errorf("got level %s, want debug", lvl)
+	}
+
+	srv.set(t, RemoteLevelConfig{Version: 4, Level: "error"}, priv)
+	if err := p.Pull(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if lvl := subsystemLevel(subsystem); lvl != zapcore.ErrorLevel {
+		t.Errorf("got level %s, want error", lvl)
+	}
+
+	// the version is kept across restarts.
+	p.Close()
+	srv.set(t, RemoteLevelConfig{Version: 2, Subsystems: map[string]string{subsystem: "debug"}}, priv)
+	p, err = StartRemoteConfig(ts.URL, pub, RemoteConfigInterval(time.Hour), RemoteConfigStateFile(state))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer p.Close()
+	if err := p.Pull(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if lvl := subsystemLevel(subsystem); lvl != zapcore.ErrorLevel {
+		t.Errorf("got level %s after a restart, want error", lvl)
+	}
+}
+
+func TestStartRemoteConfigInvalid(t *testing.T) {
+	pub, _, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := StartRemoteConfig("http://localhost", pub[:16]); err == nil {
+		t.Error("accepted a short key")
+	}
+	if _, err := StartRemoteConfig("http://localhost", pub, RemoteConfigInterval(0)); err == nil {
+		t.Error("accepted a zero interval")
+	}
+}
+
+func TestRemoteConfigSampling(t *testing.T) {
+	defer SetupLogging(Config{Stderr: true})
+	defer func() {
+		loggerMutex.Lock()
+		remoteRules = nil
+		loggerMutex.Unlock()
+	}()
+
+	buf := &bytes.Buffer{}
+	SetupLogging(Config{Level: LevelDebug, Rules: []Rule{{Subsystem: "remote-sampling-dropped", Action: DropAction}}})
+	SetPrimaryCore(&rulesCore{next: newCore(PlaintextOutput, zapcore.AddSync(buf), LevelDebug, nil)})
+
+	sampling := []RemoteSampling{{Subsystem: "remote-sampling-test", Rate: 2}}
+	if err := applyRemoteLevels(RemoteLevelConfig{Sampling: sampling}); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 4; i++ {
+		getLogger("remote-sampling-test").Info("sampled")
+	}
+	getLogger("remote-sampling-dropped").Info("dropped")
+	if n := strings.Count(buf.String(), "sampled"); n != 2 || strings.Contains(buf.String(), "dropped") {
+		t.Errorf("got %q, want 2 sampled entries", buf.String())
+	}
+
+	// the sampling is kept by SetRules.
+	if err := SetRules(nil); err != nil {
+		t.Fatal(err)
+	}
+	if rs := loadRules(); len(rs) != 1 || rs[0].Action != SampleAction {
+		t.Errorf("got rules %v", rs)
+	}
+
+	// an invalid config is not applied at all.
+	if err := applyRemoteLevels(RemoteLevelConfig{Level: "warn", Sampling: []RemoteSampling{{Rate: 0}}}); err == nil {
+		t.Error("accepted a zero sample rate")
+	}
+	if lvl := subsystemLevel("remote-sampling-test"); lvl != zapcore.DebugLevel {
+		t.Errorf("got level %s, want debug", lvl)
+	}
+
+	// a config without sampling keeps it, an empty list removes it.
+	if err := applyRemoteLevels(RemoteLevelConfig{Level: "debug"}); err != nil {
+		t.Fatal(err)
+	}
+	if len(loadRules()) != 1 {
+		t.Error("sampling removed by a config without sampling")
+	}
+	if err := applyRemoteLevels(RemoteLevelConfig{Sampling: []RemoteSampling{}}); err != nil {
+		t.Fatal(err)
+	}
+	if rs := loadRules(); len(rs) != 0 {
+		t.Errorf("got rules %v after removing the sampling", rs)
+	}
+}
+
+func subsystemLevel(name string) zapcore.Level {
+	loggerMutex.RLock()
+	defer loggerMutex.RUnlock()
+	return levels[name].Level()
+}
@@ -0,0 +1,137 @@
+// Package replay re-emits captured log output through the logging pipeline,
+// i.e. to load-test outputs or to reproduce bugs in log processing with real
+// data:
+//
+//	f, _ := os.Open("captured.log")
+//	n, err := replay.Replay(ctx, logparse.NewDecoder(f), replay.Options{Speed: 10})
+//
+// Entries are logged through the loggers of their original subsystems, so
+// levels, rules and outputs apply to them as configured.
+package replay
+
+import (
+	"context"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	log "github.com/ipfs/go-log/v2"
+	"github.com/ipfs/go-log/v2/logparse"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// fallbackSubsystem is the subsystem of entries captured without a logger
+// name.
+const fallbackSubsystem = "replay"
+
+// Options configure Replay.
+type Options struct {
+	// Speed scales the original timing of the entries, i.e. 2 replays them
+	// twice as fast. When zero, entries are replayed without delays.
+	Speed float64
+
+	// Retime logs entries with the time they are replayed at instead of
+	// their original time.
+	Retime bool
+}
+
+// Replay logs the entries read from dec in order, spaced as in the original
+// capture scaled by opts.Speed, until dec is exhausted or ctx is done. It
+// returns the number of entries replayed.
+func Replay(ctx context.Context, dec *logparse.Decoder, opts Options) (int, error) {
+	var first time.Time // time of the first entry with one
+	var start time.Time // when it was replayed
+
+	n := 0
+	for {
+		e, err := dec.Next()
+		if err == io.EOF {
+			return n, nil
+		}
+		if err != nil {
+			return n, err
+		}
+
+		if opts.Speed > 0 && !e.Time.IsZero() {
+			if first.IsZero() {
+				first, start = e.Time, time.Now()
+			} else if delay := time.Until(start.Add(time.Duration(float64(e.Time.Sub(first)) / opts.Speed))); delay > 0 {
+				timer := time.NewTimer(delay)
+				select {
+				case <-timer.C:
+				case <-ctx.Done():
+					timer.Stop()
+					return n, ctx.Err()
+				}
+			}
+		}
+		if err := ctx.Err(); err != nil {
+			return n, err
+		}
+
+		emit(e, opts.Retime)
+		n++
+	}
+}
+
+// emit logs e through the logger of its subsystem.
+func emit(e logparse.Entry, retime bool) {
+	var lvl zapcore.Level
+	if err := lvl.UnmarshalText([]byte(e.Level)); err != nil {
+		lvl = zapcore.InfoLevel
+	}
+	name := e.Logger
+	if name == "" {
+		name = fallbackSubsystem
+	}
+
+	ent := zapcore.Entry{
+		Level:      lvl,
+		Time:       e.Time,
+		LoggerName: name,
+		Message:    e.Message,
+		Caller:     parseCaller(e.Caller),
+		Stack:      e.Stacktrace,
+	}
+	if retime || ent.Time.IsZero() {
+		ent.Time = time.Now()
+	}
+
+	// writing to the core directly keeps the original caller and does not
+	// panic or exit on panic and fatal entries.
+	core := log.Logger(name).Desugar().Core()
+	if ce := core.Check(ent, nil); ce != nil {
+		ce.Write(fields(e.Fields)...)
+	}
+}
+
+// fields converts decoded fields, in the order of their keys.
+func fields(values map[string]interface{}) []zapcore.Field {
+	keys := make([]string, 0, len(values))
+	for k := range values {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	fields := make([]zapcore.Field, 0, len(keys))
+	for _, k := range keys {
+		fields = append(fields, zap.Any(k, values[k]))
+	}
+	return fields
+}
+
+// parseCaller parses a caller in the "path/file.go:line" form.
+func parseCaller(caller string) zapcore.EntryCaller {
+	i := strings.LastIndexByte(caller, ':')
+	if i < 0 {
+		return zapcore.EntryCaller{}
+	}
+	line, err := strconv.Atoi(caller[i+1:])
+	if err != nil {
+		return zapcore.EntryCaller{}
+	}
+	return zapcore.EntryCaller{Defined: true, File: caller[:i], Line: line}
+}
@@ -0,0 +1,66 @@
+package replay
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	log "github.com/ipfs/go-log/v2"
+	"github.com/ipfs/go-log/v2/logparse"
+)
+
+const captured = `{"level":"info","ts":"2010-05-23T15:14:00.000Z","logger":"replay-test","caller":"dht/query.go:12","msg":"first","peers":3}
+{"level":"debug","ts":"2010-05-23T15:14:00.010Z","logger":"replay-test","msg":"filtered"}
+2010-05-23T15:14:00.050Z	WARN	replay-test.child	second
+`
+
+func TestReplay(t *testing.T) {
+	f, err := ioutil.TempFile("", "go-log-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(f.Name())
+
+	log.SetupLogging(log.Config{Format: log.JSONOutput, Level: log.LevelInfo, File: f.Name()})
+	defer log.SetupLogging(log.Config{Stderr: true})
+
+	start := time.Now()
+	n, err := Replay(context.Background(), logparse.NewDecoder(strings.NewReader(captured)), Options{Speed: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 3 {
+		t.Errorf("replayed %d entries, want 3", n)
+	}
+	if elapsed := time.Since(start); elapsed < 50*time.Millisecond {
+		t.Errorf("replayed in %s, want the original 50ms", elapsed)
+	}
+
+	dec := logparse.NewDecoder(f)
+	first, err := dec.Next()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := time.Date(2010, 5, 23, 15, 14, 0, 0, time.UTC)
+	if first.Message != "first" || first.Caller != "dht/query.go:12" || first.Fields["peers"] != 3.0 || !first.Time.Equal(want) {
+		t.Errorf("got %+v", first)
+	}
+	second, err := dec.Next()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if second.Message != "second" || second.Logger != "replay-test.child" || second.Level != "warn" {
+		t.Errorf("got %+v", second)
+	}
+}
+
+func TestReplayCanceled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := Replay(ctx, logparse.NewDecoder(strings.NewReader(captured)), Options{}); err != context.Canceled {
+		t.Errorf("got %v, want context.Canceled", err)
+	}
+}
@@ -0,0 +1,111 @@
+package log
+
+import (
+	"errors"
+	"fmt"
+
+	"go.uber.org/zap/zapcore"
+)
+
+// ErrReservedKey is returned for entries with fields that use a reserved key
+// under RejectReservedKeys.
+var ErrReservedKey = errors.New("field uses a reserved key")
+
+// ReservedKeyPolicy is what happens to fields whose keys are also keys of the
+// entry itself in the output, i.e. "level" or "msg", see
+// Config.ReservedKeyPolicy.
+type ReservedKeyPolicy int
+
+const (
+	// KeepReservedKeys writes such fields as is, which results in duplicate
+	// keys in JSON output.
+	KeepReservedKeys ReservedKeyPolicy = iota
+	// PrefixReservedKeys prefixes their keys with ReservedKeyPrefix.
+	PrefixReservedKeys
+	// DropReservedKeys removes such fields from the entry.
+	DropReservedKeys
+	// RejectReservedKeys discards entries with such fields and reports
+	// ErrReservedKey to the error output of the logger.
+	RejectReservedKeys
+)
+
+// ReservedKeyPrefix is prepended to reserved keys by PrefixReservedKeys.
+const ReservedKeyPrefix = "_"
+
+var _ zapcore.Core = (*reservedKeyCore)(nil)
+
+// reservedKeyCore applies a ReservedKeyPolicy to the fields of entries.
+type reservedKeyCore struct {
+	next     zapcore.Core
+	policy   ReservedKeyPolicy
+	rejected string // reserved key of a field added with With, for RejectReservedKeys
+}
+
+func newReservedKeyCore(next zapcore.Core, policy ReservedKeyPolicy) *reservedKeyCore {
+	return &reservedKeyCore{next: next, policy: policy}
+}
+
+func (c *reservedKeyCore) With(fields []zapcore.Field) zapcore.Core {
+	fields, rejected := c.apply(fields)
+	if c.rejected != "" {
+		rejected = c.rejected
+	}
+	return &reservedKeyCore{next: c.next.With(fields), policy: c.policy, rejected: rejected}
+}
+
+func (c *reservedKeyCore) Enabled(lvl zapcore.Level) bool {
+	return c.next.Enabled(lvl)
+}
+
+func (c *reservedKeyCore) Check(ent zapcore.Entry, ce *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if c.next.Enabled(ent.Level) {
+		return ce.AddCore(ent, c)
+	}
+	return ce
+}
+
+func (c *reservedKeyCore) Write(ent zapcore.Entry, fields []zapcore.Field) error {
+	fields, rejected := c.apply(fields)
+	if c.rejected != "" {
+		rejected = c.rejected
+	}
+	if rejected != "" {
+		return fmt.Errorf("%w: %q", ErrReservedKey, rejected)
+	}
+	return writeChecked(c.next, ent, fields)
+}
+
+func (c *reservedKeyCore) Sync() error {
+	return c.next.Sync()
+}
+
+// apply returns the fields with the policy applied, and the first reserved
+// key under RejectReservedKeys. The given fields are not modified.
+func (c *reservedKeyCore) apply(fields []zapcore.Field) ([]zapcore.Field, string) {
+	if c.policy == KeepReservedKeys {
+		return fields, ""
+	}
+	var out []zapcore.Field
+	for i, f := range fields {
+		if !reservedFieldKeys[f.Key] || f.Type == zapcore.SkipType {
+			if out != nil {
+				out = append(out, f)
+			}
+			continue
+		}
+		if c.policy == RejectReservedKeys {
+			return fields, f.Key
+		}
+		if out == nil {
+			out = append(make([]zapcore.Field, 0, len(fields)), fields[:i]...)
+		}
+		if c.policy == PrefixReservedKeys {
+			f.Key = ReservedKeyPrefix + f.Key
+			out = append(out, f)
+		}
+	}
+	if out == nil {
+		return fields, ""
+	}
+	return out, ""
+}
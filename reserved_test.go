@@ -0,0 +1,84 @@
+package log
+
+import (
+	"bytes"
+	"errors"
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+func TestReservedKeyPolicy(t *testing.T) {
+	for _, tc := range []struct {
+		policy ReservedKeyPolicy
+		want   string
+	}{
+		{KeepReservedKeys, `{"msg": "with", "peer": "p", "level": "high"}`},
+		{PrefixReservedKeys, `{"_msg": "with", "peer": "p", "_level": "high"}`},
+		{DropReservedKeys, `{"peer": "p"}`},
+		{RejectReservedKeys, ``},
+	} {
+		buf := &bytes.Buffer{}
+		errBuf := &bytes.Buffer{}
+		core := newReservedKeyCore(newCore(PlaintextOutput, zapcore.AddSync(buf), LevelDebug, nil), tc.policy)
+		logger := zap.New(core, zap.ErrorOutput(zapcore.AddSync(errBuf))).Sugar().With("msg", "with")
+		logger.Infow("entry", "peer", "p", "level", "high")
+
+		got := strings.TrimSpace(buf.String())
+		if i := strings.Index(got, "{"); i >= 0 {
+			got = got[i:]
+		}
+		if got != tc.want {
+			t.Errorf("policy %d: got %q, want %q", tc.policy, got, tc.want)
+		}
+		if tc.policy == RejectReservedKeys && !strings.Contains(errBuf.String(), ErrReservedKey.Error()) {
+			t.Errorf("rejected entry was not reported: %q", errBuf.String())
+		}
+	}
+}
+
+func TestReservedKeyCoreReject(t *testing.T) {
+	core := newReservedKeyCore(zapcore.NewNopCore(), RejectReservedKeys)
+	err := core.Write(zapcore.Entry{}, []zapcore.Field{zap.String("ts", "now")})
+	if !errors.Is(err, ErrReservedKey) {
+		t.Errorf("got error %v, want ErrReservedKey", err)
+	}
+}
+
+func TestReservedKeyRejectReported(t *testing.T) {
+	defer SetupLogging(Config{Stderr: true})
+
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	stderr := os.Stderr
+	os.Stderr = w
+	defer func() { os.Stderr = stderr }()
+
+	buf := &bytes.Buffer{}
+	// the rejection passes the cores wrapping the reserved key core.
+	SetupLogging(Config{Level: LevelError})
+	SetPrimaryCore(newStrictFieldCore(newFieldKeyCore(
+		newReservedKeyCore(newCore(PlaintextOutput, zapcore.AddSync(buf), LevelDebug, nil), RejectReservedKeys),
+		SnakeCaseFieldKeys,
+	)))
+	getLogger("reserved-reject-test").Errorw("rejected", "ts", "now")
+
+	os.Stderr = stderr
+	w.Close()
+	out, err := ioutil.ReadAll(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(out), `write error: field uses a reserved key: "ts"`) {
+		t.Errorf("stderr got %q", out)
+	}
+	if strings.Contains(buf.String(), "rejected") {
+		t.Errorf("output got %q", buf.String())
+	}
+}
@@ -0,0 +1,121 @@
+package log
+
+import (
+	"bufio"
+	"io/ioutil"
+	"os"
+	"regexp"
+	"runtime"
+	"strings"
+)
+
+// Host resource attribute keys, following the OpenTelemetry resource semantic
+// conventions.
+const (
+	HostNameKey         = "host.name"
+	OSTypeKey           = "os.type"
+	HostArchKey         = "host.arch"
+	ContainerIDKey      = "container.id"
+	K8sPodNameKey       = "k8s.pod.name"
+	K8sPodUIDKey        = "k8s.pod.uid"
+	K8sNamespaceNameKey = "k8s.namespace.name"
+	K8sNodeNameKey      = "k8s.node.name"
+)
+
+// Environment variables read for Kubernetes pod metadata. They are expected
+// to be set with the downward API, i.e.
+//
+//	env:
+//	- name: POD_NAME
+//	  valueFrom: {fieldRef: {fieldPath: metadata.name}}
+const (
+	envPodName      = "POD_NAME"
+	envPodUID       = "POD_UID"
+	envPodNamespace = "POD_NAMESPACE"
+	envNodeName     = "NODE_NAME"
+)
+
+// files read by HostResource, variables for testing.
+var (
+	cgroupFile       = "/proc/self/cgroup"
+	k8sNamespaceFile = "/var/run/secrets/kubernetes.io/serviceaccount/namespace"
+)
+
+// containerIDPattern matches the container ID in a cgroup path, i.e.
+// "/docker/<id>" or "/kubepods/.../cri-containerd-<id>.scope".
+var containerIDPattern = regexp.MustCompile(`[0-9a-f]{64}`)
+
+// HostResource detects the host the process runs on: its hostname, OS and
+// architecture, the ID of the container it runs in, and the metadata of its
+// Kubernetes pod. Values that can't be detected are left out. Pod metadata is
+// read from the POD_NAME, POD_UID, POD_NAMESPACE and NODE_NAME environment
+// variables, which are set with the downward API; without them, the pod name
+// is the hostname and the namespace that of the service account.
+//
+// Config.HostResource adds the attributes to all entries, like Labels.
+func HostResource() map[string]string {
+	res := map[string]string{
+		OSTypeKey:   runtime.GOOS,
+		HostArchKey: runtime.GOARCH,
+	}
+	hostname, _ := os.Hostname()
+	if hostname != "" {
+		res[HostNameKey] = hostname
+	}
+	if id := containerID(); id != "" {
+		res[ContainerIDKey] = id
+	}
+
+	if os.Getenv("KUBERNETES_SERVICE_HOST") == "" {
+		return res
+	}
+	setFromEnv := func(key, env, fallback string) {
+		if v := os.Getenv(env); v != "" {
+			res[key] = v
+		} else if fallback != "" {
+			res[key] = fallback
+		}
+	}
+	var namespace string
+	if b, err := ioutil.ReadFile(k8sNamespaceFile); err == nil {
+		namespace = strings.TrimSpace(string(b))
+	}
+	setFromEnv(K8sPodNameKey, envPodName, hostname)
+	setFromEnv(K8sPodUIDKey, envPodUID, "")
+	setFromEnv(K8sNamespaceNameKey, envPodNamespace, namespace)
+	setFromEnv(K8sNodeNameKey, envNodeName, "")
+	return res
+}
+
+// containerID returns the ID of the container the process runs in, read from
+// its cgroup, or "" if not in a container.
+func containerID() string {
+	f, err := os.Open(cgroupFile)
+	if err != nil {
+		return ""
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		// lines are "hierarchy-ID:controllers:path"
+		parts := strings.SplitN(scanner.Text(), ":", 3)
+		if len(parts) != 3 {
+			continue
+		}
+		if id := containerIDPattern.FindString(parts[2]); id != "" {
+			return id
+		}
+	}
+	return ""
+}
+
+// withHostResource returns labels with the attributes of HostResource added.
+// Labels take precedence.
+func withHostResource(labels map[string]string) map[string]string {
+	merged := HostResource()
+	for k, v := range labels {
+		merged[k] = v
+	}
+	return merged
+}
@@ -0,0 +1,67 @@
+package log
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+)
+
+func TestHostResource(t *testing.T) {
+	dir, err := ioutil.TempDir("", "go-log-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	id := "0123456789abcdef0123456789abcdef0123456789abcdef0123456789abcdef"
+	cgroup := filepath.Join(dir, "cgroup")
+	namespace := filepath.Join(dir, "namespace")
+	if err := ioutil.WriteFile(cgroup, []byte("0::/kubepods/besteffort/pod1/cri-containerd-"+id+".scope\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(namespace, []byte("ipfs\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	defer func(cgroupPath, namespacePath string) {
+		cgroupFile, k8sNamespaceFile = cgroupPath, namespacePath
+	}(cgroupFile, k8sNamespaceFile)
+	cgroupFile, k8sNamespaceFile = cgroup, namespace
+
+	for env, value := range map[string]string{
+		"KUBERNETES_SERVICE_HOST": "10.0.0.1",
+		envPodName:                "node-0",
+		envNodeName:               "",
+	} {
+		old, ok := os.LookupEnv(env)
+		os.Setenv(env, value)
+		if ok {
+			defer os.Setenv(env, old)
+		} else {
+			defer os.Unsetenv(env)
+		}
+	}
+
+	res := HostResource()
+	want := map[string]string{
+		OSTypeKey:           runtime.GOOS,
+		HostArchKey:         runtime.GOARCH,
+		ContainerIDKey:      id,
+		K8sPodNameKey:       "node-0",
+		K8sNamespaceNameKey: "ipfs",
+	}
+	for k, v := range want {
+		if res[k] != v {
+			t.Errorf("got %s=%q, want %q", k, res[k], v)
+		}
+	}
+	if _, ok := res[K8sNodeNameKey]; ok {
+		t.Errorf("got node name %q", res[K8sNodeNameKey])
+	}
+
+	labels := withHostResource(map[string]string{HostArchKey: "overridden"})
+	if labels[HostArchKey] != "overridden" || labels[ContainerIDKey] != id {
+		t.Errorf("got labels %v", labels)
+	}
+}
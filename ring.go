@@ -0,0 +1,211 @@
+package log
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"go.uber.org/zap/zapcore"
+)
+
+// Entry is a log entry retained in memory, see Config.RingBufferSize, or
+// delivered to a subscriber, see Subscribe.
+//
+// Entries are snapshots taken when logging: Fields holds deep copies of the
+// logged values, with reflected values such as maps and structs in their
+// JSON representation, and every subscriber and call to Query gets its own
+// copy. Neither the logging caller nor other recipients can modify an entry
+// once it is handed out.
+type Entry struct {
+	Time      time.Time
+	Level     LogLevel
+	Subsystem string
+	Message   string
+	Caller    string
+	Fields    map[string]interface{}
+}
+
+// Filter selects entries in Query and Subscribe. Zero values match all
+// entries.
+type Filter struct {
+	// Level is the minimum level of matching entries, i.e. "error".
+	Level string
+
+	// Subsystem matches entries of the subsystem and of loggers derived from
+	// it with Named ("bitswap.ledger").
+	Subsystem string
+
+	// Since and Until bound the time of matching entries, inclusively.
+	Since time.Time
+	Until time.Time
+
+	// Fields match entries that have all of the given fields, comparing
+	// their values as formatted by fmt.Sprint.
+	Fields map[string]string
+}
+
+// ring is the in-memory ring buffer of recent entries, nil when disabled.
+// It is guarded by loggerMutex.
+var ring *entryRing
+
+// Query returns the entries in the in-memory ring buffer that match filter,
+// oldest first. It returns nothing when the ring buffer is disabled or
+// filter.Level is not a valid level.
+func Query(filter Filter) []Entry {
+	m, err := newEntryMatcher(filter)
+	if err != nil {
+		return nil
+	}
+
+	loggerMutex.RLock()
+	r := ring
+	loggerMutex.RUnlock()
+	if r == nil {
+		return nil
+	}
+
+	var matched []Entry
+	r.each(func(e *Entry) {
+		if m.match(e) {
+			matched = append(matched, e.clone())
+		}
+	})
+	return matched
+}
+
+// entryMatcher matches entries against a Filter.
+type entryMatcher struct {
+	Filter
+	min zapcore.Level
+}
+
+func newEntryMatcher(filter Filter) (*entryMatcher, error) {
+	m := &entryMatcher{Filter: filter, min: zapcore.DebugLevel}
+	if filter.Level != "" {
+		lvl, err := LevelFromString(filter.Level)
+		if err != nil {
+			return nil, err
+		}
+		m.min = zapcore.Level(lvl)
+	}
+	return m, nil
+}
+
+func (m *entryMatcher) match(e *Entry) bool {
+	if zapcore.Level(e.Level) < m.min ||
+		!m.Since.IsZero() && e.Time.Before(m.Since) ||
+		!m.Until.IsZero() && e.Time.After(m.Until) {
+		return false
+	}
+	if m.Subsystem != "" && e.Subsystem != m.Subsystem &&
+		!strings.HasPrefix(e.Subsystem, m.Subsystem+".") {
+		return false
+	}
+	for k, want := range m.Fields {
+		v, ok := e.Fields[k]
+		if !ok || fmt.Sprint(v) != want {
+			return false
+		}
+	}
+	return true
+}
+
+// entryRing keeps the most recent entries.
+type entryRing struct {
+	mu      sync.Mutex
+	entries []Entry
+	next    int // index the next entry is stored at
+	full    bool
+}
+
+func newEntryRing(size int) *entryRing {
+	return &entryRing{entries: make([]Entry, size)}
+}
+
+func (r *entryRing) add(e Entry) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.entries[r.next] = e
+	r.next++
+	if r.next == len(r.entries) {
+		r.next = 0
+		r.full = true
+	}
+}
+
+// each calls fn for the retained entries, oldest first.
+func (r *entryRing) each(fn func(*Entry)) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.full {
+		for i := r.next; i < len(r.entries); i++ {
+			fn(&r.entries[i])
+		}
+	}
+	for i := 0; i < r.next; i++ {
+		fn(&r.entries[i])
+	}
+}
+
+var _ zapcore.Core = (*ringCore)(nil)
+
+// ringCore stores entries in an entryRing.
+type ringCore struct {
+	ring   *entryRing
+	fields []zapcore.Field // accumulated by With
+}
+
+func (c *ringCore) With(fields []zapcore.Field) zapcore.Core {
+	return &ringCore{
+		ring:   c.ring,
+		fields: append(c.fields[:len(c.fields):len(c.fields)], fields...),
+	}
+}
+
+func (c *ringCore) Enabled(zapcore.Level) bool {
+	return true
+}
+
+func (c *ringCore) Check(ent zapcore.Entry, ce *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	return ce.AddCore(ent, c)
+}
+
+func (c *ringCore) Write(ent zapcore.Entry, fields []zapcore.Field) error {
+	c.ring.add(newEntry(ent, c.fields, fields))
+	return nil
+}
+
+func (c *ringCore) Sync() error {
+	return nil
+}
+
+// newEntry converts a zap entry with the fields added with With and those
+// of the entry itself.
+func newEntry(ent zapcore.Entry, with, fields []zapcore.Field) Entry {
+	enc := zapcore.NewMapObjectEncoder()
+	for _, f := range with {
+		if err := safeAddTo(f, enc); err != nil {
+			errorField(f.Key, err).AddTo(enc)
+		}
+	}
+	for _, f := range fields {
+		if err := safeAddTo(f, enc); err != nil {
+			errorField(f.Key, err).AddTo(enc)
+		}
+	}
+
+	e := Entry{
+		Time:      ent.Time,
+		Level:     LogLevel(ent.Level),
+		Subsystem: ent.LoggerName,
+		Message:   ent.Message,
+		Fields:    snapshotValue(enc.Fields).(map[string]interface{}),
+	}
+	if ent.Caller.Defined {
+		e.Caller = ent.Caller.TrimmedPath()
+	}
+	return e
+}
@@ -0,0 +1,56 @@
+package log
+
+import (
+	"testing"
+	"time"
+)
+
+func TestQuery(t *testing.T) {
+	SetupLogging(Config{
+		Level:          LevelDebug,
+		RingBufferSize: 4,
+	})
+	defer SetupLogging(Config{Stderr: true})
+
+	bitswap := getLogger("ring-test-bitswap")
+	dht := getLogger("ring-test-dht")
+
+	bitswap.Infow("dropped", "peer", "a")
+	bitswap.Errorw("failed", "peer", "b")
+	bitswap.Named("ledger").Errorw("failed", "peer", "c")
+	dht.Errorw("failed", "peer", "d")
+	bitswap.Errorw("failed", "peer", "e")
+
+	peers := func(entries []Entry) []string {
+		var ps []string
+		for _, e := range entries {
+			ps = append(ps, e.Fields["peer"].(string))
+		}
+		return ps
+	}
+	for _, tc := range []struct {
+		filter Filter
+		want   []string
+	}{
+		// the oldest entry was evicted.
+		{Filter{}, []string{"b", "c", "d", "e"}},
+		{Filter{Subsystem: "ring-test-bitswap", Level: "error"}, []string{"b", "c", "e"}},
+		{Filter{Subsystem: "ring-test-bitswap.ledger"}, []string{"c"}},
+		{Filter{Fields: map[string]string{"peer": "d"}}, []string{"d"}},
+		{Filter{Since: time.Now().Add(-5 * time.Minute)}, []string{"b", "c", "d", "e"}},
+		{Filter{Until: time.Now().Add(-5 * time.Minute)}, nil},
+		{Filter{Level: "nope"}, nil},
+	} {
+		got := peers(Query(tc.filter))
+		if len(got) != len(tc.want) {
+			t.Errorf("%+v: got %v, want %v", tc.filter, got, tc.want)
+			continue
+		}
+		for i := range got {
+			if got[i] != tc.want[i] {
+				t.Errorf("%+v: got %v, want %v", tc.filter, got, tc.want)
+				break
+			}
+		}
+	}
+}
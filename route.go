@@ -0,0 +1,118 @@
+package log
+
+import (
+	"fmt"
+	"strings"
+
+	"go.uber.org/multierr"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// Route sends the entries of some subsystems to their own output instead of
+// the primary one.
+type Route struct {
+	// Subsystems are the names of the routed subsystems. Loggers derived from
+	// a subsystem logger with Named ("bitswap.ledger") follow the route of
+	// their parent.
+	Subsystems []string
+
+	// Output is "stderr", "stdout", a file path, or a URL with a scheme
+	// registered with zap.RegisterSink.
+	Output string
+
+	// Format is the format of the output, as in Config.Format.
+	Format LogFormat
+}
+
+// openOutput opens a route output. The returned function closes it.
+func openOutput(output string) (zapcore.WriteSyncer, func(), error) {
+	switch {
+	case output == "stderr", output == "stdout", strings.Contains(output, "://"):
+	default:
+		path, err := normalizePath(output)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to resolve log path %q: %w", output, err)
+		}
+		output = path
+	}
+	return zap.Open(output)
+}
+
+var _ zapcore.Core = (*routingCore)(nil)
+
+// routingCore picks the core an entry is written to by its logger name, before
+// the entry gets encoded.
+type routingCore struct {
+	fallback zapcore.Core
+	cores    []zapcore.Core
+	routes   map[string]int // subsystem name to index in cores
+}
+
+func newRoutingCore(fallback zapcore.Core, routes []Route, cores []zapcore.Core) *routingCore {
+	r := &routingCore{
+		fallback: fallback,
+		cores:    cores,
+		routes:   make(map[string]int),
+	}
+	for i, route := range routes {
+		for _, name := range route.Subsystems {
+			r.routes[name] = i
+		}
+	}
+	return r
+}
+
+// route returns the core for the given logger name.
+func (r *routingCore) route(name string) zapcore.Core {
+	for {
+		if i, ok := r.routes[name]; ok {
+			return r.cores[i]
+		}
+		dot := strings.LastIndexByte(name, '.')
+		if dot < 0 {
+			return r.fallback
+		}
+		name = name[:dot]
+	}
+}
+
+func (r *routingCore) With(fields []zapcore.Field) zapcore.Core {
+	sub := &routingCore{
+		fallback: r.fallback.With(fields),
+		cores:    make([]zapcore.Core, len(r.cores)),
+		routes:   r.routes,
+	}
+	for i := range r.cores {
+		sub.cores[i] = r.cores[i].With(fields)
+	}
+	return sub
+}
+
+func (r *routingCore) Enabled(lvl zapcore.Level) bool {
+	if r.fallback.Enabled(lvl) {
+		return true
+	}
+	for i := range r.cores {
+		if r.cores[i].Enabled(lvl) {
+			return true
+		}
+	}
+	return false
+}
+
+func (r *routingCore) Check(ent zapcore.Entry, ce *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	return r.route(ent.LoggerName).Check(ent, ce)
+}
+
+func (r *routingCore) Write(ent zapcore.Entry, fields []zapcore.Field) error {
+	return r.route(ent.LoggerName).Write(ent, fields)
+}
+
+func (r *routingCore) Sync() error {
+	err := r.fallback.Sync()
+	for i := range r.cores {
+		err = multierr.Append(err, r.cores[i].Sync())
+	}
+	return err
+}
@@ -0,0 +1,90 @@
+package log
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"go.uber.org/zap/zapcore"
+)
+
+func TestRoutingCore(t *testing.T) {
+	fallback := &bytes.Buffer{}
+	routed := &bytes.Buffer{}
+
+	core := newRoutingCore(
+		newCore(PlaintextOutput, zapcore.AddSync(fallback), LevelDebug, nil),
+		[]Route{{Subsystems: []string{"bitswap"}}},
+		[]zapcore.Core{newCore(PlaintextOutput, zapcore.AddSync(routed), LevelDebug, nil)},
+	)
+
+	for _, name := range []string{"bitswap", "bitswap.ledger", "dht", "bitswapper"} {
+		ent := zapcore.Entry{
+			LoggerName: name,
+			Level:      zapcore.InfoLevel,
+			Message:    name,
+			Time:       time.Date(2010, 5, 23, 15, 14, 0, 0, time.UTC),
+		}
+		if ce := core.Check(ent, nil); ce != nil {
+			ce.Write()
+		}
+	}
+
+	want := "2010-05-23T15:14:00.000Z\tINFO\tbitswap\tbitswap\n" +
+		"2010-05-23T15:14:00.000Z\tINFO\tbitswap.ledger\tbitswap.ledger\n"
+	if got := routed.String(); got != want {
+		t.Errorf("routed got %q, want %q", got, want)
+	}
+	want = "2010-05-23T15:14:00.000Z\tINFO\tdht\tdht\n" +
+		"2010-05-23T15:14:00.000Z\tINFO\tbitswapper\tbitswapper\n"
+	if got := fallback.String(); got != want {
+		t.Errorf("fallback got %q, want %q", got, want)
+	}
+}
+
+func TestLogRoutes(t *testing.T) {
+	primary, err := ioutil.TempFile("", "go-log-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(primary.Name())
+	routed, err := ioutil.TempFile("", "go-log-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(routed.Name())
+
+	SetupLogging(Config{
+		Format: PlaintextOutput,
+		File:   primary.Name(),
+		Level:  LevelDebug,
+		Routes: []Route{{
+			Subsystems: []string{"route-test-bitswap"},
+			Output:     routed.Name(),
+			Format:     JSONOutput,
+		}},
+	})
+	defer SetupLogging(Config{Stderr: true})
+
+	getLogger("route-test-bitswap").Debug("scooby")
+	getLogger("route-test-dht").Debug("doo")
+
+	content, err := ioutil.ReadFile(routed.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(content), `"msg":"scooby"`) || strings.Contains(string(content), "doo") {
+		t.Errorf("routed output got %q", content)
+	}
+
+	content, err = ioutil.ReadFile(primary.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(content), "doo") || strings.Contains(string(content), "scooby") {
+		t.Errorf("primary output got %q", content)
+	}
+}
@@ -0,0 +1,316 @@
+package log
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+	"sync/atomic"
+
+	"go.uber.org/multierr"
+	"go.uber.org/zap/zapcore"
+)
+
+// RuleAction is what a Rule does with the entries it matches.
+type RuleAction int
+
+const (
+	// RouteAction writes matching entries to the rule's Output instead of
+	// the primary output.
+	RouteAction RuleAction = iota
+	// DropAction discards matching entries.
+	DropAction
+	// SampleAction keeps one in SampleRate matching entries and discards the
+	// others. Kept entries continue with the next rule.
+	SampleAction
+	// SetLevelAction changes the level of matching entries to SetLevel and
+	// continues with the next rule.
+	SetLevelAction
+)
+
+// Rule matches entries written to the primary output and acts on them. An
+// entry matches when it matches all of Level, Subsystem and Fields.
+type Rule struct {
+	// Level matches entry levels. A plain level ("warn") matches that level
+	// only; it can be prefixed with one of >=, >, <=, < to match a range
+	// ("<=info"). Empty matches all levels.
+	Level string
+
+	// Subsystem is a glob matched against the subsystem name, where '*'
+	// matches any sequence of characters and '?' any single character.
+	// Empty matches all subsystems.
+	Subsystem string
+
+	// Fields match entries that have all of the given fields, comparing
+	// values in their string form.
+	Fields map[string]string
+
+	// Action is applied to matching entries.
+	Action RuleAction
+
+	// Output and Format are the destination of RouteAction, as in Route.
+	Output string
+	Format LogFormat
+
+	// SampleRate is used by SampleAction.
+	SampleRate int
+
+	// SetLevel is the level set by SetLevelAction.
+	SetLevel LogLevel
+}
+
+// compiledRule is a Rule prepared for matching.
+type compiledRule struct {
+	sampled uint64 // atomic counter of entries seen by SampleAction, first for alignment
+
+	Rule
+	minLevel  zapcore.Level
+	maxLevel  zapcore.Level
+	subsystem *regexp.Regexp
+	core      zapcore.Core // destination of RouteAction
+	close     func()       // closes the output of core
+}
+
+// rules holds the active []*compiledRule, nil when no rules are set.
+var rules atomic.Value
+
+// remoteRules are the sampling rules of the last remote config, applied after
+// Config.Rules. loggerMutex must be held.
+var remoteRules []Rule
+
+// withRemoteRules returns local followed by remoteRules. loggerMutex must be
+// held.
+func withRemoteRules(local []Rule) []Rule {
+	return append(local[:len(local):len(local)], remoteRules...)
+}
+
+func loadRules() []*compiledRule {
+	rs, _ := rules.Load().([]*compiledRule)
+	return rs
+}
+
+// SetRules replaces the rules applied to the primary output. Rules are
+// evaluated in order, followed by the sampling rules of a remote config, see
+// RemoteLevelConfig.Sampling. An empty list removes all rules.
+func SetRules(rs []Rule) error {
+	loggerMutex.Lock()
+	defer loggerMutex.Unlock()
+
+	compiled, err := compileRules(withRemoteRules(rs), config)
+	if err != nil {
+		return err
+	}
+	replaceRules(compiled)
+	config.Rules = rs
+	return nil
+}
+
+// replaceRules makes compiled the active rules and closes the outputs of the
+// rules it replaces. loggerMutex must be held.
+func replaceRules(compiled []*compiledRule) {
+	old := loadRules()
+	rules.Store(compiled)
+	closeRules(old)
+}
+
+// closeRules syncs and closes the outputs of the routing rules in rs.
+func closeRules(rs []*compiledRule) {
+	for _, rule := range rs {
+		if rule.close == nil {
+			continue
+		}
+		if err := rule.core.Sync(); err != nil {
+			fmt.Fprintf(os.Stderr, "failed to sync log rule output %q: %s\n", rule.Output, err)
+		}
+		rule.close()
+	}
+}
+
+// compileRules prepares rs for matching, opening the outputs of routing rules
+// with the format settings of cfg.
+func compileRules(rs []Rule, cfg Config) (_ []*compiledRule, err error) {
+	compiled := make([]*compiledRule, 0, len(rs))
+	defer func() {
+		if err != nil {
+			closeRules(compiled)
+		}
+	}()
+	for i, rule := range rs {
+		c := &compiledRule{Rule: rule}
+
+		c.minLevel, c.maxLevel, err = parseLevelMatch(rule.Level)
+		if err != nil {
+			return nil, fmt.Errorf("rule %d: %w", i, err)
+		}
+		if rule.Subsystem != "" {
+			c.subsystem = globToRegexp(rule.Subsystem)
+		}
+
+		switch rule.Action {
+		case RouteAction:
+			ws, closeOutput, err := openOutput(rule.Output)
+			if err != nil {
+				return nil, fmt.Errorf("rule %d: %w", i, err)
+			}
+			c.core = withLabels(newCore(rule.Format, ws, LevelDebug, cfg.TimeLocation), cfg.Labels)
+			c.close = closeOutput
+		case SampleAction:
+			if rule.SampleRate < 1 {
+				return nil, fmt.Errorf("rule %d: invalid sample rate %d", i, rule.SampleRate)
+			}
+		case DropAction, SetLevelAction:
+		default:
+			return nil, fmt.Errorf("rule %d: unknown action %d", i, rule.Action)
+		}
+		compiled = append(compiled, c)
+	}
+	if len(compiled) == 0 {
+		return nil, nil
+	}
+	return compiled, nil
+}
+
+// parseLevelMatch parses the Level of a Rule into an inclusive level range.
+func parseLevelMatch(s string) (min, max zapcore.Level, err error) {
+	min, max = zapcore.DebugLevel, zapcore.FatalLevel
+	if s == "" {
+		return min, max, nil
+	}
+
+	var op string
+	for _, prefix := range []string{">=", "<=", ">", "<"} {
+		if strings.HasPrefix(s, prefix) {
+			op = prefix
+			break
+		}
+	}
+	lvl, err := LevelFromString(strings.TrimSpace(s[len(op):]))
+	if err != nil {
+		return min, max, err
+	}
+	l := zapcore.Level(lvl)
+
+	switch op {
+	case ">=":
+		return l, max, nil
+	case ">":
+		return l + 1, max, nil
+	case "<=":
+		return min, l, nil
+	case "<":
+		return min, l - 1, nil
+	default:
+		return l, l, nil
+	}
+}
+
+// globToRegexp converts a glob with '*' and '?' wildcards to an anchored
+// regular expression.
+func globToRegexp(glob string) *regexp.Regexp {
+	expr := regexp.QuoteMeta(glob)
+	expr = strings.ReplaceAll(expr, `\*`, ".*")
+	expr = strings.ReplaceAll(expr, `\?`, ".")
+	return regexp.MustCompile("^" + expr + "$")
+}
+
+// matchesEntry reports whether the entry matches the rule, ignoring fields.
+func (c *compiledRule) matchesEntry(ent zapcore.Entry) bool {
+	if ent.Level < c.minLevel || ent.Level > c.maxLevel {
+		return false
+	}
+	return c.subsystem == nil || c.subsystem.MatchString(ent.LoggerName)
+}
+
+// matchesFields reports whether fields contain all of the rule's fields.
+func (c *compiledRule) matchesFields(fields map[string]interface{}) bool {
+	for k, want := range c.Fields {
+		v, ok := fields[k]
+		if !ok || fmt.Sprint(v) != want {
+			return false
+		}
+	}
+	return true
+}
+
+var _ zapcore.Core = (*rulesCore)(nil)
+
+// rulesCore applies the active rules to the entries written to the next core.
+type rulesCore struct {
+	next   zapcore.Core
+	fields []zapcore.Field // accumulated by With, for matching and routing
+}
+
+func (r *rulesCore) With(fields []zapcore.Field) zapcore.Core {
+	return &rulesCore{
+		next:   r.next.With(fields),
+		fields: append(r.fields[:len(r.fields):len(r.fields)], fields...),
+	}
+}
+
+func (r *rulesCore) Enabled(lvl zapcore.Level) bool {
+	return r.next.Enabled(lvl) || len(loadRules()) > 0
+}
+
+func (r *rulesCore) Check(ent zapcore.Entry, ce *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	for _, rule := range loadRules() {
+		if rule.matchesEntry(ent) {
+			// fields are only known when writing.
+			return ce.AddCore(ent, r)
+		}
+	}
+	return r.next.Check(ent, ce)
+}
+
+func (r *rulesCore) Write(ent zapcore.Entry, fields []zapcore.Field) error {
+	var encoded map[string]interface{}
+	for _, rule := range loadRules() {
+		if !rule.matchesEntry(ent) {
+			continue
+		}
+		if len(rule.Fields) > 0 {
+			if encoded == nil {
+				enc := zapcore.NewMapObjectEncoder()
+				for _, f := range r.fields {
+					f.AddTo(enc)
+				}
+				for _, f := range fields {
+					f.AddTo(enc)
+				}
+				encoded = enc.Fields
+			}
+			if !rule.matchesFields(encoded) {
+				continue
+			}
+		}
+
+		switch rule.Action {
+		case DropAction:
+			return nil
+		case SampleAction:
+			if (atomic.AddUint64(&rule.sampled, 1)-1)%uint64(rule.SampleRate) != 0 {
+				return nil
+			}
+		case SetLevelAction:
+			ent.Level = zapcore.Level(rule.SetLevel)
+		case RouteAction:
+			if !rule.core.Enabled(ent.Level) {
+				return nil
+			}
+			return rule.core.Write(ent, append(r.fields[:len(r.fields):len(r.fields)], fields...))
+		}
+	}
+	if !r.next.Enabled(ent.Level) {
+		return nil
+	}
+	return r.next.Write(ent, fields)
+}
+
+func (r *rulesCore) Sync() error {
+	err := r.next.Sync()
+	for _, rule := range loadRules() {
+		if rule.core != nil {
+			err = multierr.Append(err, rule.core.Sync())
+		}
+	}
+	return err
+}
@@ -0,0 +1,201 @@
+package log
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+func TestParseLevelMatch(t *testing.T) {
+	testCases := []struct {
+		match    string
+		min, max zapcore.Level
+	}{
+		{"", zapcore.DebugLevel, zapcore.FatalLevel},
+		{"warn", zapcore.WarnLevel, zapcore.WarnLevel},
+		{">=warn", zapcore.WarnLevel, zapcore.FatalLevel},
+		{">warn", zapcore.ErrorLevel, zapcore.FatalLevel},
+		{"<=info", zapcore.DebugLevel, zapcore.InfoLevel},
+		{"<info", zapcore.DebugLevel, zapcore.DebugLevel},
+	}
+
+	for _, tc := range testCases {
+		min, max, err := parseLevelMatch(tc.match)
+		if err != nil {
+			t.Fatalf("%q: unexpected error: %v", tc.match, err)
+		}
+		if min != tc.min || max != tc.max {
+			t.Errorf("%q: got [%s, %s], want [%s, %s]", tc.match, min, max, tc.min, tc.max)
+		}
+	}
+
+	if _, _, err := parseLevelMatch(">=loud"); err == nil {
+		t.Error("expected an error for an unknown level")
+	}
+}
+
+func TestRulesCore(t *testing.T) {
+	defer rules.Store([]*compiledRule(nil))
+
+	buf := &bytes.Buffer{}
+	log := zap.New(&rulesCore{next: newCore(PlaintextOutput, zapcore.AddSync(buf), LevelDebug, nil)})
+
+	compiled, err := compileRules([]Rule{
+		{Subsystem: "bitswap*", Level: "debug", Action: DropAction},
+		{Subsystem: "dht", Fields: map[string]string{"peer": "QmFoo"}, Action: DropAction},
+		{Subsystem: "swarm", Action: SampleAction, SampleRate: 2},
+		{Level: "error", Subsystem: "net*", Action: SetLevelAction, SetLevel: LevelWarn},
+	}, Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	rules.Store(compiled)
+
+	log.Named("bitswap.ledger").Debug("dropped-debug")
+	log.Named("bitswap").Info("kept-info")
+	log.Named("dht").Info("dropped-field", zap.String("peer", "QmFoo"))
+	log.Named("dht").With(zap.String("peer", "QmFoo")).Info("dropped-with-field")
+	log.Named("dht").Info("kept-field", zap.String("peer", "QmBar"))
+	for i := 0; i < 4; i++ {
+		log.Named("swarm").Info("sampled")
+	}
+	log.Named("net").Error("demoted")
+
+	out := buf.String()
+	for _, want := range []string{"kept-info", "kept-field", "WARN\tnet\tdemoted"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("got %q, wanted it to contain %q", out, want)
+		}
+	}
+	for _, unwanted := range []string{"dropped"} {
+		if strings.Contains(out, unwanted) {
+			t.Errorf("got %q, wanted it to not contain %q", out, unwanted)
+		}
+	}
+	if n := strings.Count(out, "sampled"); n != 2 {
+		t.Errorf("got %d sampled entries, want 2", n)
+	}
+}
+
+func TestSetRules(t *testing.T) {
+	defer SetupLogging(Config{Stderr: true})
+
+	primary := &bytes.Buffer{}
+	SetupLogging(Config{Level: LevelDebug})
+	SetPrimaryCore(&rulesCore{next: newCore(PlaintextOutput, zapcore.AddSync(primary), LevelDebug, nil)})
+
+	if err := SetRules([]Rule{{Action: SampleAction}}); err == nil {
+		t.Fatal("expected an error for a zero sample rate")
+	}
+
+	if err := SetRules([]Rule{{Subsystem: "rules-test", Action: DropAction}}); err != nil {
+		t.Fatal(err)
+	}
+	getLogger("rules-test").Info("scooby")
+	getLogger("rules-test-other").Info("doo")
+
+	if strings.Contains(primary.String(), "scooby") || !strings.Contains(primary.String(), "doo") {
+		t.Errorf("got %q", primary.String())
+	}
+	if got := GetConfig().Rules; len(got) != 1 {
+		t.Errorf("got %d rules in config, want 1", len(got))
+	}
+
+	routed, err := ioutil.TempFile("", "go-log-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(routed.Name())
+
+	if err := SetRules([]Rule{{Level: ">=error", Action: RouteAction, Output: routed.Name(), Format: JSONOutput}}); err != nil {
+		t.Fatal(err)
+	}
+	getLogger("rules-test").Error("velma")
+
+	content, err := ioutil.ReadFile(routed.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(content), `"msg":"velma"`) || strings.Contains(primary.String(), "velma") {
+		t.Errorf("routed output got %q, primary output got %q", content, primary.String())
+	}
+}
+
+func TestRulesCoreChecksLevel(t *testing.T) {
+	defer rules.Store([]*compiledRule(nil))
+
+	buf := &bytes.Buffer{}
+	log := zap.New(&rulesCore{next: newCore(PlaintextOutput, zapcore.AddSync(buf), LevelInfo, nil)})
+
+	compiled, err := compileRules([]Rule{
+		{Subsystem: "net", Action: SetLevelAction, SetLevel: LevelDebug},
+	}, Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	rules.Store(compiled)
+
+	log.Named("net").Debug("debug")
+	log.Named("net").Info("demoted")
+	if buf.Len() > 0 {
+		t.Errorf("got %q, want no entries below the level of the output", buf.String())
+	}
+}
+
+// closeCountingSink counts how often it is closed.
+type closeCountingSink struct {
+	zapcore.WriteSyncer
+	closed *int32
+}
+
+func (s closeCountingSink) Close() error {
+	atomic.AddInt32(s.closed, 1)
+	return nil
+}
+
+var (
+	registerRulesTestSink sync.Once
+	rulesTestSinkClosed   int32
+)
+
+func TestSetRulesClosesReplacedOutputs(t *testing.T) {
+	defer SetupLogging(Config{Stderr: true})
+	registerRulesTestSink.Do(func() {
+		err := zap.RegisterSink("rulestest", func(*url.URL) (zap.Sink, error) {
+			return closeCountingSink{zapcore.AddSync(ioutil.Discard), &rulesTestSinkClosed}, nil
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	})
+	atomic.StoreInt32(&rulesTestSinkClosed, 0)
+
+	SetupLogging(Config{Level: LevelDebug, Rules: []Rule{{Action: RouteAction, Output: "rulestest://first"}}})
+	if err := SetRules([]Rule{{Action: RouteAction, Output: "rulestest://second"}}); err != nil {
+		t.Fatal(err)
+	}
+	if n := atomic.LoadInt32(&rulesTestSinkClosed); n != 1 {
+		t.Errorf("got %d closed outputs after SetRules, want 1", n)
+	}
+
+	// outputs opened before an invalid rule are closed too.
+	if err := SetRules([]Rule{{Action: RouteAction, Output: "rulestest://third"}, {Action: SampleAction}}); err == nil {
+		t.Fatal("expected an error for a zero sample rate")
+	}
+	if n := atomic.LoadInt32(&rulesTestSinkClosed); n != 2 {
+		t.Errorf("got %d closed outputs after an invalid rule, want 2", n)
+	}
+
+	SetupLogging(Config{Level: LevelDebug})
+	if n := atomic.LoadInt32(&rulesTestSinkClosed); n != 3 {
+		t.Errorf("got %d closed outputs after SetupLogging, want 3", n)
+	}
+}
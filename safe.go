@@ -0,0 +1,125 @@
+package log
+
+import (
+	"fmt"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/buffer"
+	"go.uber.org/zap/zapcore"
+)
+
+// Code supplied by applications, such as marshalers of logged values and
+// hooks like LevelEnabler, must not take down the process when it panics.
+// Panics are recovered and reported as errors in the style of zap, which
+// encodes a panicking Stringer as "PANIC=<value>" in a "<key>Error" field.
+
+// panicError converts a recovered panic value to an error.
+func panicError(r interface{}) error {
+	return fmt.Errorf("PANIC=%v", r)
+}
+
+// errorField returns the field zap adds for a field with key that failed to
+// encode.
+func errorField(key string, err error) zapcore.Field {
+	return zap.String(key+"Error", err.Error())
+}
+
+// callsUserCode reports whether encoding f calls code of the application.
+// Stringers and errors are not included, zap recovers their panics itself.
+func callsUserCode(f zapcore.Field) bool {
+	switch f.Type {
+	case zapcore.ObjectMarshalerType, zapcore.ArrayMarshalerType, zapcore.InlineMarshalerType, zapcore.ReflectType:
+		return true
+	}
+	return false
+}
+
+// safeAddTo adds f to enc, returning the panic of a marshaler as an error.
+func safeAddTo(f zapcore.Field, enc zapcore.ObjectEncoder) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = panicError(r)
+		}
+	}()
+	f.AddTo(enc)
+	return nil
+}
+
+var _ zapcore.Encoder = (*safeEncoder)(nil)
+
+// safeEncoder recovers from panics of marshalers and encodes an error field
+// in place of the field that panicked.
+type safeEncoder struct {
+	zapcore.Encoder
+}
+
+func (enc *safeEncoder) Clone() zapcore.Encoder {
+	return &safeEncoder{enc.Encoder.Clone()}
+}
+
+// AddArray, AddObject and AddReflected are used for fields added with With.
+// A field is first added to a clone, since the encoder may have written part
+// of it when a marshaler panics.
+
+func (enc *safeEncoder) AddArray(key string, m zapcore.ArrayMarshaler) error {
+	return enc.add(zap.Array(key, m))
+}
+
+func (enc *safeEncoder) AddObject(key string, m zapcore.ObjectMarshaler) error {
+	return enc.add(zap.Object(key, m))
+}
+
+func (enc *safeEncoder) AddReflected(key string, v interface{}) error {
+	return enc.add(zap.Reflect(key, v))
+}
+
+func (enc *safeEncoder) add(f zapcore.Field) error {
+	if err := safeAddTo(f, enc.Encoder.Clone()); err != nil {
+		errorField(f.Key, err).AddTo(enc.Encoder)
+		return nil
+	}
+	f.AddTo(enc.Encoder)
+	return nil
+}
+
+func (enc *safeEncoder) EncodeEntry(ent zapcore.Entry, fields []zapcore.Field) (*buffer.Buffer, error) {
+	if buf, ok, err := enc.tryEncodeEntry(ent, fields); ok {
+		return buf, err
+	}
+
+	safe := make([]zapcore.Field, len(fields))
+	for i, f := range fields {
+		safe[i] = f
+		if !callsUserCode(f) {
+			continue
+		}
+		if err := safeAddTo(f, enc.Encoder.Clone()); err != nil {
+			safe[i] = errorField(f.Key, err)
+		}
+	}
+	return enc.Encoder.EncodeEntry(ent, safe)
+}
+
+// tryEncodeEntry encodes an entry, and reports whether it did so without a
+// panic.
+func (enc *safeEncoder) tryEncodeEntry(ent zapcore.Entry, fields []zapcore.Field) (buf *buffer.Buffer, ok bool, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			ok = false
+		}
+	}()
+	buf, err = enc.Encoder.EncodeEntry(ent, fields)
+	return buf, true, err
+}
+
+// callLevelEnabler calls fn. When it panics, the entry is logged with the
+// panic in an error field.
+func callLevelEnabler(fn LevelEnabler, subsystem string, level LogLevel, fields []zapcore.Field) (enabled bool, errField *zapcore.Field) {
+	defer func() {
+		if r := recover(); r != nil {
+			f := errorField("levelEnabler", panicError(r))
+			enabled, errField = true, &f
+		}
+	}()
+	return fn(subsystem, level, fields), nil
+}
@@ -0,0 +1,72 @@
+package log
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+type panickingMarshaler struct{}
+
+func (panickingMarshaler) MarshalLogObject(zapcore.ObjectEncoder) error { panic("object") }
+func (panickingMarshaler) MarshalJSON() ([]byte, error)                 { panic("json") }
+
+func TestSafeEncoder(t *testing.T) {
+	for _, format := range []LogFormat{JSONOutput, PlaintextOutput} {
+		buf := &bytes.Buffer{}
+		logger := zap.New(newCore(format, zapcore.AddSync(buf), LevelDebug, nil)).
+			With(zap.Object("with", panickingMarshaler{}), zap.String("ok", "before"))
+		logger.Info("entry", zap.Object("obj", panickingMarshaler{}), zap.Reflect("reflect", panickingMarshaler{}), zap.Int("n", 1))
+
+		out := buf.String()
+		for _, want := range []string{`"withError": "PANIC=object"`, `"objError": "PANIC=object"`, `"reflectError": "PANIC=json"`, `"n": 1`, `"ok": "before"`} {
+			if format == JSONOutput {
+				want = strings.ReplaceAll(want, `": `, `":`)
+			}
+			if !strings.Contains(out, want) {
+				t.Errorf("%v: missing %s in %q", format, want, out)
+			}
+		}
+		if format == JSONOutput && !json.Valid(buf.Bytes()) {
+			t.Errorf("invalid JSON %q", out)
+		}
+	}
+}
+
+func TestNewEntryRecoversPanic(t *testing.T) {
+	e := newEntry(zapcore.Entry{}, nil, []zapcore.Field{zap.Object("obj", panickingMarshaler{})})
+	if e.Fields["objError"] != "PANIC=object" {
+		t.Errorf("got fields %v", e.Fields)
+	}
+}
+
+func TestPanickingHooks(t *testing.T) {
+	SetupLogging(Config{Level: LevelInfo})
+	defer SetupLogging(Config{Stderr: true})
+	buf := &bytes.Buffer{}
+	SetPrimaryCore(newCore(PlaintextOutput, zapcore.AddSync(buf), LevelDebug, nil))
+
+	SetLevelEnabler(func(string, LogLevel, []zapcore.Field) bool { panic("enabler") })
+	defer SetLevelEnabler(nil)
+	getLogger("safe-test").Info("logged")
+	if !strings.Contains(buf.String(), `"levelEnablerError": "PANIC=enabler"`) {
+		t.Errorf("got %q", buf.String())
+	}
+
+	OnWriteError(func(string, error) { panic("handler") })
+	defer OnWriteError(nil)
+	reportWriteError("sink", errors.New("disk full"))
+
+	if _, err := safePut(panickingStore{}, []byte("data")); err == nil {
+		t.Error("expected an error for a panicking store")
+	}
+}
+
+type panickingStore struct{}
+
+func (panickingStore) Put([]byte) (string, error) { panic("store") }
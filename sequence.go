@@ -0,0 +1,62 @@
+package log
+
+import (
+	"sync"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// SequenceKey is the field key of entry sequence numbers, see
+// Config.SequenceNumbers.
+const SequenceKey = "seq"
+
+var _ zapcore.Core = (*sequenceCore)(nil)
+
+// sequenceCore numbers the entries written to the next core, starting at 1.
+// Entries are written in sequence order, so a gap in the numbers seen
+// downstream means entries were lost, and a decrease means they were
+// reordered or the process restarted.
+type sequenceCore struct {
+	next zapcore.Core
+	seq  *sequence
+}
+
+type sequence struct {
+	mu sync.Mutex // also serializes writes, to keep them in sequence order
+	n  uint64
+}
+
+func newSequenceCore(next zapcore.Core) *sequenceCore {
+	return &sequenceCore{next: next, seq: &sequence{}}
+}
+
+func (c *sequenceCore) With(fields []zapcore.Field) zapcore.Core {
+	return &sequenceCore{next: c.next.With(fields), seq: c.seq}
+}
+
+func (c *sequenceCore) Enabled(lvl zapcore.Level) bool {
+	return c.next.Enabled(lvl)
+}
+
+func (c *sequenceCore) Check(ent zapcore.Entry, ce *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if c.next.Enabled(ent.Level) {
+		return ce.AddCore(ent, c)
+	}
+	return ce
+}
+
+func (c *sequenceCore) Write(ent zapcore.Entry, fields []zapcore.Field) error {
+	c.seq.mu.Lock()
+	defer c.seq.mu.Unlock()
+
+	c.seq.n++
+	numbered := make([]zapcore.Field, 0, len(fields)+1)
+	numbered = append(numbered, zap.Uint64(SequenceKey, c.seq.n))
+	numbered = append(numbered, fields...)
+	return c.next.Write(ent, numbered)
+}
+
+func (c *sequenceCore) Sync() error {
+	return c.next.Sync()
+}
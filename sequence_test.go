@@ -0,0 +1,36 @@
+package log
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"go.uber.org/zap/zapcore"
+)
+
+func TestSequenceCore(t *testing.T) {
+	buf := &bytes.Buffer{}
+	core := newSequenceCore(newCore(JSONOutput, zapcore.AddSync(buf), LevelInfo, nil))
+
+	for _, lvl := range []zapcore.Level{zapcore.InfoLevel, zapcore.DebugLevel, zapcore.WarnLevel} {
+		ent := zapcore.Entry{Level: lvl, Message: "entry"}
+		// cores derived with With share the sequence.
+		if ce := core.With(nil).Check(ent, nil); ce != nil {
+			ce.Write()
+		}
+	}
+
+	var got []uint64
+	for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+		var entry map[string]interface{}
+		if err := json.Unmarshal([]byte(line), &entry); err != nil {
+			t.Fatal(err)
+		}
+		seq, _ := entry[SequenceKey].(float64)
+		got = append(got, uint64(seq))
+	}
+	if len(got) != 2 || got[0] != 1 || got[1] != 2 {
+		t.Errorf("got sequence numbers %v, want [1 2]", got)
+	}
+}
@@ -1,12 +1,15 @@
 package log
 
 import (
+	"encoding/hex"
 	"errors"
 	"fmt"
 	"os"
 	"regexp"
 	"strings"
 	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/mattn/go-isatty"
 	"go.uber.org/zap"
@@ -32,11 +35,13 @@ const (
 	envLogging    = "GOLOG_LOG_LEVEL"
 	envLoggingFmt = "GOLOG_LOG_FMT"
 
-	envLoggingFile = "GOLOG_FILE" // /path/to/file
-	envLoggingURL  = "GOLOG_URL"  // url that will be processed by sink in the zap
+	envLoggingFile    = "GOLOG_FILE"                // /path/to/file
+	envLoggingFileKey = "GOLOG_FILE_ENCRYPTION_KEY" // hex-encoded public key the file is encrypted for
+	envLoggingURL     = "GOLOG_URL"                 // url that will be processed by sink in the zap
 
 	envLoggingOutput = "GOLOG_OUTPUT"     // possible values: stdout|stderr|file combine multiple values with '+'
 	envLoggingLabels = "GOLOG_LOG_LABELS" // comma-separated key-value pairs, i.e. "app=example_app,dc=sjc-1"
+	envLoggingTZ     = "GOLOG_LOG_TZ"     // time zone of human-readable timestamps: utc|local|<IANA name>, i.e. "Europe/Berlin"
 )
 
 type LogFormat int
@@ -45,32 +50,184 @@ const (
 	ColorizedOutput LogFormat = iota
 	PlaintextOutput
 	JSONOutput
+	// GrepOutput is plaintext output for grep and cut pipelines, with one
+	// letter levels and a fixed-width subsystem column.
+	GrepOutput
 )
 
 type Config struct {
 	// Format overrides the format of the log output. Defaults to ColorizedOutput
 	Format LogFormat
 
+	// OutputFormats override Format for single outputs, by the names of
+	// GOLOG_OUTPUT: "stderr", "stdout", "file" and "url". I.e. human-readable
+	// output can go to stderr while JSON goes to File. Entries are encoded
+	// once per format.
+	OutputFormats map[string]LogFormat
+
 	// Level is the default minimum enabled logging level.
 	Level LogLevel
 
 	// SubsystemLevels are the default levels per-subsystem. When unspecified, defaults to Level.
 	SubsystemLevels map[string]LogLevel
 
+	// StrictSubsystemLevels makes SetLogLevel fail for subsystems that don't
+	// exist yet, instead of applying the level once they are created.
+	StrictSubsystemLevels bool
+
+	// PackageLevels are levels per package import path, overriding the
+	// levels of subsystems. See SetPackageLogLevel.
+	PackageLevels map[string]LogLevel
+
 	// Stderr indicates whether logs should be written to stderr.
 	Stderr bool
 
 	// Stdout indicates whether logs should be written to stdout.
 	Stdout bool
 
-	// File is a path to a file that logs will be written to.
+	// File is a path to a file that logs will be written to. When it is a
+	// named pipe, entries are discarded while no reader is attached.
 	File string
 
-	// URL with schema supported by zap. Use zap.RegisterSink
+	// FileBuffer enables write coalescing for File. Disabled by default.
+	FileBuffer BufferConfig
+
+	// FileCompression enables stream compression for File. Disabled by
+	// default. Compressed streams are appended to existing files, which
+	// gzip readers handle as a multi-member stream.
+	FileCompression CompressionConfig
+
+	// FileEncryptionKey is the public key File is encrypted for, see
+	// GenerateEncryptionKey and NewDecryptingReader. Disabled when nil.
+	FileEncryptionKey *[32]byte
+
+	// Emergency is where Error and higher entries go while all of the
+	// outputs above are failing. Disabled by default.
+	Emergency EmergencyConfig
+
+	// Archive additionally archives the log output in content-addressed
+	// chunks. Disabled by default.
+	Archive ArchiveConfig
+
+	// URL with schema supported by zap. Use zap.RegisterSink. The sink is
+	// closed when the configuration is replaced.
 	URL string
 
 	// Labels is a set of key-values to apply to all loggers
 	Labels map[string]string
+
+	// HostResource adds the attributes of HostResource to Labels, i.e. the
+	// hostname and container ID. Labels take precedence.
+	HostResource bool
+
+	// EncoderWorkers, when greater than one, is the number of goroutines
+	// encoding entries for the outputs above, which helps throughput when
+	// encoding is expensive. Entries are still written to each output in
+	// order. Reflected values such as maps, and byte slices, are copied when
+	// logging; since the values of ObjectMarshaler, ArrayMarshaler and
+	// Stringer fields are read asynchronously, they must not be modified
+	// after logging them.
+	EncoderWorkers int
+
+	// Routes send the entries of specific subsystems to their own outputs.
+	// Entries of all other subsystems go to the outputs above.
+	Routes []Route
+
+	// Rules are applied to entries before they are routed, see SetRules.
+	Rules []Rule
+
+	// RingBufferSize is the number of recent entries kept in memory for
+	// Query. Disabled when zero.
+	RingBufferSize int
+
+	// ErrorBurst raises subsystems logging bursts of errors to debug level
+	// for a while. Disabled by default.
+	ErrorBurst ErrorBurstConfig
+
+	// Heartbeat logs periodic liveness entries per subsystem. Disabled by
+	// default.
+	Heartbeat HeartbeatConfig
+
+	// Spill moves large field values out of the log stream into a blob
+	// store. Disabled by default.
+	Spill SpillConfig
+
+	// MaxFieldSize, when greater than zero, truncates the values of string
+	// and byte slice fields to this many bytes. A truncated field is followed
+	// by fields with the hash and the length of the whole value, see
+	// TruncatedHashSuffix and TruncatedLenSuffix. Values are spilled, when
+	// larger than Spill.Threshold, rather than truncated.
+	MaxFieldSize int
+
+	// BinaryEncoding is how the values of byte slice fields are rendered in
+	// all formats. Defaults to base64, as zap does. Use Multihash for
+	// multihashes and similar identifiers.
+	BinaryEncoding BinaryEncoding
+
+	// FieldKeyConvention, when set, warns about field keys that don't follow
+	// it, once per key and subsystem. Keys that collide with the keys of the
+	// entry itself in the output, i.e. "level" or "msg", are reported too.
+	// Checking keys is slow, it is meant for development.
+	FieldKeyConvention FieldKeyConvention
+
+	// StrictFields replaces field values that can't be encoded as JSON, i.e.
+	// channels, funcs and NaN, with a "<key>Error" field, and warns about
+	// them once per key and subsystem. Checking values is slow, it is meant
+	// for development.
+	StrictFields bool
+
+	// ReservedKeyPolicy is applied to fields whose keys collide with the
+	// keys of the entry itself in the output, i.e. "level" or "msg".
+	// Defaults to keeping them.
+	ReservedKeyPolicy ReservedKeyPolicy
+
+	// GoroutineID adds the ID of the logging goroutine to every entry, see
+	// GoroutineKey. Obtaining it takes a stack trace, which slows down
+	// logging considerably.
+	GoroutineID bool
+
+	// GoroutineCount adds the number of goroutines to every entry, see
+	// GoroutinesKey.
+	GoroutineCount bool
+
+	// DetectReentrantLogging writes entries logged by a goroutine that is
+	// already writing an entry, i.e. from an output or hook that logs
+	// itself, straight to stderr instead of deadlocking or recursing.
+	// Detection takes a stack trace per entry, it is meant for debugging.
+	DetectReentrantLogging bool
+
+	// SeverityMappings add fields with the severity of entries in the terms
+	// of export targets, i.e. GCPSeverity for Google Cloud Logging.
+	SeverityMappings []SeverityMapping
+
+	// BuildInfo adds the Go version, module version and VCS revision of the
+	// binary to the first entry written, so that the output of different
+	// versions can be told apart. See GoVersionKey.
+	BuildInfo bool
+
+	// EntrySizes records the encoded sizes of entries per subsystem, see
+	// EntrySizes.
+	EntrySizes bool
+
+	// MonotonicTime guarantees non-decreasing timestamps per output. When
+	// the wall clock steps backwards, i.e. during NTP adjustments,
+	// timestamps advance with the monotonic clock until the wall clock has
+	// caught up.
+	MonotonicTime bool
+
+	// SequenceNumbers adds a sequence number to every entry, counted per
+	// output, so that consumers can detect lost or reordered entries. See
+	// SequenceKey.
+	SequenceNumbers bool
+
+	// ConsoleStyle customizes the level labels, colors and header order of
+	// human-readable output. JSON output is not affected.
+	ConsoleStyle *ConsoleStyle
+
+	// TimeLocation is the time zone used for timestamps in human-readable
+	// (colorized and plaintext) output. Defaults to UTC. JSON output is always
+	// in UTC.
+	TimeLocation *time.Location
 }
 
 // ErrNoSuchLogger is returned when the util pkg is asked for a non existant logger
@@ -91,8 +248,24 @@ var defaultLevel LogLevel = LevelError
 // primaryCore is the primary logging core
 var primaryCore zapcore.Core
 
+// stopper is implemented by output wrappers that run in the background and
+// need to be stopped once their output is no longer used.
+type stopper interface {
+	Stop() error
+}
+
+// primaryWrappers are the output wrappers of the primary core, if any
+var primaryWrappers []stopper
+
+// stopFunc is a stopper calling a function.
+type stopFunc func() error
+
+func (f stopFunc) Stop() error {
+	return f()
+}
+
 // loggerCore is the base for all loggers created by this package
-var loggerCore = &lockedMultiCore{}
+var loggerCore = newMultiCore([]zapcore.Core{subscriptions})
 
 // GetConfig returns a copy of the saved config. It can be inspected, modified,
 // and re-applied using a subsequent call to SetupLogging().
@@ -113,47 +286,275 @@ func SetupLogging(cfg Config) {
 	primaryFormat = cfg.Format
 	defaultLevel = cfg.Level
 
+	resetSinkMonitors()
+	if cfg.HostResource {
+		cfg.Labels = withHostResource(cfg.Labels)
+	}
+	cfg, platformCore := platformOutputs(cfg)
 	outputPaths := []string{}
+	outputNames := []string{} // the names of outputPaths in OutputFormats
 
 	if cfg.Stderr {
 		outputPaths = append(outputPaths, "stderr")
+		outputNames = append(outputNames, StderrOutputName)
 	}
 	if cfg.Stdout {
 		outputPaths = append(outputPaths, "stdout")
+		outputNames = append(outputNames, StdoutOutputName)
 	}
 
 	// check if we log to a file
+	var wrappers []stopper
+	var fileWS zapcore.WriteSyncer
 	if len(cfg.File) > 0 {
 		if path, err := normalizePath(cfg.File); err != nil {
 			fmt.Fprintf(os.Stderr, "failed to resolve log path '%q', logging to %s\n", cfg.File, outputPaths)
+		} else if cfg.FileBuffer.Size > 0 || cfg.FileCompression.Compressor != nil || cfg.FileEncryptionKey != nil {
+			// a wrapped file gets its own writer, the other outputs are
+			// written through.
+			fileWS, wrappers = openWrappedFile(path, cfg)
+		} else if isFIFO(path) {
+			// opening a named pipe blocks until there is a reader.
+			fifo := newFIFOWriteSyncer(path)
+			fileWS = monitorSink(path, fifo)
+			wrappers = append(wrappers, fifo)
 		} else {
 			outputPaths = append(outputPaths, path)
+			outputNames = append(outputNames, FileOutputName)
 		}
 	}
 	if len(cfg.URL) > 0 {
 		outputPaths = append(outputPaths, cfg.URL)
+		outputNames = append(outputNames, URLOutputName)
 	}
 
-	ws, _, err := zap.Open(outputPaths...)
-	if err != nil {
-		panic(fmt.Sprintf("unable to open logging output: %v", err))
+	// outputs are grouped by format, so that entries are encoded once per
+	// format. The primary format comes first, and has a core even without
+	// outputs.
+	formats := []LogFormat{primaryFormat}
+	sinks := map[LogFormat][]zapcore.WriteSyncer{primaryFormat: nil}
+	addSink := func(format LogFormat, ws zapcore.WriteSyncer) {
+		if _, ok := sinks[format]; !ok {
+			formats = append(formats, format)
+		}
+		sinks[format] = append(sinks[format], ws)
+	}
+	for name := range cfg.OutputFormats {
+		switch name {
+		case StderrOutputName, StdoutOutputName, FileOutputName, URLOutputName:
+		default:
+			fmt.Fprintf(os.Stderr, "ignoring format of unknown log output %q\n", name)
+		}
 	}
 
-	newPrimaryCore := newCore(primaryFormat, ws, LevelDebug) // the main core needs to log everything.
+	for i, path := range outputPaths {
+		sink, closeSink, err := zap.Open(path)
+		if err != nil {
+			panic(fmt.Sprintf("unable to open logging output: %v", err))
+		}
+		addSink(outputFormat(cfg, outputNames[i]), monitorSink(path, sink))
+		if path == cfg.URL {
+			// sinks registered for URLs may send in the background, i.e.
+			// those of NewBatchSink.
+			wrappers = append(wrappers, stopFunc(func() error {
+				closeSink()
+				return nil
+			}))
+		}
+	}
+	if fileWS != nil {
+		addSink(outputFormat(cfg, FileOutputName), fileWS)
+	}
+	if len(cfg.Archive.Dir) > 0 {
+		if aws, err := newArchiveWriteSyncer(cfg.Archive); err != nil {
+			fmt.Fprintf(os.Stderr, "failed to set up log archive in %q: %s\n", cfg.Archive.Dir, err)
+		} else {
+			m := monitorSink("archive:"+cfg.Archive.Dir, aws)
+			m.queue = aws
+			addSink(primaryFormat, m)
+			wrappers = append(wrappers, aws)
+		}
+	}
+	var pool *encodePool
+	if cfg.EncoderWorkers > 1 {
+		pool = newEncodePool(cfg.EncoderWorkers)
+		// the pool writes to the other wrappers, it has to be stopped first.
+		wrappers = append([]stopper{pool}, wrappers...)
+	}
+	encoder := func(format LogFormat) zapcore.Encoder {
+		if cfg.ConsoleStyle != nil && (format == ColorizedOutput || format == PlaintextOutput) {
+			return newStyledEncoder(format, cfg.TimeLocation, cfg.ConsoleStyle)
+		}
+		return newEncoder(format, cfg.TimeLocation)
+	}
+	outputCore := func(format LogFormat, ws zapcore.WriteSyncer, recordSizes bool) zapcore.Core {
+		enc := encoder(format)
+		if cfg.EntrySizes && recordSizes {
+			enc = &sizeEncoder{Encoder: enc, sizes: entrySizes}
+		}
+		var core zapcore.Core
+		if pool != nil {
+			core = newPooledCore(enc, ws, pool)
+		} else {
+			core = zapcore.NewCore(enc, ws, zap.NewAtomicLevelAt(zapcore.DebugLevel))
+		}
+		if cfg.SequenceNumbers {
+			core = newSequenceCore(core)
+		}
+		if cfg.MonotonicTime {
+			core = newMonotonicCore(core)
+		}
+		return withLabels(core, cfg.Labels)
+	}
+
+	// the main core needs to log everything.
+	var newPrimaryCore zapcore.Core
+	for i, format := range formats {
+		// sizes are recorded once, with the primary format.
+		core := outputCore(format, zapcore.NewMultiWriteSyncer(sinks[format]...), i == 0)
+		if newPrimaryCore == nil {
+			newPrimaryCore = core
+		} else {
+			newPrimaryCore = zapcore.NewTee(newPrimaryCore, core)
+		}
+	}
+	if platformCore != nil {
+		newPrimaryCore = zapcore.NewTee(newPrimaryCore, withLabels(platformCore, cfg.Labels))
+	}
+
+	if len(cfg.Emergency.Output) > 0 {
+		if ews, w, err := openEmergencyOutput(cfg.Emergency); err != nil {
+			fmt.Fprintf(os.Stderr, "failed to open emergency log output %q: %s\n", cfg.Emergency.Output, err)
+		} else {
+			enc := encoder(primaryFormat)
+			for k, v := range cfg.Labels {
+				zap.String(k, v).AddTo(enc)
+			}
+			// only the outputs registered so far belong to the primary core.
+			newPrimaryCore = newEmergencyCore(newPrimaryCore, enc, ews, registeredSinks())
+			if w != nil {
+				wrappers = append(wrappers, w)
+			}
+		}
+	}
+
+	if len(cfg.Routes) > 0 {
+		routes := make([]Route, 0, len(cfg.Routes))
+		cores := make([]zapcore.Core, 0, len(cfg.Routes))
+		for _, route := range cfg.Routes {
+			rws, closeOutput, err := openOutput(route.Output)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "failed to open log route output %q, using primary output: %s\n", route.Output, err)
+				continue
+			}
+			routes = append(routes, route)
+			cores = append(cores, outputCore(route.Format, monitorSink(route.Output, rws), true))
+			wrappers = append(wrappers, stopFunc(func() error {
+				err := rws.Sync()
+				closeOutput()
+				return err
+			}))
+		}
+		newPrimaryCore = newRoutingCore(newPrimaryCore, routes, cores)
+	}
+
+	if cfg.RingBufferSize > 0 {
+		if ring == nil || len(ring.entries) != cfg.RingBufferSize {
+			ring = newEntryRing(cfg.RingBufferSize)
+		}
+		newPrimaryCore = zapcore.NewTee(newPrimaryCore, &ringCore{ring: ring})
+	} else {
+		ring = nil
+	}
+
+	if cfg.ErrorBurst.Threshold > 0 {
+		newPrimaryCore = zapcore.NewTee(newPrimaryCore, newBurstCore(cfg.ErrorBurst))
+	}
+
+	if cfg.Heartbeat.Interval > 0 {
+		counter := newCountingCore()
+		newPrimaryCore = zapcore.NewTee(newPrimaryCore, counter)
+		wrappers = append(wrappers, newHeartbeat(counter, cfg.Heartbeat.Interval))
+	}
+
+	if cfg.BinaryEncoding != Base64Binary {
+		newPrimaryCore = newBinaryCore(newPrimaryCore, cfg.BinaryEncoding)
+	}
+	if cfg.MaxFieldSize > 0 {
+		newPrimaryCore = &truncateCore{next: newPrimaryCore, maxSize: cfg.MaxFieldSize}
+	}
+	if cfg.Spill.Threshold > 0 {
+		newPrimaryCore = newSpillCore(newPrimaryCore, cfg.Spill)
+	}
+
+	compiled, err := compileRules(withRemoteRules(cfg.Rules), cfg)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "ignoring invalid log rules: %s\n", err)
+		config.Rules = nil
+	}
+	replaceRules(compiled)
+	newPrimaryCore = &rulesCore{next: newPrimaryCore}
+	if cfg.GoroutineID || cfg.GoroutineCount {
+		newPrimaryCore = &goroutineCore{next: newPrimaryCore, id: cfg.GoroutineID, count: cfg.GoroutineCount}
+	}
+	if len(cfg.SeverityMappings) > 0 {
+		newPrimaryCore = &severityCore{next: newPrimaryCore, mappings: cfg.SeverityMappings}
+	}
+	if cfg.BuildInfo {
+		newPrimaryCore = newBuildInfoCore(newPrimaryCore)
+	}
+	if cfg.ReservedKeyPolicy != KeepReservedKeys {
+		newPrimaryCore = newReservedKeyCore(newPrimaryCore, cfg.ReservedKeyPolicy)
+	}
+	if cfg.FieldKeyConvention != AnyFieldKeys {
+		newPrimaryCore = newFieldKeyCore(newPrimaryCore, cfg.FieldKeyConvention)
+	}
+	if cfg.StrictFields {
+		newPrimaryCore = newStrictFieldCore(newPrimaryCore)
+	}
 
-	for k, v := range cfg.Labels {
-		newPrimaryCore = newPrimaryCore.With([]zap.Field{zap.String(k, v)})
+	var reentry int32
+	if cfg.DetectReentrantLogging {
+		reentry = 1
 	}
+	atomic.StoreInt32(&detectReentry, reentry)
 
 	setPrimaryCore(newPrimaryCore)
+	stopPrimaryWrappers()
+	primaryWrappers = wrappers
 	setAllLoggers(defaultLevel)
 
 	for name, level := range cfg.SubsystemLevels {
-		if leveler, ok := levels[name]; ok {
-			leveler.SetLevel(zapcore.Level(level))
-		} else {
-			levels[name] = zap.NewAtomicLevelAt(zapcore.Level(level))
+		setSubsystemLevel(name, level)
+	}
+
+	packageLevelsMu.Lock()
+	updatePackageLevels(func(all map[string]zapcore.Level) {
+		for pkg := range all {
+			delete(all, pkg)
+		}
+		for pkg, level := range cfg.PackageLevels {
+			all[pkg] = zapcore.Level(level)
 		}
+	})
+	packageLevelsMu.Unlock()
+}
+
+// withLabels adds labels as fields to core.
+func withLabels(core zapcore.Core, labels map[string]string) zapcore.Core {
+	for k, v := range labels {
+		core = core.With([]zap.Field{zap.String(k, v)})
+	}
+	return core
+}
+
+// setSubsystemLevel sets the level of a subsystem. Subsystems that don't exist
+// yet pick up the level when they are created. loggerMutex must be held.
+func setSubsystemLevel(name string, level LogLevel) {
+	if leveler, ok := levels[name]; ok {
+		leveler.SetLevel(zapcore.Level(level))
+	} else {
+		levels[name] = zap.NewAtomicLevelAt(zapcore.Level(level))
 	}
 }
 
@@ -164,6 +565,67 @@ func SetPrimaryCore(core zapcore.Core) {
 	defer loggerMutex.Unlock()
 
 	setPrimaryCore(core)
+	stopPrimaryWrappers()
+	resetSinkMonitors()
+}
+
+// openWrappedFile opens the log file at path, wrapped with the encryption,
+// compression and buffering configured in cfg. The returned wrappers are
+// ordered from the outermost to the innermost.
+func openWrappedFile(path string, cfg Config) (zapcore.WriteSyncer, []stopper) {
+	var file zapcore.WriteSyncer
+	var wrappers []stopper
+	if isFIFO(path) {
+		fifo := newFIFOWriteSyncer(path)
+		file = fifo
+		wrappers = append(wrappers, fifo)
+	} else {
+		var err error
+		if file, _, err = zap.Open(path); err != nil {
+			panic(fmt.Sprintf("unable to open logging output: %v", err))
+		}
+	}
+	// the file itself is monitored, so that errors of background flushes
+	// are seen.
+	m := monitorSink(path, file)
+	var ws zapcore.WriteSyncer = m
+
+	if cfg.FileEncryptionKey != nil {
+		var err error
+		ws, err = newEncryptedWriteSyncer(ws, cfg.FileEncryptionKey)
+		if err != nil {
+			panic(fmt.Sprintf("unable to set up log encryption: %v", err))
+		}
+	}
+
+	if cfg.FileCompression.Compressor != nil {
+		cws, err := newCompressedWriteSyncer(ws, cfg.FileCompression, m.backgroundError)
+		if err != nil {
+			panic(fmt.Sprintf("unable to set up log compression: %v", err))
+		}
+		ws = cws
+		wrappers = append([]stopper{cws}, wrappers...)
+	}
+	if cfg.FileBuffer.Size > 0 {
+		bws := newBufferedWriteSyncer(ws, cfg.FileBuffer, m.backgroundError)
+		ws = bws
+		m.queue = bws
+		// the buffer has to be flushed before the compressed stream is
+		// terminated.
+		wrappers = append([]stopper{bws}, wrappers...)
+	}
+	return ws, wrappers
+}
+
+// stopPrimaryWrappers flushes and stops the output wrappers of a replaced
+// primary core.
+func stopPrimaryWrappers() {
+	for _, w := range primaryWrappers {
+		if err := w.Stop(); err != nil {
+			fmt.Fprintf(os.Stderr, "failed to stop log output: %s\n", err)
+		}
+	}
+	primaryWrappers = nil
 }
 
 func setPrimaryCore(core zapcore.Core) {
@@ -188,14 +650,33 @@ func SetAllLoggers(lvl LogLevel) {
 	setAllLoggers(lvl)
 }
 
+// SetDefaultLogLevel changes the level subsystems are created with. Existing
+// subsystems keep their level; call SetAllLoggers as well to change them.
+func SetDefaultLogLevel(lvl LogLevel) {
+	loggerMutex.Lock()
+	defer loggerMutex.Unlock()
+
+	defaultLevel = lvl
+	config.Level = lvl
+}
+
+// setAllLoggers sets the level of all created subsystems. Levels set for
+// subsystems that were not created yet are kept, see SetLogLevel.
 func setAllLoggers(lvl LogLevel) {
-	for _, l := range levels {
-		l.SetLevel(zapcore.Level(lvl))
+	for name, l := range levels {
+		if _, created := loggers[name]; created {
+			l.SetLevel(zapcore.Level(lvl))
+		}
 	}
 }
 
 // SetLogLevel changes the log level of a specific subsystem
 // name=="*" changes all subsystems
+//
+// The level of a subsystem that doesn't exist yet is applied once it is
+// created, with a warning on stderr, and is kept by SetAllLoggers and
+// SetupLogging until then, unless Config.StrictSubsystemLevels is set, in which case
+// ErrNoSuchLogger is returned.
 func SetLogLevel(name, level string) error {
 	lvl, err := LevelFromString(level)
 	if err != nil {
@@ -208,16 +689,20 @@ func SetLogLevel(name, level string) error {
 		return nil
 	}
 
-	loggerMutex.RLock()
-	defer loggerMutex.RUnlock()
-
+	loggerMutex.Lock()
 	// Check if we have a logger by that name
-	if _, ok := levels[name]; !ok {
+	_, ok := loggers[name]
+	if !ok && config.StrictSubsystemLevels {
+		loggerMutex.Unlock()
 		return ErrNoSuchLogger
 	}
+	setSubsystemLevel(name, lvl)
+	loggerMutex.Unlock()
 
-	levels[name].SetLevel(zapcore.Level(lvl))
-
+	if !ok {
+		fmt.Fprintf(os.Stderr, "log level %s set for unknown subsystem %q, applying it once the subsystem is created\n",
+			zapcore.Level(lvl), name)
+	}
 	return nil
 }
 
@@ -267,9 +752,8 @@ func getLogger(name string) *zap.SugaredLogger {
 			level = zap.NewAtomicLevelAt(zapcore.Level(defaultLevel))
 			levels[name] = level
 		}
-		log = zap.New(loggerCore).
+		log = zap.New(&levelCore{next: &reentrantCore{next: loggerCore}, level: level}).
 			WithOptions(
-				zap.IncreaseLevel(level),
 				zap.AddCaller(),
 			).
 			Named(name).
@@ -296,20 +780,26 @@ func configFromEnv() Config {
 		format = os.Getenv(envIPFSLoggingFmt)
 	}
 
-	var noExplicitFormat bool
-
-	switch format {
-	case "color":
-		cfg.Format = ColorizedOutput
-	case "nocolor":
-		cfg.Format = PlaintextOutput
-	case "json":
-		cfg.Format = JSONOutput
-	default:
-		if format != "" {
-			fmt.Fprintf(os.Stderr, "ignoring unrecognized log format '%s'\n", format)
+	noExplicitFormat := true
+	if format != "" {
+		for _, kvs := range strings.Split(format, ",") {
+			kv := strings.SplitN(kvs, "=", 2)
+			f, ok := formatFromName(kv[len(kv)-1])
+			if !ok {
+				fmt.Fprintf(os.Stderr, "ignoring unrecognized log format '%s'\n", kvs)
+				continue
+			}
+			switch len(kv) {
+			case 1:
+				cfg.Format = f
+				noExplicitFormat = false
+			case 2:
+				if cfg.OutputFormats == nil {
+					cfg.OutputFormats = make(map[string]LogFormat)
+				}
+				cfg.OutputFormats[kv[0]] = f
+			}
 		}
-		noExplicitFormat = true
 	}
 
 	lvl := os.Getenv(envLogging)
@@ -334,6 +824,14 @@ func configFromEnv() Config {
 	}
 
 	cfg.File = os.Getenv(envLoggingFile)
+	if key := os.Getenv(envLoggingFileKey); key != "" {
+		if pub, err := hex.DecodeString(key); err != nil || len(pub) != 32 {
+			fmt.Fprintf(os.Stderr, "ignoring invalid log encryption key %q\n", key)
+		} else {
+			cfg.FileEncryptionKey = new([32]byte)
+			copy(cfg.FileEncryptionKey[:], pub)
+		}
+	}
 	// Disable stderr logging when a file is specified
 	// https://github.com/ipfs/go-log/issues/83
 	if cfg.File != "" {
@@ -383,14 +881,40 @@ func configFromEnv() Config {
 		}
 	}
 
+	if tz := os.Getenv(envLoggingTZ); tz != "" {
+		loc, err := locationFromString(tz)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "ignoring log time zone %q: %s\n", tz, err)
+		} else {
+			cfg.TimeLocation = loc
+		}
+	}
+
 	return cfg
 }
 
+// locationFromString resolves a time zone name. Besides IANA names, the
+// case-insensitive values "utc" and "local" are accepted.
+func locationFromString(tz string) (*time.Location, error) {
+	switch strings.ToLower(tz) {
+	case "utc":
+		return time.UTC, nil
+	case "local":
+		return time.Local, nil
+	default:
+		return time.LoadLocation(tz)
+	}
+}
+
 func isTerm(f *os.File) bool {
 	return isatty.IsTerminal(f.Fd()) || isatty.IsCygwinTerminal(f.Fd())
 }
 
 func pathIsTerm(p string) bool {
+	// opening a named pipe would block until there is a reader.
+	if isFIFO(p) {
+		return false
+	}
 	// !!!no!!! O_CREAT, if we fail - we fail
 	f, err := os.OpenFile(p, os.O_WRONLY, 0)
 	if f != nil {
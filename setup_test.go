@@ -7,6 +7,7 @@ import (
 	"os"
 	"strings"
 	"testing"
+	"time"
 
 	"go.uber.org/zap"
 	"go.uber.org/zap/zapcore"
@@ -217,12 +218,12 @@ func TestCustomCore(t *testing.T) {
 	}
 
 	// logging should work with the custom core
-	SetPrimaryCore(newCore(PlaintextOutput, w1, LevelDebug))
+	SetPrimaryCore(newCore(PlaintextOutput, w1, LevelDebug, nil))
 	log := getLogger("test")
 	log.Error("scooby")
 
 	// SetPrimaryCore should replace the core in previously created loggers
-	SetPrimaryCore(newCore(PlaintextOutput, w2, LevelDebug))
+	SetPrimaryCore(newCore(PlaintextOutput, w2, LevelDebug, nil))
 	log.Error("doo")
 
 	w1.Close()
@@ -258,3 +259,127 @@ func TestTeeCore(t *testing.T) {
 	SetPrimaryCore(zap.NewNop().Core())
 	log.Error("doo")
 }
+
+func TestLogTimeZoneFromEnv(t *testing.T) {
+	testCases := []struct {
+		tz   string
+		want *time.Location
+	}{
+		{tz: "", want: nil},
+		{tz: "UTC", want: time.UTC},
+		{tz: "local", want: time.Local},
+		{tz: "Not/AZone", want: nil},
+	}
+
+	for _, tc := range testCases {
+		os.Setenv(envLoggingTZ, tc.tz)
+		cfg := configFromEnv()
+		if cfg.TimeLocation != tc.want {
+			t.Errorf("%q: got location %v, want %v", tc.tz, cfg.TimeLocation, tc.want)
+		}
+	}
+	os.Unsetenv(envLoggingTZ)
+}
+
+func TestLogToBufferedFile(t *testing.T) {
+	logfile, err := ioutil.TempFile("", "go-log-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(logfile.Name())
+
+	SetupLogging(Config{
+		File:       logfile.Name(),
+		FileBuffer: BufferConfig{Size: 4096, FlushInterval: time.Hour},
+	})
+	defer SetupLogging(Config{Stderr: true})
+
+	log := getLogger("test")
+
+	want := "grokgrokgrok"
+	log.Error(want)
+
+	content, err := ioutil.ReadFile(logfile.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(content), want) {
+		t.Errorf("entry was written before the buffer was flushed")
+	}
+
+	if err := log.Sync(); err != nil {
+		t.Fatal(err)
+	}
+
+	content, err = ioutil.ReadFile(logfile.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(content), want) {
+		t.Errorf("want: '%s', got: '%s'", want, string(content))
+	}
+}
+
+func TestSetLogLevelUnknownSubsystem(t *testing.T) {
+	SetupLogging(Config{Level: LevelError})
+	defer SetupLogging(Config{Stderr: true})
+
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	stderr := os.Stderr
+	os.Stderr = w
+	err = SetLogLevel("lazy-level-test", "debug")
+	os.Stderr = stderr
+	w.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if warning, _ := ioutil.ReadAll(r); !strings.Contains(string(warning), `unknown subsystem "lazy-level-test"`) {
+		t.Errorf("got warning %q", warning)
+	}
+	getLogger("lazy-level-test")
+	if lvl := subsystemLevel("lazy-level-test"); lvl != zapcore.DebugLevel {
+		t.Errorf("got level %s, want debug", lvl)
+	}
+
+	// kept until the subsystem is created.
+	if err := SetLogLevel("later-level-test", "debug"); err != nil {
+		t.Fatal(err)
+	}
+	SetupLogging(Config{Level: LevelError})
+	SetAllLoggers(LevelWarn)
+	getLogger("later-level-test")
+	if lvl := subsystemLevel("later-level-test"); lvl != zapcore.DebugLevel {
+		t.Errorf("got level %s, want debug", lvl)
+	}
+	SetAllLoggers(LevelWarn)
+	if lvl := subsystemLevel("later-level-test"); lvl != zapcore.WarnLevel {
+		t.Errorf("got level %s once created, want warn", lvl)
+	}
+
+	SetupLogging(Config{Level: LevelError, StrictSubsystemLevels: true})
+	if err := SetLogLevel("strict-level-test", "debug"); err != ErrNoSuchLogger {
+		t.Errorf("got error %v, want %v", err, ErrNoSuchLogger)
+	}
+}
+
+func TestSetDefaultLogLevel(t *testing.T) {
+	SetupLogging(Config{Level: LevelError})
+	defer SetupLogging(Config{Stderr: true})
+
+	getLogger("default-level-old-test")
+	SetDefaultLogLevel(LevelInfo)
+	getLogger("default-level-new-test")
+
+	if lvl := subsystemLevel("default-level-old-test"); lvl != zapcore.ErrorLevel {
+		t.Errorf("got level %s for an existing subsystem, want error", lvl)
+	}
+	if lvl := subsystemLevel("default-level-new-test"); lvl != zapcore.InfoLevel {
+		t.Errorf("got level %s for a new subsystem, want info", lvl)
+	}
+	if GetConfig().Level != LevelInfo {
+		t.Errorf("got config level %s, want info", zapcore.Level(GetConfig().Level))
+	}
+}
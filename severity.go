@@ -0,0 +1,130 @@
+package log
+
+import (
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// SeverityMapping adds a field with the severity of an entry in the terms
+// of an export target, i.e. a log collector that expects syslog severities.
+// The profiles SyslogSeverity, JournaldPriority, GCPSeverity and
+// OTelSeverityNumber return the default mappings of common targets, which
+// can be modified to match other alerting conventions.
+type SeverityMapping struct {
+	// Key is the field key.
+	Key string
+
+	// Names maps levels to severity names. Levels not in the map get no
+	// field.
+	Names map[LogLevel]string
+
+	// Numbers maps levels to severity numbers, used when Names is nil.
+	Numbers map[LogLevel]int
+}
+
+// SyslogSeverity returns the mapping to the numeric syslog severities of
+// RFC 5424, from 7 (debug) to 0 (emergency), in the "severity" field.
+func SyslogSeverity() SeverityMapping {
+	return SeverityMapping{
+		Key: "severity",
+		Numbers: map[LogLevel]int{
+			LevelDebug:  7,
+			LevelInfo:   6,
+			LevelWarn:   4,
+			LevelError:  3,
+			LevelDPanic: 2,
+			LevelPanic:  1,
+			LevelFatal:  0,
+		},
+	}
+}
+
+// JournaldPriority returns the mapping to the syslog severities in the
+// PRIORITY field of journald.
+func JournaldPriority() SeverityMapping {
+	m := SyslogSeverity()
+	m.Key = "PRIORITY"
+	return m
+}
+
+// GCPSeverity returns the mapping to the LogSeverity names of Google Cloud
+// Logging in the "severity" field.
+func GCPSeverity() SeverityMapping {
+	return SeverityMapping{
+		Key: "severity",
+		Names: map[LogLevel]string{
+			LevelDebug:  "DEBUG",
+			LevelInfo:   "INFO",
+			LevelWarn:   "WARNING",
+			LevelError:  "ERROR",
+			LevelDPanic: "CRITICAL",
+			LevelPanic:  "ALERT",
+			LevelFatal:  "EMERGENCY",
+		},
+	}
+}
+
+// OTelSeverityNumber returns the mapping to the SeverityNumber of the
+// OpenTelemetry log data model in the "severity_number" field.
+func OTelSeverityNumber() SeverityMapping {
+	return SeverityMapping{
+		Key: "severity_number",
+		Numbers: map[LogLevel]int{
+			LevelDebug:  5,
+			LevelInfo:   9,
+			LevelWarn:   13,
+			LevelError:  17,
+			LevelDPanic: 18,
+			LevelPanic:  19,
+			LevelFatal:  21,
+		},
+	}
+}
+
+// field returns the severity field of lvl.
+func (m *SeverityMapping) field(lvl zapcore.Level) (zapcore.Field, bool) {
+	if m.Names != nil {
+		name, ok := m.Names[LogLevel(lvl)]
+		return zap.String(m.Key, name), ok
+	}
+	n, ok := m.Numbers[LogLevel(lvl)]
+	return zap.Int(m.Key, n), ok
+}
+
+var _ zapcore.Core = (*severityCore)(nil)
+
+// severityCore adds the fields of severity mappings to entries.
+type severityCore struct {
+	next     zapcore.Core
+	mappings []SeverityMapping
+}
+
+func (c *severityCore) With(fields []zapcore.Field) zapcore.Core {
+	return &severityCore{next: c.next.With(fields), mappings: c.mappings}
+}
+
+func (c *severityCore) Enabled(lvl zapcore.Level) bool {
+	return c.next.Enabled(lvl)
+}
+
+func (c *severityCore) Check(ent zapcore.Entry, ce *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if c.next.Enabled(ent.Level) {
+		return ce.AddCore(ent, c)
+	}
+	return ce
+}
+
+func (c *severityCore) Write(ent zapcore.Entry, fields []zapcore.Field) error {
+	tagged := make([]zapcore.Field, 0, len(c.mappings)+len(fields))
+	for i := range c.mappings {
+		if f, ok := c.mappings[i].field(ent.Level); ok {
+			tagged = append(tagged, f)
+		}
+	}
+	tagged = append(tagged, fields...)
+	return writeChecked(c.next, ent, tagged)
+}
+
+func (c *severityCore) Sync() error {
+	return c.next.Sync()
+}
@@ -0,0 +1,58 @@
+package log
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"go.uber.org/zap/zapcore"
+)
+
+func TestSeverityMappings(t *testing.T) {
+	f, err := ioutil.TempFile("", "go-log-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(f.Name())
+
+	custom := SyslogSeverity()
+	custom.Key = "syslog_severity"
+	custom.Numbers[LevelWarn] = 5
+	delete(custom.Numbers, LevelInfo)
+
+	SetupLogging(Config{
+		Format:           JSONOutput,
+		Level:            LevelInfo,
+		File:             f.Name(),
+		SeverityMappings: []SeverityMapping{GCPSeverity(), custom, OTelSeverityNumber()},
+	})
+	defer SetupLogging(Config{Stderr: true})
+
+	Logger("severity-test").Warn("disk almost full")
+
+	content, err := ioutil.ReadFile(f.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	var entry map[string]interface{}
+	if err := json.Unmarshal(content, &entry); err != nil {
+		t.Fatalf("%s: %q", err, content)
+	}
+	if entry["severity"] != "WARNING" || entry["syslog_severity"] != 5.0 || entry["severity_number"] != 13.0 {
+		t.Errorf("got entry %v", entry)
+	}
+	if SyslogSeverity().Numbers[LevelWarn] != 4 {
+		t.Error("modifying a profile changed the default")
+	}
+	if JournaldPriority().Key != "PRIORITY" || GCPSeverity().Names[LevelFatal] != "EMERGENCY" {
+		t.Error("got wrong profiles")
+	}
+}
+
+func TestSeverityCoreWriteError(t *testing.T) {
+	core := &severityCore{next: newCore(JSONOutput, failingWriteSyncer{}, LevelDebug, nil), mappings: []SeverityMapping{GCPSeverity()}}
+	if err := core.Write(zapcore.Entry{Level: zapcore.InfoLevel}, nil); err == nil {
+		t.Error("expected the write error of the output")
+	}
+}
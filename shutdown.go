@@ -0,0 +1,123 @@
+package log
+
+import (
+	"context"
+	"fmt"
+	"sync/atomic"
+
+	"go.uber.org/multierr"
+	"go.uber.org/zap/zapcore"
+)
+
+// ShutdownError reports the entries lost by Shutdown.
+type ShutdownError struct {
+	// Refused is the number of entries logged after Shutdown was called.
+	Refused uint64
+
+	// Dropped is the number of writes to outputs that failed while
+	// flushing, see SinkStatus.
+	Dropped uint64
+
+	// Unflushed is the number of bytes still buffered when the deadline
+	// expired.
+	Unflushed int
+
+	// Err is the error of flushing or stopping the outputs, or of the
+	// context when the deadline expired.
+	Err error
+}
+
+func (e *ShutdownError) Error() string {
+	msg := fmt.Sprintf("log shutdown: %d entries refused, %d writes dropped, %d bytes unflushed", e.Refused, e.Dropped, e.Unflushed)
+	if e.Err != nil {
+		msg += ": " + e.Err.Error()
+	}
+	return msg
+}
+
+func (e *ShutdownError) Unwrap() error {
+	return e.Err
+}
+
+// Shutdown stops logging in two phases. First, new entries are refused.
+// Then the outputs are synced and their background writers, such as buffers
+// and archives, are flushed and stopped, until done or ctx is done. Shutdown
+// returns a *ShutdownError if any entries were lost, nil otherwise.
+//
+// Logging resumes after the next call to SetupLogging or SetPrimaryCore.
+func Shutdown(ctx context.Context) error {
+	var before uint64
+	sinks := registeredSinks()
+	for _, m := range sinks {
+		before += m.status().Dropped
+	}
+
+	refusing := &refusingCore{refused: new(uint64)}
+	loggerMutex.Lock()
+	core := primaryCore
+	wrappers := primaryWrappers
+	primaryWrappers = nil
+	setPrimaryCore(refusing)
+	loggerMutex.Unlock()
+
+	done := make(chan error, 1)
+	go func() {
+		var err error
+		if core != nil {
+			err = core.Sync()
+		}
+		for _, w := range wrappers {
+			err = multierr.Append(err, w.Stop())
+		}
+		done <- err
+	}()
+
+	shutdownErr := &ShutdownError{}
+	select {
+	case shutdownErr.Err = <-done:
+	case <-ctx.Done():
+		shutdownErr.Err = ctx.Err()
+		for _, m := range sinks {
+			shutdownErr.Unflushed += m.status().Queued
+		}
+	}
+
+	var after uint64
+	for _, m := range sinks {
+		after += m.status().Dropped
+	}
+	shutdownErr.Dropped = after - before
+	shutdownErr.Refused = atomic.LoadUint64(refusing.refused)
+	if shutdownErr.Refused == 0 && shutdownErr.Dropped == 0 && shutdownErr.Unflushed == 0 && shutdownErr.Err == nil {
+		return nil
+	}
+	return shutdownErr
+}
+
+var _ zapcore.Core = (*refusingCore)(nil)
+
+// refusingCore counts and drops all entries.
+type refusingCore struct {
+	refused *uint64
+}
+
+func (c *refusingCore) With([]zapcore.Field) zapcore.Core {
+	return c
+}
+
+func (c *refusingCore) Enabled(zapcore.Level) bool {
+	return true
+}
+
+func (c *refusingCore) Check(ent zapcore.Entry, ce *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	atomic.AddUint64(c.refused, 1)
+	return ce
+}
+
+func (c *refusingCore) Write(zapcore.Entry, []zapcore.Field) error {
+	return nil
+}
+
+func (c *refusingCore) Sync() error {
+	return nil
+}
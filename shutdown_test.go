@@ -0,0 +1,69 @@
+package log
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestShutdown(t *testing.T) {
+	f, err := ioutil.TempFile("", "go-log-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(f.Name())
+
+	SetupLogging(Config{
+		Format:     PlaintextOutput,
+		Level:      LevelInfo,
+		File:       f.Name(),
+		FileBuffer: BufferConfig{Size: 1 << 20, FlushInterval: time.Hour},
+	})
+	defer SetupLogging(Config{Stderr: true})
+
+	log := Logger("shutdown-test")
+	log.Warn("before shutdown")
+	if err := Shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	log.Warn("after shutdown")
+
+	content, err := ioutil.ReadFile(f.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := string(content); !strings.Contains(got, "before shutdown") || strings.Contains(got, "after shutdown") {
+		t.Errorf("got %q", got)
+	}
+}
+
+type blockingStopper chan struct{}
+
+func (b blockingStopper) Stop() error {
+	<-b
+	return nil
+}
+
+func TestShutdownDeadline(t *testing.T) {
+	SetupLogging(Config{Level: LevelInfo})
+	defer SetupLogging(Config{Stderr: true})
+
+	block := make(blockingStopper)
+	defer close(block)
+	loggerMutex.Lock()
+	primaryWrappers = append(primaryWrappers, block)
+	loggerMutex.Unlock()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	err := Shutdown(ctx)
+
+	var shutdownErr *ShutdownError
+	if !errors.As(err, &shutdownErr) || !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("got error %v", err)
+	}
+}
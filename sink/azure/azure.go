@@ -0,0 +1,217 @@
+// Package azure registers a sink writing entries to Azure Monitor Logs
+// through the HTTP Data Collector API of a Log Analytics workspace. Import
+// it for its side effect and log to a URL with the "azure" scheme, naming
+// the workspace ID and the log type:
+//
+//	import _ "github.com/ipfs/go-log/v2/sink/azure"
+//
+//	log.SetupLogging(log.Config{
+//		Format: log.JSONOutput,
+//		Level:  log.LevelInfo,
+//		URL:    "azure://0123abcd-.../IPFSLog?columns=peer:PeerID",
+//	})
+//
+// The shared key of the workspace is read from the AZURE_LOG_ANALYTICS_KEY
+// environment variable, or the key option.
+//
+// Every entry becomes a record with the columns TimeGenerated, Level,
+// Logger, Caller, Message and StackTrace, and a column per field. Field
+// keys are mapped to column names by the columns option, a comma-separated
+// list of key:column pairs, and otherwise have the characters not allowed
+// in column names replaced by underscores. Values of nested fields are
+// logged as JSON. The max_entries, max_bytes, max_queued, max_retries,
+// max_spooled, spool_dir, flush_interval and retry_backoff options set the
+// fields of log.BatchConfig. The ca_file, cert_file, key_file, pinned_keys,
+// proxy and timeout options configure the HTTP client as log.HTTPClientConfig
+// does, i.e. "&proxy=http%3A%2F%2Fproxy%3A3128".
+package azure
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	log "github.com/ipfs/go-log/v2"
+	"github.com/ipfs/go-log/v2/logparse"
+	"github.com/ipfs/go-log/v2/sink/internal/httpsink"
+	"go.uber.org/zap"
+)
+
+// Scheme is the URL scheme of the sink.
+const Scheme = "azure"
+
+const (
+	envKey = "AZURE_LOG_ANALYTICS_KEY"
+
+	// maxValueSize is the maximum size of a value in a record.
+	maxValueSize = 32 << 10
+	// maxBatchBytes is the maximum size of a request.
+	maxBatchBytes = 30 << 20
+)
+
+// endpoint returns the Data Collector API of workspace, a variable for
+// testing.
+var endpoint = func(workspace string) string {
+	return "https://" + workspace + ".ods.opinsights.azure.com/api/logs?api-version=2016-04-01"
+}
+
+var (
+	logTypePattern    = regexp.MustCompile(`^[A-Za-z0-9_]{1,100}$`)
+	invalidColumnChar = regexp.MustCompile(`[^A-Za-z0-9_]`)
+)
+
+func init() {
+	if err := zap.RegisterSink(Scheme, newSink); err != nil {
+		panic(err)
+	}
+}
+
+func newSink(u *url.URL) (zap.Sink, error) {
+	workspace := u.Host
+	logType := strings.Trim(u.Path, "/")
+	if workspace == "" || !logTypePattern.MatchString(logType) {
+		return nil, fmt.Errorf("azure log URL %q must name a workspace and a log type of letters, digits and underscores, i.e. azure://<workspace-id>/IPFSLog", u)
+	}
+	q := u.Query()
+	encodedKey := q.Get("key")
+	if encodedKey == "" {
+		encodedKey = os.Getenv(envKey)
+	}
+	key, err := base64.StdEncoding.DecodeString(encodedKey)
+	if err != nil || len(key) == 0 {
+		return nil, fmt.Errorf("azure log URL %q: the workspace key is missing or not base64, set %s", u, envKey)
+	}
+	columns := make(map[string]string)
+	if v := q.Get("columns"); v != "" {
+		for _, pair := range strings.Split(v, ",") {
+			kv := strings.SplitN(pair, ":", 2)
+			if len(kv) != 2 || kv[0] == "" || invalidColumnChar.MatchString(kv[1]) {
+				return nil, fmt.Errorf("azure log URL %q: invalid column mapping %q", u, pair)
+			}
+			columns[kv[0]] = kv[1]
+		}
+	}
+	cfg, err := httpsink.BatchConfig(q)
+	if err != nil {
+		return nil, err
+	}
+	if cfg.MaxBytes <= 0 || cfg.MaxBytes > maxBatchBytes/2 {
+		// records are larger than entries, i.e. due to escaping.
+		cfg.MaxBytes = maxBatchBytes / 2
+	}
+	client, err := httpsink.Client(q)
+	if err != nil {
+		return nil, err
+	}
+
+	s := &sender{
+		client:    client,
+		workspace: workspace,
+		key:       key,
+		logType:   logType,
+		columns:   columns,
+	}
+	return log.NewBatchSink(s.send, cfg), nil
+}
+
+type sender struct {
+	client    *http.Client
+	workspace string
+	key       []byte
+	logType   string
+	columns   map[string]string
+}
+
+func (s *sender) send(lines [][]byte) error {
+	records := make([]map[string]interface{}, 0, len(lines))
+	for _, line := range lines {
+		records = append(records, s.record(line))
+	}
+	body, err := json.Marshal(records)
+	if err != nil {
+		return err
+	}
+
+	date := time.Now().UTC().Format(http.TimeFormat)
+	req, err := http.NewRequest(http.MethodPost, endpoint(s.workspace), bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Log-Type", s.logType)
+	req.Header.Set("x-ms-date", date)
+	req.Header.Set("time-generated-field", "TimeGenerated")
+	req.Header.Set("Authorization", s.authorization(len(body), date))
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return &log.RetryableError{Err: err}
+	}
+	return httpsink.CheckResponse(resp)
+}
+
+// authorization returns the SharedKey authorization of a request.
+func (s *sender) authorization(contentLength int, date string) string {
+	stringToSign := "POST\n" + strconv.Itoa(contentLength) + "\napplication/json\nx-ms-date:" + date + "\n/api/logs"
+	mac := hmac.New(sha256.New, s.key)
+	mac.Write([]byte(stringToSign))
+	return "SharedKey " + s.workspace + ":" + base64.StdEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// record converts an encoded log entry.
+func (s *sender) record(line []byte) map[string]interface{} {
+	e, err := logparse.Parse(line)
+	if err != nil {
+		return map[string]interface{}{
+			"TimeGenerated": time.Now().UTC().Format(time.RFC3339Nano),
+			"Message":       truncate(string(line)),
+		}
+	}
+
+	rec := make(map[string]interface{}, len(e.Fields)+6)
+	for k, v := range e.Fields {
+		column, ok := s.columns[k]
+		if !ok {
+			column = invalidColumnChar.ReplaceAllString(k, "_")
+		}
+		switch v := v.(type) {
+		case string:
+			rec[column] = truncate(v)
+		case map[string]interface{}, []interface{}:
+			b, _ := json.Marshal(v)
+			rec[column] = truncate(string(b))
+		default:
+			rec[column] = v
+		}
+	}
+	ts := e.Time
+	if ts.IsZero() {
+		ts = time.Now()
+	}
+	rec["TimeGenerated"] = ts.UTC().Format(time.RFC3339Nano)
+	rec["Level"] = e.Level
+	rec["Logger"] = e.Logger
+	rec["Caller"] = e.Caller
+	rec["Message"] = truncate(e.Message)
+	if e.Stacktrace != "" {
+		rec["StackTrace"] = truncate(e.Stacktrace)
+	}
+	return rec
+}
+
+// truncate limits v to the size of a value.
+func truncate(v string) string {
+	if len(v) > maxValueSize {
+		return v[:maxValueSize]
+	}
+	return v
+}
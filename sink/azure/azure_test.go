@@ -0,0 +1,79 @@
+package azure
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"testing"
+
+	log "github.com/ipfs/go-log/v2"
+)
+
+func TestSink(t *testing.T) {
+	key := []byte("workspace key")
+	records := make(chan []map[string]interface{}, 1)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		mac := hmac.New(sha256.New, key)
+		mac.Write([]byte("POST\n" + strconv.Itoa(len(body)) + "\napplication/json\nx-ms-date:" + r.Header.Get("x-ms-date") + "\n/api/logs"))
+		want := "SharedKey ws:" + base64.StdEncoding.EncodeToString(mac.Sum(nil))
+		if r.Header.Get("Authorization") != want || r.Header.Get("Log-Type") != "IPFSLog" {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		var recs []map[string]interface{}
+		if err := json.Unmarshal(body, &recs); err != nil {
+			t.Error(err)
+		}
+		records <- recs
+	}))
+	defer srv.Close()
+	defer func(f func(string) string) { endpoint = f }(endpoint)
+	endpoint = func(string) string { return srv.URL + "/api/logs" }
+
+	log.SetupLogging(log.Config{
+		Format: log.JSONOutput,
+		Level:  log.LevelInfo,
+		URL:    "azure://ws/IPFSLog?columns=peer:PeerID&key=" + url.QueryEscape(base64.StdEncoding.EncodeToString(key)),
+	})
+	defer log.SetupLogging(log.Config{Stderr: true})
+
+	logger := log.Logger("azure-test")
+	logger.Warnw("connected", "peer", "p1", "addr.port", 4001, "meta", map[string]int{"n": 1})
+	if err := logger.Sync(); err != nil {
+		t.Fatal(err)
+	}
+
+	recs := <-records
+	if len(recs) != 1 {
+		t.Fatalf("got records %v", recs)
+	}
+	rec := recs[0]
+	if rec["Message"] != "connected" || rec["Level"] != "warn" || rec["Logger"] != "azure-test" ||
+		rec["PeerID"] != "p1" || rec["addr_port"] != 4001.0 || rec["meta"] != `{"n":1}` || rec["TimeGenerated"] == "" {
+		t.Errorf("got record %v", rec)
+	}
+}
+
+func TestInvalidURL(t *testing.T) {
+	for _, raw := range []string{
+		"azure://ws/IPFS-Log?key=a2V5",
+		"azure://ws/IPFSLog?key=not+base64",
+		"azure://ws/IPFSLog?key=a2V5&columns=peer",
+		"azure://ws/IPFSLog?key=a2V5&pinned_keys=abcd",
+	} {
+		u, err := url.Parse(raw)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := newSink(u); err == nil {
+			t.Errorf("accepted %s", raw)
+		}
+	}
+}
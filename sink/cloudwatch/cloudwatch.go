@@ -0,0 +1,294 @@
+// Package cloudwatch registers a sink writing entries to Amazon CloudWatch
+// Logs, for nodes running on EC2 or ECS. Import it for its side effect and
+// log to a URL with the "cloudwatch" scheme, naming the region and the log
+// group:
+//
+//	import _ "github.com/ipfs/go-log/v2/sink/cloudwatch"
+//
+//	log.SetupLogging(log.Config{
+//		Format: log.JSONOutput,
+//		Level:  log.LevelInfo,
+//		URL:    "cloudwatch://us-east-1/?group=/ipfs/node&stream=node-1",
+//	})
+//
+// The region defaults to AWS_REGION or AWS_DEFAULT_REGION, the stream to
+// the hostname. The log group and stream are created if they don't exist.
+// Batches are kept within the limits of PutLogEvents, and entries longer
+// than the 256KiB limit of an event are truncated. The max_entries, max_bytes,
+// max_queued, max_retries, max_spooled, spool_dir, flush_interval and
+// retry_backoff options set the fields of log.BatchConfig. The ca_file,
+// cert_file, key_file, pinned_keys, proxy and timeout options configure the
+// HTTP client as log.HTTPClientConfig does, i.e.
+// "&proxy=http%3A%2F%2Fproxy%3A3128".
+//
+// Credentials are looked up like the AWS SDKs do: in the environment, the
+// shared credentials file, the ECS task role and the EC2 instance role. The
+// credential endpoints of ECS and EC2 are local and are not reached through
+// the proxy.
+package cloudwatch
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	log "github.com/ipfs/go-log/v2"
+	"github.com/ipfs/go-log/v2/logparse"
+	"github.com/ipfs/go-log/v2/sink/internal/httpsink"
+	"go.uber.org/zap"
+)
+
+// Scheme is the URL scheme of the sink.
+const Scheme = "cloudwatch"
+
+// Limits of PutLogEvents.
+const (
+	maxBatchEvents = 10000
+	maxBatchBytes  = 1 << 20
+	maxEventBytes  = 256 << 10
+	// eventOverhead is added to the size of every event in a batch.
+	eventOverhead = 26
+
+	defaultBatchEvents = 1000
+)
+
+// endpoint returns the endpoint of CloudWatch Logs in region, a variable for
+// testing.
+var endpoint = func(region string) string {
+	return "https://logs." + region + ".amazonaws.com/"
+}
+
+func init() {
+	if err := zap.RegisterSink(Scheme, newSink); err != nil {
+		panic(err)
+	}
+}
+
+func newSink(u *url.URL) (zap.Sink, error) {
+	q := u.Query()
+	region := u.Host
+	if region == "" {
+		region = os.Getenv("AWS_REGION")
+	}
+	if region == "" {
+		region = os.Getenv("AWS_DEFAULT_REGION")
+	}
+	group := q.Get("group")
+	if region == "" || group == "" {
+		return nil, fmt.Errorf("cloudwatch log URL %q must name a region and a log group, i.e. cloudwatch://us-east-1/?group=ipfs", u)
+	}
+	stream := q.Get("stream")
+	if stream == "" {
+		hostname, err := os.Hostname()
+		if err != nil {
+			return nil, fmt.Errorf("cloudwatch log URL %q names no log stream: %w", u, err)
+		}
+		stream = hostname
+	}
+
+	cfg, err := httpsink.BatchConfig(q)
+	if err != nil {
+		return nil, err
+	}
+	if cfg.MaxEntries <= 0 || cfg.MaxEntries > maxBatchEvents {
+		cfg.MaxEntries = defaultBatchEvents
+	}
+	// leave room for the overhead of the events.
+	if limit := maxBatchBytes - eventOverhead*cfg.MaxEntries; cfg.MaxBytes <= 0 || cfg.MaxBytes > limit {
+		cfg.MaxBytes = limit
+	}
+
+	client, err := httpsink.Client(q)
+	if err != nil {
+		return nil, err
+	}
+	s := &sender{
+		client: client,
+		creds:  &credentialChain{client: &http.Client{Timeout: 30 * time.Second}},
+		region: region,
+		group:  group,
+		stream: stream,
+	}
+	return log.NewBatchSink(s.send, cfg), nil
+}
+
+type inputLogEvent struct {
+	Timestamp int64  `json:"timestamp"`
+	Message   string `json:"message"`
+}
+
+type sender struct {
+	client *http.Client
+	creds  *credentialChain
+	region string
+	group  string
+	stream string
+
+	mu    sync.Mutex // guards token, sends are serialized by the batch sink
+	token string     // sequence token of the next PutLogEvents
+}
+
+func (s *sender) send(lines [][]byte) error {
+	events := make([]inputLogEvent, 0, len(lines))
+	for _, line := range lines {
+		ts := time.Now()
+		if e, err := logparse.Parse(line); err == nil && !e.Time.IsZero() {
+			ts = e.Time
+		}
+		if len(line) > maxEventBytes-eventOverhead {
+			line = line[:maxEventBytes-eventOverhead]
+		}
+		events = append(events, inputLogEvent{Timestamp: ts.UnixNano() / int64(time.Millisecond), Message: string(line)})
+	}
+	// events must be in chronological order.
+	sort.SliceStable(events, func(i, j int) bool {
+		return events[i].Timestamp < events[j].Timestamp
+	})
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	created := false
+	for attempt := 0; attempt < 3; attempt++ {
+		err := s.putLogEvents(events)
+		var apiErr *apiError
+		if !errors.As(err, &apiErr) {
+			return err
+		}
+		switch apiErr.Type {
+		case "DataAlreadyAcceptedException":
+			s.token = apiErr.ExpectedSequenceToken
+			return nil
+		case "InvalidSequenceTokenException":
+			s.token = apiErr.ExpectedSequenceToken
+		case "ResourceNotFoundException":
+			if created {
+				return err
+			}
+			if err := s.createGroupAndStream(); err != nil {
+				return err
+			}
+			created = true
+		default:
+			return err
+		}
+	}
+	return errors.New("cloudwatch: sequence token keeps changing")
+}
+
+func (s *sender) putLogEvents(events []inputLogEvent) error {
+	req := map[string]interface{}{
+		"logGroupName":  s.group,
+		"logStreamName": s.stream,
+		"logEvents":     events,
+	}
+	if s.token != "" {
+		req["sequenceToken"] = s.token
+	}
+	var resp struct {
+		NextSequenceToken string `json:"nextSequenceToken"`
+	}
+	if err := s.call("PutLogEvents", req, &resp); err != nil {
+		return err
+	}
+	s.token = resp.NextSequenceToken
+	return nil
+}
+
+// createGroupAndStream creates the log group and stream, unless they exist.
+func (s *sender) createGroupAndStream() error {
+	err := s.call("CreateLogGroup", map[string]string{"logGroupName": s.group}, nil)
+	if err != nil && !isAPIError(err, "ResourceAlreadyExistsException") {
+		return err
+	}
+	err = s.call("CreateLogStream", map[string]string{"logGroupName": s.group, "logStreamName": s.stream}, nil)
+	if err != nil && !isAPIError(err, "ResourceAlreadyExistsException") {
+		return err
+	}
+	s.token = ""
+	return nil
+}
+
+// apiError is an error response of CloudWatch Logs.
+type apiError struct {
+	Type                  string `json:"__type"`
+	Message               string `json:"message"`
+	ExpectedSequenceToken string `json:"expectedSequenceToken"`
+}
+
+func (e *apiError) Error() string {
+	return "cloudwatch: " + e.Type + ": " + e.Message
+}
+
+func isAPIError(err error, typ string) bool {
+	var apiErr *apiError
+	return errors.As(err, &apiErr) && apiErr.Type == typ
+}
+
+// retryableErrors are the error types that are worth retrying.
+var retryableErrors = map[string]bool{
+	"ThrottlingException":         true,
+	"ServiceUnavailableException": true,
+}
+
+// call calls an action of the CloudWatch Logs API.
+func (s *sender) call(action string, in, out interface{}) error {
+	body, err := json.Marshal(in)
+	if err != nil {
+		return err
+	}
+	creds, err := s.creds.get()
+	if err != nil {
+		return &log.RetryableError{Err: err}
+	}
+	req, err := http.NewRequest(http.MethodPost, endpoint(s.region), bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-amz-json-1.1")
+	req.Header.Set("X-Amz-Target", "Logs_20140328."+action)
+	signRequest(req, body, creds, s.region, "logs", time.Now())
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return &log.RetryableError{Err: err}
+	}
+	switch resp.StatusCode {
+	case http.StatusOK:
+		defer resp.Body.Close()
+		if out == nil {
+			return nil
+		}
+		return json.NewDecoder(resp.Body).Decode(out)
+	case http.StatusBadRequest:
+	default:
+		return httpsink.CheckResponse(resp)
+	}
+	defer resp.Body.Close()
+	respBody, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return &log.RetryableError{Err: err}
+	}
+	apiErr := &apiError{}
+	if err := json.Unmarshal(respBody, apiErr); err != nil {
+		return fmt.Errorf("cloudwatch: %s: %s", resp.Status, strings.TrimSpace(string(respBody)))
+	}
+	// the type may be prefixed with a namespace, i.e.
+	// "com.amazonaws.logs#ResourceNotFoundException".
+	if i := strings.LastIndexByte(apiErr.Type, '#'); i >= 0 {
+		apiErr.Type = apiErr.Type[i+1:]
+	}
+	if retryableErrors[apiErr.Type] {
+		return &log.RetryableError{Err: apiErr}
+	}
+	return apiErr
+}
@@ -0,0 +1,109 @@
+package cloudwatch
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+
+	log "github.com/ipfs/go-log/v2"
+)
+
+// setenv sets environment variables until the test ends.
+func setenv(t *testing.T, vars map[string]string) {
+	for k, v := range vars {
+		old, ok := os.LookupEnv(k)
+		os.Setenv(k, v)
+		k := k
+		t.Cleanup(func() {
+			if ok {
+				os.Setenv(k, old)
+			} else {
+				os.Unsetenv(k)
+			}
+		})
+	}
+}
+
+func TestSink(t *testing.T) {
+	setenv(t, map[string]string{"AWS_ACCESS_KEY_ID": "AKID", "AWS_SECRET_ACCESS_KEY": "secret"})
+
+	var mu sync.Mutex
+	var actions []string
+	var events []inputLogEvent
+	created := false
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+
+		if !strings.HasPrefix(r.Header.Get("Authorization"), "AWS4-HMAC-SHA256 Credential=AKID/") {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		action := strings.TrimPrefix(r.Header.Get("X-Amz-Target"), "Logs_20140328.")
+		actions = append(actions, action)
+		var req struct {
+			LogGroupName  string          `json:"logGroupName"`
+			LogStreamName string          `json:"logStreamName"`
+			LogEvents     []inputLogEvent `json:"logEvents"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.LogGroupName != "/ipfs/node" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		switch action {
+		case "CreateLogGroup":
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(`{"__type":"com.amazonaws.logs#ResourceAlreadyExistsException","message":"exists"}`))
+		case "CreateLogStream":
+			created = true
+			w.Write([]byte(`{}`))
+		case "PutLogEvents":
+			if !created {
+				w.WriteHeader(http.StatusBadRequest)
+				w.Write([]byte(`{"__type":"ResourceNotFoundException","message":"no stream"}`))
+				return
+			}
+			events = append(events, req.LogEvents...)
+			w.Write([]byte(`{"nextSequenceToken":"2"}`))
+		}
+	}))
+	defer srv.Close()
+	defer func(f func(string) string) { endpoint = f }(endpoint)
+	endpoint = func(string) string { return srv.URL + "/" }
+
+	log.SetupLogging(log.Config{
+		Format: log.JSONOutput,
+		Level:  log.LevelInfo,
+		URL:    "cloudwatch://us-east-1/?group=/ipfs/node&stream=node-1",
+	})
+	defer log.SetupLogging(log.Config{Stderr: true})
+
+	logger := log.Logger("cloudwatch-test")
+	logger.Warn("first")
+	logger.Warn("second")
+	if err := logger.Sync(); err != nil {
+		t.Fatal(err)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if got := strings.Join(actions, ","); got != "PutLogEvents,CreateLogGroup,CreateLogStream,PutLogEvents" {
+		t.Errorf("got actions %s", got)
+	}
+	if len(events) != 2 || !strings.Contains(events[0].Message, `"msg":"first"`) || events[0].Timestamp == 0 {
+		t.Errorf("got events %+v", events)
+	}
+}
+
+func TestInvalidURL(t *testing.T) {
+	if _, err := newSink(mustParse(t, "cloudwatch://us-east-1/")); err == nil {
+		t.Error("accepted a URL without a log group")
+	}
+	if _, err := newSink(mustParse(t, "cloudwatch://us-east-1/?group=ipfs&stream=node&ca_file=/no/such/ca.pem")); err == nil {
+		t.Error("accepted a missing CA file")
+	}
+}
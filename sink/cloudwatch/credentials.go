@@ -0,0 +1,229 @@
+package cloudwatch
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// errNoCredentials is returned when no source of the chain has credentials.
+var errNoCredentials = errors.New("no AWS credentials found in the environment, the shared credentials file, the ECS task role or the EC2 instance role")
+
+// credentials are AWS credentials, with the time they expire if temporary.
+type credentials struct {
+	AccessKeyID     string    `json:"AccessKeyId"`
+	SecretAccessKey string    `json:"SecretAccessKey"`
+	SessionToken    string    `json:"Token"`
+	Expiration      time.Time `json:"Expiration"`
+}
+
+// endpoints of the container and instance metadata services, variables for
+// testing.
+var (
+	ecsEndpoint  = "http://169.254.170.2"
+	imdsEndpoint = "http://169.254.169.254"
+)
+
+// credentialChain looks up credentials like the AWS SDKs do: in the
+// environment, the shared credentials file, the ECS task role and the EC2
+// instance role, in that order. Temporary credentials are cached until
+// shortly before they expire.
+type credentialChain struct {
+	client *http.Client
+
+	mu     sync.Mutex
+	cached *credentials
+}
+
+func (c *credentialChain) get() (credentials, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.cached != nil && (c.cached.Expiration.IsZero() || time.Until(c.cached.Expiration) > 5*time.Minute) {
+		return *c.cached, nil
+	}
+	sources := []func() (*credentials, error){
+		envCredentials,
+		fileCredentials,
+		c.ecsCredentials,
+		c.instanceCredentials,
+	}
+	for _, source := range sources {
+		creds, err := source()
+		if err != nil {
+			return credentials{}, err
+		}
+		if creds != nil {
+			c.cached = creds
+			return *creds, nil
+		}
+	}
+	return credentials{}, errNoCredentials
+}
+
+func envCredentials() (*credentials, error) {
+	id, secret := os.Getenv("AWS_ACCESS_KEY_ID"), os.Getenv("AWS_SECRET_ACCESS_KEY")
+	if id == "" || secret == "" {
+		return nil, nil
+	}
+	return &credentials{AccessKeyID: id, SecretAccessKey: secret, SessionToken: os.Getenv("AWS_SESSION_TOKEN")}, nil
+}
+
+// fileCredentials reads the profile named by AWS_PROFILE, "default" if
+// unset, from the file named by AWS_SHARED_CREDENTIALS_FILE, or
+// ~/.aws/credentials.
+func fileCredentials() (*credentials, error) {
+	path := os.Getenv("AWS_SHARED_CREDENTIALS_FILE")
+	if path == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return nil, nil
+		}
+		path = filepath.Join(home, ".aws", "credentials")
+	}
+	profile := os.Getenv("AWS_PROFILE")
+	if profile == "" {
+		profile = "default"
+	}
+
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	values := make(map[string]string)
+	var section string
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		switch {
+		case line == "" || line[0] == '#' || line[0] == ';':
+		case line[0] == '[':
+			section = strings.TrimSpace(strings.Trim(line, "[]"))
+		case section == profile:
+			if i := strings.IndexByte(line, '='); i >= 0 {
+				values[strings.TrimSpace(line[:i])] = strings.TrimSpace(line[i+1:])
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if values["aws_access_key_id"] == "" || values["aws_secret_access_key"] == "" {
+		return nil, nil
+	}
+	return &credentials{
+		AccessKeyID:     values["aws_access_key_id"],
+		SecretAccessKey: values["aws_secret_access_key"],
+		SessionToken:    values["aws_session_token"],
+	}, nil
+}
+
+// ecsCredentials gets the credentials of the task role from the container
+// metadata service of ECS.
+func (c *credentialChain) ecsCredentials() (*credentials, error) {
+	url := os.Getenv("AWS_CONTAINER_CREDENTIALS_FULL_URI")
+	if rel := os.Getenv("AWS_CONTAINER_CREDENTIALS_RELATIVE_URI"); rel != "" {
+		url = ecsEndpoint + rel
+	}
+	if url == "" {
+		return nil, nil
+	}
+	req, err := http.NewRequest(http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	if token := os.Getenv("AWS_CONTAINER_AUTHORIZATION_TOKEN"); token != "" {
+		req.Header.Set("Authorization", token)
+	}
+	var creds credentials
+	if err := c.getJSON(req, &creds); err != nil {
+		return nil, fmt.Errorf("getting ECS task credentials: %w", err)
+	}
+	return &creds, nil
+}
+
+// instanceCredentials gets the credentials of the instance role from the
+// EC2 instance metadata service, using IMDSv2. It returns no credentials
+// when the service is unreachable, i.e. outside of EC2.
+func (c *credentialChain) instanceCredentials() (*credentials, error) {
+	req, err := http.NewRequest(http.MethodPut, imdsEndpoint+"/latest/api/token", nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("X-aws-ec2-metadata-token-ttl-seconds", "21600")
+	// don't wait long for a service that only exists on EC2.
+	probe := &http.Client{Transport: c.client.Transport, Timeout: time.Second}
+	resp, err := probe.Do(req)
+	if err != nil {
+		return nil, nil
+	}
+	token, err := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil || resp.StatusCode != http.StatusOK {
+		return nil, nil
+	}
+
+	get := func(path string) (*http.Request, error) {
+		req, err := http.NewRequest(http.MethodGet, imdsEndpoint+"/latest/meta-data/iam/security-credentials/"+path, nil)
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("X-aws-ec2-metadata-token", string(token))
+		return req, nil
+	}
+	req, err = get("")
+	if err != nil {
+		return nil, err
+	}
+	resp, err = c.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("getting EC2 instance role: %w", err)
+	}
+	roles, err := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return nil, fmt.Errorf("getting EC2 instance role: %w", err)
+	}
+	if resp.StatusCode == http.StatusNotFound {
+		// no role attached to the instance.
+		return nil, nil
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("getting EC2 instance role: %s", resp.Status)
+	}
+	role := strings.TrimSpace(strings.SplitN(string(roles), "\n", 2)[0])
+
+	req, err = get(role)
+	if err != nil {
+		return nil, err
+	}
+	var creds credentials
+	if err := c.getJSON(req, &creds); err != nil {
+		return nil, fmt.Errorf("getting EC2 instance credentials: %w", err)
+	}
+	return &creds, nil
+}
+
+func (c *credentialChain) getJSON(req *http.Request, v interface{}) error {
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return errors.New(resp.Status)
+	}
+	return json.NewDecoder(resp.Body).Decode(v)
+}
@@ -0,0 +1,70 @@
+package cloudwatch
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func mustParse(t *testing.T, s string) *url.URL {
+	t.Helper()
+	u, err := url.Parse(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return u
+}
+
+func TestFileCredentials(t *testing.T) {
+	dir, err := ioutil.TempDir("", "go-log-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "credentials")
+	content := "[default]\naws_access_key_id = AKID\naws_secret_access_key = default\n\n" +
+		"# the profile in use\n[ipfs]\naws_access_key_id=AKIPFS\naws_secret_access_key=secret\naws_session_token=token\n"
+	if err := ioutil.WriteFile(path, []byte(content), 0600); err != nil {
+		t.Fatal(err)
+	}
+	setenv(t, map[string]string{"AWS_SHARED_CREDENTIALS_FILE": path, "AWS_PROFILE": "ipfs"})
+
+	creds, err := fileCredentials()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if creds == nil || creds.AccessKeyID != "AKIPFS" || creds.SecretAccessKey != "secret" || creds.SessionToken != "token" {
+		t.Errorf("got %+v", creds)
+	}
+}
+
+func TestECSCredentials(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v2/credentials/task" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Write([]byte(`{"AccessKeyId":"AKTASK","SecretAccessKey":"secret","Token":"token","Expiration":"2100-01-01T00:00:00Z"}`))
+	}))
+	defer srv.Close()
+	defer func(e string) { ecsEndpoint = e }(ecsEndpoint)
+	ecsEndpoint = srv.URL
+	setenv(t, map[string]string{
+		"AWS_ACCESS_KEY_ID":                      "",
+		"AWS_SHARED_CREDENTIALS_FILE":            filepath.Join(os.TempDir(), "does-not-exist"),
+		"AWS_CONTAINER_CREDENTIALS_RELATIVE_URI": "/v2/credentials/task",
+	})
+
+	chain := &credentialChain{client: srv.Client()}
+	creds, err := chain.get()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if creds.AccessKeyID != "AKTASK" || creds.SessionToken != "token" || creds.Expiration.Year() != 2100 {
+		t.Errorf("got %+v", creds)
+	}
+}
@@ -0,0 +1,200 @@
+// Package datadog registers a sink writing entries to the HTTP log intake
+// of Datadog. Import it for its side effect and log to a URL with the
+// "datadog" scheme, naming the Datadog site:
+//
+//	import _ "github.com/ipfs/go-log/v2/sink/datadog"
+//
+//	log.SetupLogging(log.Config{
+//		Format: log.JSONOutput,
+//		Level:  log.LevelInfo,
+//		URL:    "datadog://datadoghq.eu?service=ipfs&tags=env:prod,team:storage",
+//	})
+//
+// The site defaults to DD_SITE, or datadoghq.com. The API key is read from
+// DD_API_KEY. The service, source and tags options default to DD_SERVICE,
+// "go" and DD_TAGS, with DD_ENV and DD_VERSION added to the tags as env and
+// version, following the conventions of the Datadog agent.
+//
+// Entries are sent with their level as status, the subsystem as
+// logger.name and the fields as attributes. The max_entries, max_bytes,
+// max_queued, max_retries, max_spooled, spool_dir, flush_interval and
+// retry_backoff options set the fields of log.BatchConfig. The ca_file,
+// cert_file, key_file, pinned_keys, proxy and timeout options configure the
+// HTTP client as log.HTTPClientConfig does, i.e.
+// "&proxy=http%3A%2F%2Fproxy%3A3128".
+package datadog
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	log "github.com/ipfs/go-log/v2"
+	"github.com/ipfs/go-log/v2/logparse"
+	"github.com/ipfs/go-log/v2/sink/internal/httpsink"
+	"go.uber.org/zap"
+)
+
+// Scheme is the URL scheme of the sink.
+const Scheme = "datadog"
+
+// Limits of the log intake.
+const (
+	maxBatchEntries = 1000
+	maxBatchBytes   = 5 << 20
+	maxEntryBytes   = 1 << 20
+)
+
+// endpoint returns the log intake of site, a variable for testing.
+var endpoint = func(site string) string {
+	return "https://http-intake.logs." + site + "/api/v2/logs"
+}
+
+func init() {
+	if err := zap.RegisterSink(Scheme, newSink); err != nil {
+		panic(err)
+	}
+}
+
+func newSink(u *url.URL) (zap.Sink, error) {
+	q := u.Query()
+	apiKey := os.Getenv("DD_API_KEY")
+	if apiKey == "" {
+		return nil, errors.New("datadog log sink: DD_API_KEY is not set")
+	}
+	site := u.Host
+	if site == "" {
+		site = os.Getenv("DD_SITE")
+	}
+	if site == "" {
+		site = "datadoghq.com"
+	}
+	service := q.Get("service")
+	if service == "" {
+		service = os.Getenv("DD_SERVICE")
+	}
+	source := q.Get("source")
+	if source == "" {
+		source = "go"
+	}
+	tags := q.Get("tags")
+	if tags == "" {
+		tags = strings.Join(strings.Fields(strings.ReplaceAll(os.Getenv("DD_TAGS"), ",", " ")), ",")
+	}
+	for _, tag := range []struct{ name, env string }{{"env", "DD_ENV"}, {"version", "DD_VERSION"}} {
+		if v := os.Getenv(tag.env); v != "" && !strings.Contains(","+tags, ","+tag.name+":") {
+			tags = strings.TrimPrefix(tags+","+tag.name+":"+v, ",")
+		}
+	}
+	hostname, _ := os.Hostname()
+
+	cfg, err := httpsink.BatchConfig(q)
+	if err != nil {
+		return nil, err
+	}
+	if cfg.MaxEntries <= 0 || cfg.MaxEntries > maxBatchEntries {
+		cfg.MaxEntries = maxBatchEntries
+	}
+	if cfg.MaxBytes <= 0 || cfg.MaxBytes > maxBatchBytes/2 {
+		// leave room for the attributes added to entries.
+		cfg.MaxBytes = maxBatchBytes / 2
+	}
+	client, err := httpsink.Client(q)
+	if err != nil {
+		return nil, err
+	}
+
+	s := &sender{
+		client:   client,
+		url:      endpoint(site),
+		apiKey:   apiKey,
+		service:  service,
+		source:   source,
+		tags:     tags,
+		hostname: hostname,
+	}
+	return log.NewBatchSink(s.send, cfg), nil
+}
+
+type sender struct {
+	client   *http.Client
+	url      string
+	apiKey   string
+	service  string
+	source   string
+	tags     string
+	hostname string
+}
+
+func (s *sender) send(lines [][]byte) error {
+	entries := make([]map[string]interface{}, 0, len(lines))
+	for _, line := range lines {
+		entries = append(entries, s.entry(line))
+	}
+	body, err := json.Marshal(entries)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest(http.MethodPost, s.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("DD-API-KEY", s.apiKey)
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return &log.RetryableError{Err: err}
+	}
+	return httpsink.CheckResponse(resp)
+}
+
+// entry converts an encoded log entry.
+func (s *sender) entry(line []byte) map[string]interface{} {
+	var entry map[string]interface{}
+	if e, err := logparse.Parse(line); err != nil {
+		entry = map[string]interface{}{"message": string(line)}
+	} else {
+		entry = make(map[string]interface{}, len(e.Fields)+8)
+		for k, v := range e.Fields {
+			entry[k] = v
+		}
+		entry["message"] = e.Message
+		entry["status"] = e.Level
+		if !e.Time.IsZero() {
+			entry["timestamp"] = e.Time.UnixNano() / int64(time.Millisecond)
+		}
+		// logger.name and error.stack are standard attributes.
+		logger := map[string]string{}
+		if e.Logger != "" {
+			logger["name"] = e.Logger
+		}
+		if e.Caller != "" {
+			logger["caller"] = e.Caller
+		}
+		if len(logger) > 0 {
+			entry["logger"] = logger
+		}
+		if e.Stacktrace != "" {
+			entry["error"] = map[string]string{"stack": e.Stacktrace}
+		}
+	}
+	if msg, _ := entry["message"].(string); len(msg) > maxEntryBytes/2 {
+		entry["message"] = msg[:maxEntryBytes/2]
+	}
+	entry["ddsource"] = s.source
+	if s.service != "" {
+		entry["service"] = s.service
+	}
+	if s.tags != "" {
+		entry["ddtags"] = s.tags
+	}
+	if s.hostname != "" {
+		entry["hostname"] = s.hostname
+	}
+	return entry
+}
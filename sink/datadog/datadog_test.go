@@ -0,0 +1,90 @@
+package datadog
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"testing"
+
+	log "github.com/ipfs/go-log/v2"
+)
+
+// setenv sets environment variables until the test ends.
+func setenv(t *testing.T, vars map[string]string) {
+	for k, v := range vars {
+		old, ok := os.LookupEnv(k)
+		os.Setenv(k, v)
+		k := k
+		t.Cleanup(func() {
+			if ok {
+				os.Setenv(k, old)
+			} else {
+				os.Unsetenv(k)
+			}
+		})
+	}
+}
+
+func TestSink(t *testing.T) {
+	setenv(t, map[string]string{"DD_API_KEY": "key", "DD_ENV": "prod", "DD_SERVICE": "kubo"})
+
+	batches := make(chan []map[string]interface{}, 1)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("DD-API-KEY") != "key" {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		var entries []map[string]interface{}
+		if err := json.NewDecoder(r.Body).Decode(&entries); err != nil {
+			t.Error(err)
+		}
+		batches <- entries
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	defer srv.Close()
+	var site string
+	defer func(f func(string) string) { endpoint = f }(endpoint)
+	endpoint = func(s string) string {
+		site = s
+		return srv.URL
+	}
+
+	log.SetupLogging(log.Config{
+		Format: log.PlaintextOutput,
+		Level:  log.LevelInfo,
+		URL:    "datadog://datadoghq.eu?tags=team:storage",
+	})
+	defer log.SetupLogging(log.Config{Stderr: true})
+
+	logger := log.Logger("datadog-test")
+	logger.Errorw("provide failed", "cid", "bafy")
+	if err := logger.Sync(); err != nil {
+		t.Fatal(err)
+	}
+
+	entries := <-batches
+	if site != "datadoghq.eu" || len(entries) != 1 {
+		t.Fatalf("got %d entries for %s", len(entries), site)
+	}
+	e := entries[0]
+	if e["message"] != "provide failed" || e["status"] != "error" || e["logger"].(map[string]interface{})["name"] != "datadog-test" ||
+		e["cid"] != "bafy" || e["service"] != "kubo" || e["ddsource"] != "go" || e["ddtags"] != "team:storage,env:prod" {
+		t.Errorf("got entry %v", e)
+	}
+}
+
+func TestMissingAPIKey(t *testing.T) {
+	setenv(t, map[string]string{"DD_API_KEY": ""})
+	if _, err := newSink(&url.URL{Scheme: Scheme}); err == nil {
+		t.Error("created a sink without an API key")
+	}
+}
+
+func TestInvalidClientOption(t *testing.T) {
+	setenv(t, map[string]string{"DD_API_KEY": "key"})
+	if _, err := newSink(&url.URL{Scheme: Scheme, RawQuery: "timeout=soon"}); err == nil {
+		t.Error("accepted an invalid timeout")
+	}
+}
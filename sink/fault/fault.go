@@ -0,0 +1,158 @@
+// Package fault registers a sink that injects failures into the writes of
+// another output, to test how applications and the fallback paths of go-log,
+// i.e. Config.Emergency, cope with a failing logging layer. Import it for its
+// side effect and log to a URL with the "fault" scheme:
+//
+//	import _ "github.com/ipfs/go-log/v2/sink/fault"
+//
+//	log.SetupLogging(log.Config{
+//		Format: log.JSONOutput,
+//		Level:  log.LevelInfo,
+//		URL:    "fault:?url=" + url.QueryEscape("/tmp/app.log") + "&fail_every=3&latency=10ms",
+//	})
+//
+// The url option is the output that writes go to, as in Config.URL; writes
+// are discarded without it. Every fail_every-th write fails with ErrInjected,
+// or, with short_writes=true, writes half of the entry and fails with
+// io.ErrShortWrite. latency delays every write and sync. Wrap injects the
+// same failures into a zapcore.WriteSyncer, i.e. for SetPrimaryCore.
+package fault
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/url"
+	"strconv"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// Scheme is the scheme of the sink.
+const Scheme = "fault"
+
+// ErrInjected is returned by the writes that are made to fail.
+var ErrInjected = errors.New("injected log write failure")
+
+func init() {
+	if err := zap.RegisterSink(Scheme, newSink); err != nil {
+		panic(err)
+	}
+}
+
+// Config is the failures to inject.
+type Config struct {
+	// FailEvery, when greater than zero, fails every FailEvery-th write,
+	// i.e. 1 fails all writes.
+	FailEvery int
+	// ShortWrites makes failing writes write half of their data and return
+	// io.ErrShortWrite, instead of writing nothing and returning
+	// ErrInjected.
+	ShortWrites bool
+	// Latency delays every write and sync.
+	Latency time.Duration
+}
+
+var _ zap.Sink = (*Sink)(nil)
+
+// Sink injects failures into the writes of an output.
+type Sink struct {
+	ws    zapcore.WriteSyncer
+	close func()
+	cfg   Config
+
+	mu     sync.Mutex
+	writes int
+	failed int
+}
+
+// Wrap returns a Sink injecting the failures of cfg into the writes to ws.
+func Wrap(ws zapcore.WriteSyncer, cfg Config) *Sink {
+	return &Sink{ws: ws, cfg: cfg}
+}
+
+func newSink(u *url.URL) (zap.Sink, error) {
+	q := u.Query()
+	var cfg Config
+	if v := q.Get("fail_every"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			return nil, fmt.Errorf("fault log URL %q: invalid fail_every %q", u, v)
+		}
+		cfg.FailEvery = n
+	}
+	if v := q.Get("short_writes"); v != "" {
+		b, err := strconv.ParseBool(v)
+		if err != nil {
+			return nil, fmt.Errorf("fault log URL %q: invalid short_writes %q", u, v)
+		}
+		cfg.ShortWrites = b
+	}
+	if v := q.Get("latency"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil || d < 0 {
+			return nil, fmt.Errorf("fault log URL %q: invalid latency %q", u, v)
+		}
+		cfg.Latency = d
+	}
+
+	inner := q.Get("url")
+	if inner == "" {
+		return Wrap(zapcore.AddSync(ioutil.Discard), cfg), nil
+	}
+	ws, closeInner, err := zap.Open(inner)
+	if err != nil {
+		return nil, fmt.Errorf("fault log URL %q: %w", u, err)
+	}
+	s := Wrap(ws, cfg)
+	s.close = closeInner
+	return s, nil
+}
+
+func (s *Sink) Write(p []byte) (int, error) {
+	time.Sleep(s.cfg.Latency)
+
+	s.mu.Lock()
+	s.writes++
+	fail := s.cfg.FailEvery > 0 && s.writes%s.cfg.FailEvery == 0
+	if fail {
+		s.failed++
+	}
+	s.mu.Unlock()
+
+	if !fail {
+		return s.ws.Write(p)
+	}
+	if !s.cfg.ShortWrites {
+		return 0, ErrInjected
+	}
+	n, err := s.ws.Write(p[:len(p)/2])
+	if err != nil {
+		return n, err
+	}
+	return n, io.ErrShortWrite
+}
+
+func (s *Sink) Sync() error {
+	time.Sleep(s.cfg.Latency)
+	return s.ws.Sync()
+}
+
+// Close closes the output of a sink opened by URL.
+func (s *Sink) Close() error {
+	if s.close != nil {
+		s.close()
+	}
+	return nil
+}
+
+// Failed returns the number of writes that were made to fail.
+func (s *Sink) Failed() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.failed
+}
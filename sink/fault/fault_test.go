@@ -0,0 +1,108 @@
+package fault
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	log "github.com/ipfs/go-log/v2"
+	"go.uber.org/zap/zapcore"
+)
+
+func TestFailEvery(t *testing.T) {
+	buf := &bytes.Buffer{}
+	s := Wrap(zapcore.AddSync(buf), Config{FailEvery: 2})
+
+	var errs []error
+	for _, line := range []string{"a\n", "b\n", "c\n", "d\n"} {
+		_, err := s.Write([]byte(line))
+		errs = append(errs, err)
+	}
+	if errs[0] != nil || errs[1] != ErrInjected || errs[2] != nil || errs[3] != ErrInjected {
+		t.Errorf("got errors %v", errs)
+	}
+	if buf.String() != "a\nc\n" || s.Failed() != 2 {
+		t.Errorf("got %q and %d failures", buf.String(), s.Failed())
+	}
+}
+
+func TestShortWrites(t *testing.T) {
+	buf := &bytes.Buffer{}
+	s := Wrap(zapcore.AddSync(buf), Config{FailEvery: 1, ShortWrites: true})
+
+	n, err := s.Write([]byte("abcdef"))
+	if n != 3 || err != io.ErrShortWrite || buf.String() != "abc" {
+		t.Errorf("got %d, %v and %q", n, err, buf.String())
+	}
+}
+
+func TestLatency(t *testing.T) {
+	s := Wrap(zapcore.AddSync(ioutil.Discard), Config{Latency: 20 * time.Millisecond})
+
+	start := time.Now()
+	if _, err := s.Write([]byte("a\n")); err != nil {
+		t.Fatal(err)
+	}
+	if d := time.Since(start); d < 20*time.Millisecond {
+		t.Errorf("write took %s", d)
+	}
+}
+
+func TestEmergency(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("file URLs differ on windows")
+	}
+	dir, err := ioutil.TempDir("", "fault")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	output, emergency := filepath.Join(dir, "output.log"), filepath.Join(dir, "emergency.log")
+
+	log.SetupLogging(log.Config{
+		Format:    log.JSONOutput,
+		Level:     log.LevelInfo,
+		URL:       "fault:?fail_every=1&url=" + url.QueryEscape(output),
+		Emergency: log.EmergencyConfig{Output: emergency},
+	})
+	defer log.SetupLogging(log.Config{Stderr: true})
+
+	logger := log.Logger("fault-test")
+	logger.Error("first")
+	logger.Error("second")
+	logger.Sync()
+
+	content, err := ioutil.ReadFile(emergency)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(content), "second") {
+		t.Errorf("got %q in the emergency output", content)
+	}
+	if content, _ := ioutil.ReadFile(output); len(content) > 0 {
+		t.Errorf("got %q in the failing output", content)
+	}
+}
+
+func TestInvalidURL(t *testing.T) {
+	for _, u := range []string{
+		"fault:?fail_every=x",
+		"fault:?latency=-1s",
+		"fault:?short_writes=maybe",
+	} {
+		parsed, err := url.Parse(u)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := newSink(parsed); err == nil {
+			t.Errorf("accepted %q", u)
+		}
+	}
+}
@@ -0,0 +1,240 @@
+// Package gcp registers a sink writing entries to Google Cloud Logging, for
+// nodes running on Google Cloud. Import it for its side effect and log to a
+// URL with the "gcp" scheme, naming the project and the log:
+//
+//	import _ "github.com/ipfs/go-log/v2/sink/gcp"
+//
+//	log.SetupLogging(log.Config{
+//		Format: log.JSONOutput,
+//		Level:  log.LevelInfo,
+//		URL:    "gcp://my-project/ipfs?trace_key=trace_id",
+//	})
+//
+// Entries are written as structured entries with the severity mapped by
+// log.GCPSeverity. The value of the field named by the trace_key option is
+// linked to Cloud Trace. The resource option sets the monitored resource
+// type, "global" by default. The max_entries, max_bytes, max_queued,
+// max_retries, max_spooled, spool_dir, flush_interval and retry_backoff
+// options set the fields of log.BatchConfig. The ca_file, cert_file,
+// key_file, pinned_keys, proxy and timeout options configure the HTTP client
+// as log.HTTPClientConfig does, i.e. "&proxy=http%3A%2F%2Fproxy%3A3128".
+// Human-readable output is written as text entries.
+//
+// Access tokens are obtained from the metadata server of the instance.
+package gcp
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	log "github.com/ipfs/go-log/v2"
+	"github.com/ipfs/go-log/v2/logparse"
+	"github.com/ipfs/go-log/v2/sink/internal/httpsink"
+	"go.uber.org/zap"
+)
+
+// Scheme is the URL scheme of the sink.
+const Scheme = "gcp"
+
+// endpoints, variables for testing.
+var (
+	writeURL    = "https://logging.googleapis.com/v2/entries:write"
+	metadataURL = "http://metadata.google.internal/computeMetadata/v1/instance/service-accounts/default/token"
+)
+
+func init() {
+	if err := zap.RegisterSink(Scheme, newSink); err != nil {
+		panic(err)
+	}
+}
+
+func newSink(u *url.URL) (zap.Sink, error) {
+	project := u.Host
+	logID := strings.Trim(u.Path, "/")
+	if project == "" || logID == "" {
+		return nil, fmt.Errorf("gcp log URL %q must name a project and a log, i.e. gcp://my-project/ipfs", u)
+	}
+	q := u.Query()
+	cfg, err := httpsink.BatchConfig(q)
+	if err != nil {
+		return nil, err
+	}
+	client, err := httpsink.Client(q)
+	if err != nil {
+		return nil, err
+	}
+	resource := q.Get("resource")
+	if resource == "" {
+		resource = "global"
+	}
+
+	s := &sender{
+		client:         client,
+		metadataClient: &http.Client{Timeout: 30 * time.Second},
+		logName:        "projects/" + project + "/logs/" + url.PathEscape(logID),
+		project:        project,
+		resource:       resource,
+		traceKey:       q.Get("trace_key"),
+		severity:       log.GCPSeverity(),
+	}
+	return log.NewBatchSink(s.send, cfg), nil
+}
+
+type monitoredResource struct {
+	Type string `json:"type"`
+}
+
+type sourceLocation struct {
+	File string `json:"file"`
+	Line string `json:"line,omitempty"`
+}
+
+// entry is a LogEntry of the Cloud Logging API.
+type entry struct {
+	Timestamp      string                 `json:"timestamp,omitempty"`
+	Severity       string                 `json:"severity"`
+	JSONPayload    map[string]interface{} `json:"jsonPayload,omitempty"`
+	TextPayload    string                 `json:"textPayload,omitempty"`
+	Trace          string                 `json:"trace,omitempty"`
+	SourceLocation *sourceLocation        `json:"sourceLocation,omitempty"`
+	Labels         map[string]string      `json:"labels,omitempty"`
+}
+
+type sender struct {
+	client         *http.Client
+	metadataClient *http.Client // the metadata server is local, not reached through the proxy
+	logName        string
+	project        string
+	resource       string
+	traceKey       string
+	severity       log.SeverityMapping
+
+	mu      sync.Mutex
+	token   string
+	expires time.Time
+}
+
+func (s *sender) send(lines [][]byte) error {
+	entries := make([]entry, 0, len(lines))
+	for _, line := range lines {
+		entries = append(entries, s.entry(line))
+	}
+	body, err := json.Marshal(map[string]interface{}{
+		"logName":        s.logName,
+		"resource":       monitoredResource{Type: s.resource},
+		"entries":        entries,
+		"partialSuccess": true,
+	})
+	if err != nil {
+		return err
+	}
+
+	token, err := s.accessToken()
+	if err != nil {
+		return &log.RetryableError{Err: err}
+	}
+	req, err := http.NewRequest(http.MethodPost, writeURL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+token)
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return &log.RetryableError{Err: err}
+	}
+	return httpsink.CheckResponse(resp)
+}
+
+// entry converts an encoded log entry.
+func (s *sender) entry(line []byte) entry {
+	e, err := logparse.Parse(line)
+	if err != nil {
+		return entry{Severity: "DEFAULT", TextPayload: string(line)}
+	}
+
+	ent := entry{Severity: "DEFAULT"}
+	if !e.Time.IsZero() {
+		ent.Timestamp = e.Time.UTC().Format(time.RFC3339Nano)
+	}
+	if lvl, err := log.LevelFromString(e.Level); err == nil {
+		if name, ok := s.severity.Names[lvl]; ok {
+			ent.Severity = name
+		}
+	}
+	if e.Caller != "" {
+		loc := &sourceLocation{File: e.Caller}
+		if i := strings.LastIndexByte(e.Caller, ':'); i >= 0 {
+			if _, err := strconv.Atoi(e.Caller[i+1:]); err == nil {
+				loc.File, loc.Line = e.Caller[:i], e.Caller[i+1:]
+			}
+		}
+		ent.SourceLocation = loc
+	}
+	if e.Logger != "" {
+		ent.Labels = map[string]string{"logger": e.Logger}
+	}
+	if line[0] != '{' {
+		// human-readable output.
+		ent.TextPayload = string(line)
+		return ent
+	}
+
+	if s.traceKey != "" {
+		if trace, ok := e.Fields[s.traceKey].(string); ok && trace != "" {
+			ent.Trace = "projects/" + s.project + "/traces/" + trace
+		}
+	}
+	payload := e.Fields
+	if payload == nil {
+		payload = make(map[string]interface{})
+	}
+	payload["message"] = e.Message
+	if e.Stacktrace != "" {
+		payload["stacktrace"] = e.Stacktrace
+	}
+	ent.JSONPayload = payload
+	return ent
+}
+
+// accessToken returns a token of the service account of the instance,
+// cached until shortly before it expires.
+func (s *sender) accessToken() (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.token != "" && time.Now().Before(s.expires) {
+		return s.token, nil
+	}
+	req, err := http.NewRequest(http.MethodGet, metadataURL, nil)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Metadata-Flavor", "Google")
+	resp, err := s.metadataClient.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("getting access token: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("getting access token: %s", resp.Status)
+	}
+
+	var token struct {
+		AccessToken string `json:"access_token"`
+		ExpiresIn   int    `json:"expires_in"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&token); err != nil {
+		return "", fmt.Errorf("getting access token: %w", err)
+	}
+	s.token = token.AccessToken
+	s.expires = time.Now().Add(time.Duration(token.ExpiresIn)*time.Second - time.Minute)
+	return s.token, nil
+}
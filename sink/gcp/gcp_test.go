@@ -0,0 +1,93 @@
+package gcp
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	log "github.com/ipfs/go-log/v2"
+)
+
+func TestSink(t *testing.T) {
+	requests := make(chan map[string]interface{}, 10)
+	attempts := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/token":
+			if r.Header.Get("Metadata-Flavor") != "Google" {
+				w.WriteHeader(http.StatusForbidden)
+				return
+			}
+			w.Write([]byte(`{"access_token":"secret","expires_in":3600}`))
+		case "/write":
+			if r.Header.Get("Authorization") != "Bearer secret" {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			// the first attempt hits the quota.
+			if attempts++; attempts == 1 {
+				w.WriteHeader(http.StatusTooManyRequests)
+				return
+			}
+			var body map[string]interface{}
+			if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+				t.Error(err)
+			}
+			requests <- body
+		}
+	}))
+	defer srv.Close()
+	defer func(w, m string) { writeURL, metadataURL = w, m }(writeURL, metadataURL)
+	writeURL, metadataURL = srv.URL+"/write", srv.URL+"/token"
+
+	log.SetupLogging(log.Config{
+		Format: log.JSONOutput,
+		Level:  log.LevelInfo,
+		URL:    "gcp://my-project/ipfs?trace_key=trace_id&retry_backoff=1ms",
+	})
+	defer log.SetupLogging(log.Config{Stderr: true})
+
+	logger := log.Logger("gcp-test")
+	logger.Warnw("disk almost full", "trace_id", "abc", "free", 10)
+	if err := logger.Sync(); err != nil {
+		t.Fatal(err)
+	}
+
+	body := <-requests
+	if body["logName"] != "projects/my-project/logs/ipfs" {
+		t.Errorf("got log name %v", body["logName"])
+	}
+	entries, _ := body["entries"].([]interface{})
+	if len(entries) != 1 {
+		t.Fatalf("got entries %v", body["entries"])
+	}
+	entry := entries[0].(map[string]interface{})
+	payload, _ := entry["jsonPayload"].(map[string]interface{})
+	if entry["severity"] != "WARNING" || entry["trace"] != "projects/my-project/traces/abc" ||
+		payload["message"] != "disk almost full" || payload["free"] != 10.0 {
+		t.Errorf("got entry %v", entry)
+	}
+	if labels, _ := entry["labels"].(map[string]interface{}); labels["logger"] != "gcp-test" {
+		t.Errorf("got labels %v", entry["labels"])
+	}
+}
+
+func TestInvalidURL(t *testing.T) {
+	if _, err := newSink(mustParse(t, "gcp://my-project")); err == nil {
+		t.Error("accepted a URL without a log")
+	}
+	if _, err := newSink(mustParse(t, "gcp://my-project/ipfs?proxy=ftp%3A%2F%2Fproxy")); err == nil {
+		t.Error("accepted an unsupported proxy")
+	}
+}
+
+func mustParse(t *testing.T, s string) *url.URL {
+	t.Helper()
+	u, err := url.Parse(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return u
+}
@@ -0,0 +1,168 @@
+// Package httpsink has helpers shared by the sinks sending entries to HTTP
+// APIs and other network services.
+package httpsink
+
+import (
+	"crypto/sha256"
+	"crypto/tls"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	log "github.com/ipfs/go-log/v2"
+)
+
+// maxErrorBody is the number of bytes of a response body included in errors.
+const maxErrorBody = 512
+
+// CheckResponse returns nil for successful responses. Throttled and
+// unavailable responses (429 and 5xx) are returned as a log.RetryableError,
+// honoring Retry-After. The body is consumed and closed.
+func CheckResponse(resp *http.Response) error {
+	defer resp.Body.Close()
+	body, _ := ioutil.ReadAll(io.LimitReader(resp.Body, maxErrorBody))
+	_, _ = io.Copy(ioutil.Discard, resp.Body)
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return nil
+	}
+
+	err := fmt.Errorf("%s: %s", resp.Status, strings.TrimSpace(string(body)))
+	if resp.StatusCode != http.StatusTooManyRequests && resp.StatusCode < 500 {
+		return err
+	}
+	return &log.RetryableError{Err: err, After: retryAfter(resp.Header.Get("Retry-After"))}
+}
+
+// retryAfter parses the value of a Retry-After header, in seconds or as an
+// HTTP date.
+func retryAfter(v string) time.Duration {
+	if v == "" {
+		return 0
+	}
+	if secs, err := strconv.Atoi(v); err == nil && secs > 0 {
+		return time.Duration(secs) * time.Second
+	}
+	if t, err := http.ParseTime(v); err == nil {
+		if d := time.Until(t); d > 0 {
+			return d
+		}
+	}
+	return 0
+}
+
+// BatchConfig reads the batching options of a sink URL: max_entries,
+// max_bytes, max_queued, max_retries and max_spooled as numbers,
+// flush_interval and retry_backoff as durations, i.e. "?flush_interval=10s",
+// and spool_dir as the directory batches are kept in during outages.
+func BatchConfig(q url.Values) (log.BatchConfig, error) {
+	cfg := log.BatchConfig{SpoolDir: q.Get("spool_dir")}
+	ints := map[string]*int{
+		"max_entries": &cfg.MaxEntries,
+		"max_bytes":   &cfg.MaxBytes,
+		"max_queued":  &cfg.MaxQueued,
+		"max_retries": &cfg.MaxRetries,
+	}
+	for key, p := range ints {
+		if v := q.Get(key); v != "" {
+			n, err := strconv.Atoi(v)
+			if err != nil {
+				return cfg, fmt.Errorf("invalid %s %q: %w", key, v, err)
+			}
+			*p = n
+		}
+	}
+	if v := q.Get("max_spooled"); v != "" {
+		n, err := strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			return cfg, fmt.Errorf("invalid max_spooled %q: %w", v, err)
+		}
+		cfg.MaxSpooled = n
+	}
+	durations := map[string]*time.Duration{
+		"flush_interval": &cfg.FlushInterval,
+		"retry_backoff":  &cfg.RetryBackoff,
+	}
+	for key, p := range durations {
+		if v := q.Get(key); v != "" {
+			d, err := time.ParseDuration(v)
+			if err != nil {
+				return cfg, fmt.Errorf("invalid %s %q: %w", key, v, err)
+			}
+			*p = d
+		}
+	}
+	return cfg, nil
+}
+
+// ClientConfig reads the TLS and proxy options of a sink URL into a
+// log.HTTPClientConfig: ca_file, cert_file and key_file as paths, pinned_keys
+// as comma-separated hex SHA-256 pins, see log.PublicKeyPin, proxy as a URL
+// and timeout as a duration.
+func ClientConfig(q url.Values) (log.HTTPClientConfig, error) {
+	cfg := log.HTTPClientConfig{
+		CAFile:   q.Get("ca_file"),
+		CertFile: q.Get("cert_file"),
+		KeyFile:  q.Get("key_file"),
+		Proxy:    q.Get("proxy"),
+	}
+	if v := q.Get("pinned_keys"); v != "" {
+		for _, s := range strings.Split(v, ",") {
+			pin, err := hex.DecodeString(strings.TrimSpace(s))
+			if err != nil || len(pin) != sha256.Size {
+				return cfg, fmt.Errorf("invalid pinned key %q", s)
+			}
+			cfg.PinnedKeys = append(cfg.PinnedKeys, pin)
+		}
+	}
+	if v := q.Get("timeout"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil {
+			return cfg, fmt.Errorf("invalid timeout %q: %w", v, err)
+		}
+		cfg.Timeout = d
+	}
+	return cfg, nil
+}
+
+// Client returns the HTTP client configured by the options of a sink URL, see
+// ClientConfig.
+func Client(q url.Values) (*http.Client, error) {
+	cfg, err := ClientConfig(q)
+	if err != nil {
+		return nil, err
+	}
+	return cfg.Client()
+}
+
+// TLSConfig returns the TLS settings given by the options of a sink URL, see
+// ClientConfig, for connections to the host of u, i.e. of sinks speaking
+// other protocols than HTTP.
+func TLSConfig(u *url.URL) (*tls.Config, error) {
+	cfg, err := ClientConfig(u.Query())
+	if err != nil {
+		return nil, err
+	}
+	tlsConfig, err := cfg.TLSConfig()
+	if err != nil {
+		return nil, err
+	}
+	tlsConfig.ServerName = u.Hostname()
+	return tlsConfig, nil
+}
+
+// IsLoopback reports whether host, a host name or an IP address, is the
+// local host, which credentials may be sent to without TLS.
+func IsLoopback(host string) bool {
+	if host == "localhost" {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
@@ -0,0 +1,102 @@
+package httpsink
+
+import (
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	log "github.com/ipfs/go-log/v2"
+)
+
+func response(status int, header http.Header) *http.Response {
+	return &http.Response{
+		StatusCode: status,
+		Status:     http.StatusText(status),
+		Header:     header,
+		Body:       ioutil.NopCloser(strings.NewReader("details")),
+	}
+}
+
+func TestCheckResponse(t *testing.T) {
+	if err := CheckResponse(response(http.StatusAccepted, http.Header{})); err != nil {
+		t.Errorf("got %v", err)
+	}
+
+	err := CheckResponse(response(http.StatusTooManyRequests, http.Header{"Retry-After": {"3"}}))
+	var retryable *log.RetryableError
+	if !errors.As(err, &retryable) || retryable.After != 3*time.Second {
+		t.Errorf("got %v", err)
+	}
+
+	err = CheckResponse(response(http.StatusBadRequest, http.Header{}))
+	if err == nil || errors.As(err, &retryable) || !strings.Contains(err.Error(), "details") {
+		t.Errorf("got %v", err)
+	}
+}
+
+func TestBatchConfig(t *testing.T) {
+	cfg, err := BatchConfig(url.Values{"max_entries": {"10"}, "flush_interval": {"2s"}, "spool_dir": {"/var/spool/log"}, "max_spooled": {"1024"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.MaxEntries != 10 || cfg.FlushInterval != 2*time.Second || cfg.SpoolDir != "/var/spool/log" || cfg.MaxSpooled != 1024 {
+		t.Errorf("got %+v", cfg)
+	}
+	if _, err := BatchConfig(url.Values{"max_bytes": {"lots"}}); err == nil {
+		t.Error("accepted an invalid number")
+	}
+}
+
+func TestClientConfig(t *testing.T) {
+	pin := strings.Repeat("ab", 32)
+	cfg, err := ClientConfig(url.Values{
+		"ca_file":     {"/etc/ca.pem"},
+		"proxy":       {"http://proxy:3128"},
+		"pinned_keys": {pin + "," + pin},
+		"timeout":     {"5s"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.CAFile != "/etc/ca.pem" || cfg.Proxy != "http://proxy:3128" || len(cfg.PinnedKeys) != 2 || cfg.Timeout != 5*time.Second {
+		t.Errorf("got %+v", cfg)
+	}
+	if _, err := ClientConfig(url.Values{"pinned_keys": {"abcd"}}); err == nil {
+		t.Error("accepted a short pin")
+	}
+
+	client, err := Client(url.Values{"proxy": {"socks5://127.0.0.1:1080"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if client.Timeout != 30*time.Second {
+		t.Errorf("got timeout %s", client.Timeout)
+	}
+	if _, err := Client(url.Values{"proxy": {"ftp://proxy"}}); err == nil {
+		t.Error("accepted an unsupported proxy")
+	}
+}
+
+func TestTLSConfig(t *testing.T) {
+	u, err := url.Parse("mqtts://broker.example:8883/logs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg, err := TLSConfig(u)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.ServerName != "broker.example" {
+		t.Errorf("got server name %q", cfg.ServerName)
+	}
+
+	for host, want := range map[string]bool{"localhost": true, "127.0.0.1": true, "::1": true, "broker": false, "10.0.0.1": false} {
+		if got := IsLoopback(host); got != want {
+			t.Errorf("IsLoopback(%q) = %v, want %v", host, got, want)
+		}
+	}
+}
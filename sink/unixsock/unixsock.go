@@ -0,0 +1,149 @@
+// Package unixsock registers sinks writing entries to a unix datagram or
+// seqpacket socket, a low overhead transport to a collector agent on the
+// same host. Import it for its side effect and log to a URL with the
+// "unixgram" or "unixpacket" scheme, naming the socket of the collector:
+//
+//	import _ "github.com/ipfs/go-log/v2/sink/unixsock"
+//
+//	log.SetupLogging(log.Config{
+//		Format: log.JSONOutput,
+//		Level:  log.LevelInfo,
+//		URL:    "unixgram:///run/collector/ipfs.sock",
+//	})
+//
+// Entries are packed into messages of at most max_message bytes, 64KiB by
+// default, every entry prefixed with its length as a 32-bit big-endian
+// integer. Since the kernel delivers messages whole and blocks writers while
+// the collector catches up, entries are not lost nor partially read, unlike
+// with pipes. Entries longer than a message are truncated.
+//
+// When the collector restarts the socket is reconnected, and the messages
+// written meanwhile are queued and retried. The max_entries, max_queued,
+// max_retries, max_spooled, spool_dir, flush_interval and retry_backoff
+// options set the fields of log.BatchConfig; flush_interval defaults to
+// 100ms.
+package unixsock
+
+import (
+	"encoding/binary"
+	"fmt"
+	"net"
+	"net/url"
+	"strconv"
+	"time"
+
+	log "github.com/ipfs/go-log/v2"
+	"github.com/ipfs/go-log/v2/sink/internal/httpsink"
+	"go.uber.org/zap"
+)
+
+// Schemes of the sinks, named after the networks of package net.
+const (
+	DatagramScheme  = "unixgram"
+	SeqpacketScheme = "unixpacket"
+)
+
+const (
+	defaultMaxMessage    = 64 << 10
+	defaultBatchEntries  = 500
+	defaultFlushInterval = 100 * time.Millisecond
+	writeTimeout         = 10 * time.Second
+
+	// prefixSize is the size of the length prefix of an entry.
+	prefixSize = 4
+)
+
+func init() {
+	for _, scheme := range []string{DatagramScheme, SeqpacketScheme} {
+		scheme := scheme
+		err := zap.RegisterSink(scheme, func(u *url.URL) (zap.Sink, error) {
+			return newSink(scheme, u)
+		})
+		if err != nil {
+			panic(err)
+		}
+	}
+}
+
+func newSink(network string, u *url.URL) (zap.Sink, error) {
+	if u.Host != "" || u.Path == "" {
+		return nil, fmt.Errorf("%s log URL %q must name the path of a socket, i.e. %s:///run/collector.sock", network, u, network)
+	}
+	q := u.Query()
+	maxMessage := defaultMaxMessage
+	if v := q.Get("max_message"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 2*prefixSize {
+			return nil, fmt.Errorf("%s log URL %q: invalid max_message %q", network, u, v)
+		}
+		maxMessage = n
+	}
+	cfg, err := httpsink.BatchConfig(q)
+	if err != nil {
+		return nil, err
+	}
+	if cfg.FlushInterval <= 0 {
+		cfg.FlushInterval = defaultFlushInterval
+	}
+	// a batch is sent as a single message, so that retrying it after an
+	// error does not repeat entries. Length prefixes take at most half of it.
+	if cfg.MaxEntries <= 0 {
+		cfg.MaxEntries = defaultBatchEntries
+	}
+	if limit := maxMessage / (2 * prefixSize); cfg.MaxEntries > limit {
+		cfg.MaxEntries = limit
+	}
+	cfg.MaxBytes = maxMessage - prefixSize*cfg.MaxEntries
+
+	w := &writer{network: network, path: u.Path, maxMessage: maxMessage}
+	return log.NewBatchSink(w.send, cfg), nil
+}
+
+// writer writes batches of entries to a socket, connecting on demand.
+type writer struct {
+	network    string
+	path       string
+	maxMessage int
+
+	conn net.Conn // nil when not connected, only used by send
+	buf  []byte
+}
+
+func (w *writer) send(lines [][]byte) error {
+	w.buf = w.frame(w.buf[:0], lines)
+
+	if w.conn == nil {
+		c, err := net.Dial(w.network, w.path)
+		if err != nil {
+			// the collector is not running, or restarting.
+			return &log.RetryableError{Err: err}
+		}
+		w.conn = c
+	}
+	w.conn.SetWriteDeadline(time.Now().Add(writeTimeout))
+	if _, err := w.conn.Write(w.buf); err != nil {
+		// a restarted collector listens on a new socket.
+		w.conn.Close()
+		w.conn = nil
+		return &log.RetryableError{Err: err}
+	}
+	return nil
+}
+
+// frame appends the length-prefixed entries to b. Only the single entry of a
+// batch can exceed a message, and is truncated.
+func (w *writer) frame(b []byte, lines [][]byte) []byte {
+	for _, line := range lines {
+		room := w.maxMessage - len(b) - prefixSize
+		if room <= 0 {
+			break
+		}
+		if len(line) > room {
+			line = line[:room]
+		}
+		b = append(b, 0, 0, 0, 0)
+		binary.BigEndian.PutUint32(b[len(b)-prefixSize:], uint32(len(line)))
+		b = append(b, line...)
+	}
+	return b
+}
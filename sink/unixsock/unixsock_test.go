@@ -0,0 +1,162 @@
+package unixsock
+
+import (
+	"encoding/binary"
+	"io/ioutil"
+	"net"
+	"net/url"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	log "github.com/ipfs/go-log/v2"
+)
+
+// socketPath returns the path of a socket in a new directory, short enough
+// for the limits of socket addresses.
+func socketPath(t *testing.T) string {
+	if runtime.GOOS == "windows" || runtime.GOOS == "js" || runtime.GOOS == "plan9" {
+		t.Skip("no unix datagram sockets on", runtime.GOOS)
+	}
+	dir, err := ioutil.TempDir("", "unixsock")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+	return filepath.Join(dir, "s")
+}
+
+// entries splits a message into its entries.
+func entries(t *testing.T, msg []byte) []string {
+	var entries []string
+	for len(msg) > 0 {
+		if len(msg) < prefixSize {
+			t.Fatalf("truncated prefix % x", msg)
+		}
+		n := int(binary.BigEndian.Uint32(msg))
+		msg = msg[prefixSize:]
+		if n > len(msg) {
+			t.Fatalf("entry of %d bytes in %d", n, len(msg))
+		}
+		entries = append(entries, string(msg[:n]))
+		msg = msg[n:]
+	}
+	return entries
+}
+
+func listenDatagram(t *testing.T, path string) *net.UnixConn {
+	c, err := net.ListenUnixgram(DatagramScheme, &net.UnixAddr{Name: path, Net: DatagramScheme})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return c
+}
+
+func TestDatagram(t *testing.T) {
+	path := socketPath(t)
+	collector := listenDatagram(t, path)
+	defer collector.Close()
+
+	log.SetupLogging(log.Config{
+		Format: log.JSONOutput,
+		Level:  log.LevelInfo,
+		URL:    DatagramScheme + "://" + path,
+	})
+	defer log.SetupLogging(log.Config{Stderr: true})
+
+	logger := log.Logger("unixsock-test")
+	logger.Warn("first")
+	logger.Warn("second")
+	if err := logger.Sync(); err != nil {
+		t.Fatal(err)
+	}
+
+	buf := make([]byte, defaultMaxMessage)
+	n, err := collector.Read(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := entries(t, buf[:n])
+	if len(got) != 2 || !strings.Contains(got[0], `"msg":"first"`) || !strings.Contains(got[1], `"msg":"second"`) {
+		t.Errorf("got entries %q", got)
+	}
+}
+
+func TestSeqpacket(t *testing.T) {
+	path := socketPath(t)
+	l, err := net.ListenUnix(SeqpacketScheme, &net.UnixAddr{Name: path, Net: SeqpacketScheme})
+	if err != nil {
+		t.Skip("no seqpacket sockets:", err)
+	}
+	defer l.Close()
+
+	w := &writer{network: SeqpacketScheme, path: path, maxMessage: defaultMaxMessage}
+	if err := w.send([][]byte{[]byte("a"), []byte("bc")}); err != nil {
+		t.Fatal(err)
+	}
+	c, err := l.Accept()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	buf := make([]byte, defaultMaxMessage)
+	n, err := c.Read(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := entries(t, buf[:n]); len(got) != 2 || got[0] != "a" || got[1] != "bc" {
+		t.Errorf("got entries %q", got)
+	}
+}
+
+func TestCollectorRestart(t *testing.T) {
+	path := socketPath(t)
+	collector := listenDatagram(t, path)
+
+	w := &writer{network: DatagramScheme, path: path, maxMessage: defaultMaxMessage}
+	if err := w.send([][]byte{[]byte("before")}); err != nil {
+		t.Fatal(err)
+	}
+	collector.Close()
+	os.Remove(path)
+
+	if err := w.send([][]byte{[]byte("down")}); err == nil {
+		t.Fatal("no error while the collector is down")
+	}
+
+	collector = listenDatagram(t, path)
+	defer collector.Close()
+	if err := w.send([][]byte{[]byte("after")}); err != nil {
+		t.Fatal(err)
+	}
+	buf := make([]byte, defaultMaxMessage)
+	n, err := collector.Read(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := entries(t, buf[:n]); len(got) != 1 || got[0] != "after" {
+		t.Errorf("got entries %q", got)
+	}
+}
+
+func TestTruncate(t *testing.T) {
+	w := &writer{maxMessage: 16}
+	got := entries(t, w.frame(nil, [][]byte{[]byte(strings.Repeat("x", 20))}))
+	if len(got) != 1 || got[0] != strings.Repeat("x", 12) {
+		t.Errorf("got entries %q", got)
+	}
+}
+
+func TestInvalidURL(t *testing.T) {
+	for _, s := range []string{"unixgram://host/sock", "unixgram://", "unixgram:///sock?max_message=4"} {
+		u, err := url.Parse(s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := newSink(DatagramScheme, u); err == nil {
+			t.Errorf("no error for %s", s)
+		}
+	}
+}
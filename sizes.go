@@ -0,0 +1,106 @@
+package log
+
+import (
+	"sync"
+
+	"go.uber.org/zap/buffer"
+	"go.uber.org/zap/zapcore"
+)
+
+// entrySizeBounds are the upper bounds of the buckets of SizeHistogram, from
+// 64B to 1MiB.
+var entrySizeBounds = func() []int {
+	var bounds []int
+	for b := 64; b <= 1<<20; b *= 2 {
+		bounds = append(bounds, b)
+	}
+	return bounds
+}()
+
+// SizeHistogram counts encoded entries by size, see EntrySizes.
+type SizeHistogram struct {
+	// Bounds are the inclusive upper bounds of the buckets in bytes.
+	Bounds []int
+	// Counts are the number of entries per bucket. The last one, beyond
+	// Bounds, counts the entries larger than all bounds.
+	Counts []uint64
+
+	// Count and Sum are the number and total size of all entries, Max the
+	// size of the largest one.
+	Count uint64
+	Sum   uint64
+	Max   int
+}
+
+func (h *SizeHistogram) observe(size int) {
+	i := 0
+	for i < len(h.Bounds) && size > h.Bounds[i] {
+		i++
+	}
+	h.Counts[i]++
+	h.Count++
+	h.Sum += uint64(size)
+	if size > h.Max {
+		h.Max = size
+	}
+}
+
+// entrySizes records the sizes of entries written with Config.EntrySizes.
+var entrySizes = &sizeRecorder{hists: make(map[string]*SizeHistogram)}
+
+// EntrySizes returns a histogram of the encoded sizes of the entries written
+// so far, per subsystem, i.e. to find subsystems that log huge entries. It
+// is empty unless Config.EntrySizes is set.
+func EntrySizes() map[string]SizeHistogram {
+	return entrySizes.snapshot()
+}
+
+type sizeRecorder struct {
+	mu    sync.Mutex
+	hists map[string]*SizeHistogram
+}
+
+func (r *sizeRecorder) observe(subsystem string, size int) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	h, ok := r.hists[subsystem]
+	if !ok {
+		h = &SizeHistogram{Bounds: entrySizeBounds, Counts: make([]uint64, len(entrySizeBounds)+1)}
+		r.hists[subsystem] = h
+	}
+	h.observe(size)
+}
+
+func (r *sizeRecorder) snapshot() map[string]SizeHistogram {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	hists := make(map[string]SizeHistogram, len(r.hists))
+	for name, h := range r.hists {
+		c := *h
+		c.Counts = append([]uint64(nil), h.Counts...)
+		hists[name] = c
+	}
+	return hists
+}
+
+var _ zapcore.Encoder = (*sizeEncoder)(nil)
+
+// sizeEncoder records the sizes of the entries it encodes.
+type sizeEncoder struct {
+	zapcore.Encoder
+	sizes *sizeRecorder
+}
+
+func (enc *sizeEncoder) Clone() zapcore.Encoder {
+	return &sizeEncoder{Encoder: enc.Encoder.Clone(), sizes: enc.sizes}
+}
+
+func (enc *sizeEncoder) EncodeEntry(ent zapcore.Entry, fields []zapcore.Field) (*buffer.Buffer, error) {
+	buf, err := enc.Encoder.EncodeEntry(ent, fields)
+	if err == nil {
+		enc.sizes.observe(ent.LoggerName, buf.Len())
+	}
+	return buf, err
+}
@@ -0,0 +1,44 @@
+package log
+
+import (
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestSizeHistogram(t *testing.T) {
+	h := &SizeHistogram{Bounds: entrySizeBounds, Counts: make([]uint64, len(entrySizeBounds)+1)}
+	for _, size := range []int{10, 64, 65, 2 << 20} {
+		h.observe(size)
+	}
+	if h.Counts[0] != 2 || h.Counts[1] != 1 || h.Counts[len(h.Counts)-1] != 1 {
+		t.Errorf("got counts %v", h.Counts)
+	}
+	if h.Count != 4 || h.Sum != 10+64+65+2<<20 || h.Max != 2<<20 {
+		t.Errorf("got %+v", h)
+	}
+}
+
+func TestEntrySizes(t *testing.T) {
+	f, err := ioutil.TempFile("", "go-log-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(f.Name())
+
+	SetupLogging(Config{Format: JSONOutput, Level: LevelInfo, File: f.Name(), EntrySizes: true})
+	defer SetupLogging(Config{Stderr: true})
+
+	logger := getLogger("entry-sizes-test")
+	logger.Info("small")
+	logger.Info(strings.Repeat("x", 1000))
+
+	h, ok := EntrySizes()["entry-sizes-test"]
+	if !ok {
+		t.Fatal("no histogram for the subsystem")
+	}
+	if h.Count != 2 || h.Max < 1000 || h.Sum <= uint64(h.Max) {
+		t.Errorf("got %+v", h)
+	}
+}
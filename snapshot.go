@@ -0,0 +1,119 @@
+package log
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// snapshotValue returns a deep copy of a value encoded by a
+// zapcore.MapObjectEncoder, so that the caller can't modify it once logged.
+// Reflected values, i.e. maps and structs, are converted to their JSON
+// representation with map[string]interface{} objects and float64 numbers,
+// as shown in JSON output.
+func snapshotValue(v interface{}) interface{} {
+	switch v := v.(type) {
+	case nil, string, bool, error,
+		int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64, uintptr,
+		float32, float64, complex64, complex128:
+		return v
+	case map[string]interface{}:
+		m := make(map[string]interface{}, len(v))
+		for k, e := range v {
+			m[k] = snapshotValue(e)
+		}
+		return m
+	case []interface{}:
+		s := make([]interface{}, len(v))
+		for i, e := range v {
+			s[i] = snapshotValue(e)
+		}
+		return s
+	case []byte:
+		return append([]byte(nil), v...)
+	case time.Time, time.Duration:
+		return v
+	}
+
+	b, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Sprintf("<unserializable %T: %v>", v, err)
+	}
+	var copied interface{}
+	if err := json.Unmarshal(b, &copied); err != nil {
+		return string(b)
+	}
+	return copied
+}
+
+// cloneValue returns a deep copy of a value returned by snapshotValue.
+func cloneValue(v interface{}) interface{} {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		m := make(map[string]interface{}, len(v))
+		for k, e := range v {
+			m[k] = cloneValue(e)
+		}
+		return m
+	case []interface{}:
+		s := make([]interface{}, len(v))
+		for i, e := range v {
+			s[i] = cloneValue(e)
+		}
+		return s
+	case []byte:
+		return append([]byte(nil), v...)
+	}
+	return v
+}
+
+// clone returns a deep copy of e, so that recipients of the same entry can't
+// see each other's modifications.
+func (e Entry) clone() Entry {
+	if e.Fields != nil {
+		e.Fields = cloneValue(e.Fields).(map[string]interface{})
+	}
+	return e
+}
+
+// snapshotFields returns a copy of fields in which the values referencing
+// memory of the caller, i.e. reflected maps and byte slices, are copied, for
+// fields encoded after the logging call returns. The values of marshalers and
+// stringers are still read when they are encoded.
+func snapshotFields(fields []zapcore.Field) []zapcore.Field {
+	var copied []zapcore.Field
+	for i, f := range fields {
+		var snapshot zapcore.Field
+		switch f.Type {
+		case zapcore.ReflectType:
+			switch f.Interface.(type) {
+			case byteSize, byteRate:
+				// immutable, and rendered with units by humanEncoder.
+				continue
+			}
+			b, err := json.Marshal(f.Interface)
+			if err != nil {
+				// encoded as an error when encoding the entry.
+				continue
+			}
+			snapshot = zap.Reflect(f.Key, json.RawMessage(b))
+		case zapcore.BinaryType:
+			snapshot = zap.Binary(f.Key, append([]byte(nil), f.Interface.([]byte)...))
+		case zapcore.ByteStringType:
+			snapshot = zap.ByteString(f.Key, append([]byte(nil), f.Interface.([]byte)...))
+		default:
+			continue
+		}
+		if copied == nil {
+			copied = append([]zapcore.Field(nil), fields...)
+		}
+		copied[i] = snapshot
+	}
+	if copied == nil {
+		return fields
+	}
+	return copied
+}
@@ -0,0 +1,104 @@
+package log
+
+import (
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestSubscribeSnapshot(t *testing.T) {
+	SetupLogging(Config{Level: LevelInfo})
+	defer SetupLogging(Config{Stderr: true})
+
+	first, cancelFirst, err := Subscribe(Filter{Subsystem: "snapshot-test"}, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cancelFirst()
+	second, cancelSecond, err := Subscribe(Filter{Subsystem: "snapshot-test"}, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cancelSecond()
+
+	metadata := map[string]interface{}{"peer": "p1", "tags": []string{"a"}}
+	getLogger("snapshot-test").Infow("connected", "metadata", metadata, "raw", []byte("x"))
+	// neither the caller nor a subscriber can change what the others see.
+	metadata["peer"] = "p2"
+	e := <-first
+	e.Fields["metadata"].(map[string]interface{})["peer"] = "p3"
+
+	e = <-second
+	m := e.Fields["metadata"].(map[string]interface{})
+	if m["peer"] != "p1" || m["tags"].([]interface{})[0] != "a" {
+		t.Errorf("got metadata %v", m)
+	}
+}
+
+func TestQuerySnapshot(t *testing.T) {
+	SetupLogging(Config{Level: LevelInfo, RingBufferSize: 4})
+	defer SetupLogging(Config{Stderr: true})
+
+	getLogger("snapshot-test").Infow("connected", "metadata", map[string]string{"peer": "p1"})
+	entries := Query(Filter{Subsystem: "snapshot-test"})
+	if len(entries) != 1 {
+		t.Fatalf("got %d entries", len(entries))
+	}
+	entries[0].Fields["metadata"].(map[string]interface{})["peer"] = "p2"
+
+	entries = Query(Filter{Subsystem: "snapshot-test"})
+	if m := entries[0].Fields["metadata"].(map[string]interface{}); m["peer"] != "p1" {
+		t.Errorf("got metadata %v", m)
+	}
+}
+
+func TestEncoderWorkersSnapshot(t *testing.T) {
+	logfile, err := ioutil.TempFile("", "go-log-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(logfile.Name())
+
+	SetupLogging(Config{Format: JSONOutput, File: logfile.Name(), EncoderWorkers: 2})
+	defer SetupLogging(Config{Stderr: true})
+
+	log := getLogger("snapshot-test")
+	metadata := map[string]string{"peer": "p1"}
+	log.Errorw("connected", "metadata", metadata)
+	// encoded in the background, but with the logged value.
+	metadata["peer"] = "p2"
+	if err := log.Sync(); err != nil {
+		t.Fatal(err)
+	}
+
+	content, err := ioutil.ReadFile(logfile.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(content), `"metadata":{"peer":"p1"}`) {
+		t.Errorf("got %q", content)
+	}
+}
+
+func TestSnapshotValue(t *testing.T) {
+	type peer struct {
+		ID    string
+		Addrs []string
+	}
+	v := snapshotValue(map[string]interface{}{
+		"peer":   &peer{ID: "p1", Addrs: []string{"/ip4/1.2.3.4"}},
+		"n":      int64(1),
+		"broken": func() {},
+	}).(map[string]interface{})
+	p, ok := v["peer"].(map[string]interface{})
+	if !ok || p["ID"] != "p1" || p["Addrs"].([]interface{})[0] != "/ip4/1.2.3.4" {
+		t.Errorf("got peer %#v", v["peer"])
+	}
+	if v["n"] != int64(1) {
+		t.Errorf("got n %#v", v["n"])
+	}
+	if s, _ := v["broken"].(string); !strings.HasPrefix(s, "<unserializable func()") {
+		t.Errorf("got broken %#v", v["broken"])
+	}
+}
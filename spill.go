@@ -0,0 +1,187 @@
+package log
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// BlobStore stores field values that are too large to be logged inline.
+type BlobStore interface {
+	// Put stores data and returns a reference to it.
+	Put(data []byte) (ref string, err error)
+}
+
+// SpillConfig configures spilling of large field values. Values of string
+// and byte slice fields larger than Threshold are written to a BlobStore and
+// replaced in the entry by an object holding the reference and the length of
+// the value.
+type SpillConfig struct {
+	// Threshold is the size in bytes above which values are spilled.
+	// Spilling is disabled when Threshold is zero.
+	Threshold int
+
+	// Dir is the directory of a content-addressed store, see
+	// NewDirBlobStore. Ignored when Store is set.
+	Dir string
+
+	// Store is where values are spilled to.
+	Store BlobStore
+}
+
+// NewDirBlobStore returns a BlobStore keeping each value in a file under dir
+// named by the hex encoded SHA-256 hash of its content. References have the
+// form "sha256:<hex>". Identical values are stored once.
+func NewDirBlobStore(dir string) BlobStore {
+	return dirBlobStore(dir)
+}
+
+type dirBlobStore string
+
+func (d dirBlobStore) Put(data []byte) (string, error) {
+	sum := sha256.Sum256(data)
+	name := hex.EncodeToString(sum[:])
+	path := filepath.Join(string(d), name)
+
+	if _, err := os.Stat(path); err == nil {
+		return "sha256:" + name, nil
+	}
+	if err := writeFileAtomic(string(d), name, data); err != nil {
+		return "", err
+	}
+	return "sha256:" + name, nil
+}
+
+// writeFileAtomic writes data to the file name in dir, creating dir if
+// needed. The data is written to a temporary file first, so that readers
+// never see a partial file under its final name.
+func writeFileAtomic(dir, name string, data []byte) error {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return err
+	}
+	tmp, err := ioutil.TempFile(dir, name+".tmp")
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()           // nolint:errcheck
+		os.Remove(tmp.Name()) // nolint:errcheck
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name()) // nolint:errcheck
+		return err
+	}
+	if err := os.Rename(tmp.Name(), filepath.Join(dir, name)); err != nil {
+		os.Remove(tmp.Name()) // nolint:errcheck
+		return err
+	}
+	return nil
+}
+
+// blobRef is logged in place of a spilled value.
+type blobRef struct {
+	ref    string
+	length int
+}
+
+func (b blobRef) MarshalLogObject(enc zapcore.ObjectEncoder) error {
+	enc.AddString("blob", b.ref)
+	enc.AddInt("len", b.length)
+	return nil
+}
+
+var _ zapcore.Core = (*spillCore)(nil)
+
+// spillCore replaces large field values with references to a BlobStore.
+type spillCore struct {
+	next      zapcore.Core
+	store     BlobStore
+	threshold int
+}
+
+func newSpillCore(next zapcore.Core, cfg SpillConfig) *spillCore {
+	store := cfg.Store
+	if store == nil {
+		store = NewDirBlobStore(cfg.Dir)
+	}
+	return &spillCore{next: next, store: store, threshold: cfg.Threshold}
+}
+
+func (s *spillCore) With(fields []zapcore.Field) zapcore.Core {
+	return &spillCore{
+		next:      s.next.With(s.spill(fields)),
+		store:     s.store,
+		threshold: s.threshold,
+	}
+}
+
+func (s *spillCore) Enabled(lvl zapcore.Level) bool {
+	return s.next.Enabled(lvl)
+}
+
+func (s *spillCore) Check(ent zapcore.Entry, ce *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if s.next.Enabled(ent.Level) {
+		return ce.AddCore(ent, s)
+	}
+	return ce
+}
+
+func (s *spillCore) Write(ent zapcore.Entry, fields []zapcore.Field) error {
+	return writeChecked(s.next, ent, s.spill(fields))
+}
+
+func (s *spillCore) Sync() error {
+	return s.next.Sync()
+}
+
+// spill returns fields with large values replaced by references. fields is
+// not modified.
+func (s *spillCore) spill(fields []zapcore.Field) []zapcore.Field {
+	var out []zapcore.Field
+	for i, f := range fields {
+		var data []byte
+		switch f.Type {
+		case zapcore.StringType:
+			if len(f.String) > s.threshold {
+				data = []byte(f.String)
+			}
+		case zapcore.ByteStringType, zapcore.BinaryType:
+			if b, ok := f.Interface.([]byte); ok && len(b) > s.threshold {
+				data = b
+			}
+		}
+		if data == nil {
+			continue
+		}
+
+		ref, err := safePut(s.store, data)
+		if err != nil {
+			// keep the value rather than losing it.
+			continue
+		}
+		if out == nil {
+			out = append([]zapcore.Field(nil), fields...)
+		}
+		out[i] = zap.Object(f.Key, blobRef{ref: ref, length: len(data)})
+	}
+	if out == nil {
+		return fields
+	}
+	return out
+}
+
+// safePut stores data in store, returning a panic of the store as an error.
+func safePut(store BlobStore, data []byte) (ref string, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = panicError(r)
+		}
+	}()
+	return store.Put(data)
+}
@@ -0,0 +1,80 @@
+package log
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+func TestSpillCore(t *testing.T) {
+	dir, err := ioutil.TempDir("", "go-log-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	buf := &bytes.Buffer{}
+	core := newSpillCore(
+		newCore(JSONOutput, zapcore.AddSync(buf), LevelDebug, nil),
+		SpillConfig{Threshold: 16, Dir: dir},
+	)
+	large := strings.Repeat("x", 100)
+	logger := zap.New(core).With(zap.Binary("payload", []byte(large)))
+	logger.Info("spilled", zap.String("small", "value"), zap.String("large", large))
+
+	var entry struct {
+		Small   string
+		Large   map[string]interface{}
+		Payload map[string]interface{}
+	}
+	if err := json.Unmarshal(buf.Bytes(), &entry); err != nil {
+		t.Fatal(err)
+	}
+	if entry.Small != "value" {
+		t.Errorf("got small %q, want %q", entry.Small, "value")
+	}
+	for _, ref := range []map[string]interface{}{entry.Large, entry.Payload} {
+		if ref["len"] != float64(len(large)) {
+			t.Errorf("got len %v, want %d", ref["len"], len(large))
+		}
+		blob, _ := ref["blob"].(string)
+		if !strings.HasPrefix(blob, "sha256:") {
+			t.Fatalf("got blob reference %q", blob)
+		}
+		data, err := ioutil.ReadFile(filepath.Join(dir, strings.TrimPrefix(blob, "sha256:")))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(data) != large {
+			t.Errorf("got blob %q, want %q", data, large)
+		}
+	}
+
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) != 1 {
+		t.Errorf("got %d blobs, want identical values stored once", len(files))
+	}
+}
+
+func TestSpillCoreWriteError(t *testing.T) {
+	dir, err := ioutil.TempDir("", "go-log-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	core := newSpillCore(newCore(JSONOutput, failingWriteSyncer{}, LevelDebug, nil), SpillConfig{Threshold: 16, Dir: dir})
+	if err := core.Write(zapcore.Entry{Level: zapcore.InfoLevel}, []zapcore.Field{zap.String("large", strings.Repeat("x", 100))}); err == nil {
+		t.Error("expected the write error of the output")
+	}
+}
@@ -0,0 +1,133 @@
+package log
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"go.uber.org/multierr"
+)
+
+// spoolSuffix is the suffix of the files of spooled batches.
+const spoolSuffix = ".batch"
+
+// errCorruptSpool is returned for a spooled batch that can't be decoded.
+var errCorruptSpool = errors.New("corrupt spooled log batch")
+
+// batchSpool keeps the batches of a BatchSink that could not be sent in the
+// files of a directory, one per batch and named by a sequence number, see
+// BatchConfig.SpoolDir. It is only used by the loop of the sink.
+type batchSpool struct {
+	dir   string
+	max   int64
+	files []spoolFile // oldest first
+	size  int64       // bytes in files
+	next  uint64      // sequence number of the next file
+}
+
+type spoolFile struct {
+	name string
+	size int64
+}
+
+// openBatchSpool opens the spool in dir, picking up the batches spooled by
+// earlier processes.
+func openBatchSpool(dir string, max int64) (*batchSpool, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	infos, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	s := &batchSpool{dir: dir, max: max}
+	for _, info := range infos {
+		seq, err := strconv.ParseUint(strings.TrimSuffix(info.Name(), spoolSuffix), 10, 64)
+		if err != nil || !info.Mode().IsRegular() || !strings.HasSuffix(info.Name(), spoolSuffix) {
+			continue
+		}
+		s.files = append(s.files, spoolFile{name: info.Name(), size: info.Size()})
+		s.size += info.Size()
+		if seq >= s.next {
+			s.next = seq + 1
+		}
+	}
+	// the names are zero-padded, so that they sort by sequence number.
+	sort.Slice(s.files, func(i, j int) bool { return s.files[i].name < s.files[j].name })
+	return s, nil
+}
+
+func (s *batchSpool) empty() bool {
+	return len(s.files) == 0
+}
+
+// push spools a batch, and removes the oldest batches when the spool grows
+// beyond its maximum size. It returns the number of bytes removed.
+func (s *batchSpool) push(batch [][]byte) (int64, error) {
+	var data []byte
+	var n [binary.MaxVarintLen64]byte
+	for _, entry := range batch {
+		data = append(data, n[:binary.PutUvarint(n[:], uint64(len(entry)))]...)
+		data = append(data, entry...)
+	}
+	name := fmt.Sprintf("%020d%s", s.next, spoolSuffix)
+	if err := writeFileAtomic(s.dir, name, data); err != nil {
+		return 0, err
+	}
+	s.next++
+	s.files = append(s.files, spoolFile{name: name, size: int64(len(data))})
+	s.size += int64(len(data))
+
+	var removed int64
+	var err error
+	for s.size > s.max {
+		removed += s.files[0].size
+		err = multierr.Append(err, s.pop())
+	}
+	return removed, err
+}
+
+// peek returns the oldest batch. A batch that can't be read is removed.
+func (s *batchSpool) peek() ([][]byte, error) {
+	data, err := ioutil.ReadFile(filepath.Join(s.dir, s.files[0].name))
+	if err == nil {
+		var batch [][]byte
+		if batch, err = decodeSpooledBatch(data); err == nil {
+			return batch, nil
+		}
+	}
+	name := s.files[0].name
+	return nil, multierr.Append(fmt.Errorf("dropped spooled log batch %s: %w", name, err), s.pop())
+}
+
+// pop removes the oldest batch. It is forgotten even if its file can't be
+// removed, in which case it is picked up again by the next process.
+func (s *batchSpool) pop() error {
+	f := s.files[0]
+	s.files = s.files[1:]
+	s.size -= f.size
+	if err := os.Remove(filepath.Join(s.dir, f.name)); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+func decodeSpooledBatch(data []byte) ([][]byte, error) {
+	var batch [][]byte
+	for len(data) > 0 {
+		n, k := binary.Uvarint(data)
+		if k <= 0 || n > uint64(len(data)-k) {
+			return nil, errCorruptSpool
+		}
+		data = data[k:]
+		batch = append(batch, data[:n:n])
+		data = data[n:]
+	}
+	return batch, nil
+}
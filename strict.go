@@ -0,0 +1,124 @@
+package log
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"sync"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+var _ zapcore.Core = (*strictFieldCore)(nil)
+
+// strictFieldCore replaces field values that can't be encoded as JSON, i.e.
+// channels, funcs and NaN, with an error field in the style of zap, see
+// errorField. Every key is reported once per subsystem, in a warning written
+// to the next core before the entry that has it. Entries of this package's
+// own "log" logger are not checked.
+type strictFieldCore struct {
+	next   zapcore.Core
+	bad    []badField // fields added with With that were not reported yet
+	warned *sync.Map
+}
+
+// badField is a field whose value can't be encoded as JSON.
+type badField struct {
+	key string
+	err error
+}
+
+func newStrictFieldCore(next zapcore.Core) *strictFieldCore {
+	return &strictFieldCore{next: next, warned: &sync.Map{}}
+}
+
+func (c *strictFieldCore) With(fields []zapcore.Field) zapcore.Core {
+	fields, bad := checkFields(fields, c.bad[:len(c.bad):len(c.bad)])
+	return &strictFieldCore{next: c.next.With(fields), bad: bad, warned: c.warned}
+}
+
+func (c *strictFieldCore) Enabled(lvl zapcore.Level) bool {
+	return c.next.Enabled(lvl)
+}
+
+func (c *strictFieldCore) Check(ent zapcore.Entry, ce *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if c.next.Enabled(ent.Level) {
+		return ce.AddCore(ent, c)
+	}
+	return ce
+}
+
+func (c *strictFieldCore) Write(ent zapcore.Entry, fields []zapcore.Field) error {
+	if ent.LoggerName != "log" {
+		var bad []badField
+		fields, bad = checkFields(fields, c.bad[:len(c.bad):len(c.bad)])
+		for _, f := range bad {
+			if _, warned := c.warned.LoadOrStore(ent.LoggerName+"\x00"+f.key, true); warned {
+				continue
+			}
+			warning := zapcore.Entry{
+				Level:      zapcore.WarnLevel,
+				Time:       ent.Time,
+				LoggerName: ent.LoggerName,
+				Caller:     ent.Caller,
+				Message:    "field value can't be encoded as JSON",
+			}
+			if ce := c.next.Check(warning, nil); ce != nil {
+				ce.Write(zap.String("key", f.key), zap.Error(f.err))
+			}
+		}
+	}
+	return writeChecked(c.next, ent, fields)
+}
+
+func (c *strictFieldCore) Sync() error {
+	return c.next.Sync()
+}
+
+// checkFields returns fields with the values that can't be encoded as JSON
+// replaced by error fields, and appends them to bad. fields is copied before
+// it is modified.
+func checkFields(fields []zapcore.Field, bad []badField) ([]zapcore.Field, []badField) {
+	var checked []zapcore.Field
+	for i, f := range fields {
+		err := checkValue(f)
+		if err == nil {
+			continue
+		}
+		if checked == nil {
+			checked = append([]zapcore.Field(nil), fields...)
+		}
+		checked[i] = errorField(f.Key, err)
+		bad = append(bad, badField{key: f.Key, err: err})
+	}
+	if checked == nil {
+		return fields, bad
+	}
+	return checked, bad
+}
+
+// checkValue returns the error encoding the value of f as JSON, or nil.
+// Reflected values are marshaled to find out, which is slow.
+func checkValue(f zapcore.Field) error {
+	switch f.Type {
+	case zapcore.Float64Type:
+		return checkFloat(math.Float64frombits(uint64(f.Integer)))
+	case zapcore.Float32Type:
+		return checkFloat(float64(math.Float32frombits(uint32(f.Integer))))
+	case zapcore.ReflectType:
+		if _, err := json.Marshal(f.Interface); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// checkFloat returns an error for the floats JSON has no numbers for, which
+// zap encodes as strings.
+func checkFloat(v float64) error {
+	if math.IsNaN(v) || math.IsInf(v, 0) {
+		return fmt.Errorf("unsupported value: %v", v)
+	}
+	return nil
+}
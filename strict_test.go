@@ -0,0 +1,78 @@
+package log
+
+import (
+	"bytes"
+	"encoding/json"
+	"math"
+	"strings"
+	"testing"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+func TestStrictFields(t *testing.T) {
+	buf := &bytes.Buffer{}
+	core := newStrictFieldCore(newCore(JSONOutput, zapcore.AddSync(buf), LevelDebug, nil))
+	logger := zap.New(core).Sugar().Named("strict-test").With("done", make(chan struct{}))
+
+	logger.Infow("first", "peer", "p", "ratio", math.NaN(), "callback", func() {})
+	logger.Infow("second", "ratio", math.Inf(1))
+
+	var warned []string
+	var entries []map[string]interface{}
+	for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+		var entry map[string]interface{}
+		if err := json.Unmarshal([]byte(line), &entry); err != nil {
+			t.Fatalf("invalid JSON %q: %s", line, err)
+		}
+		if entry["msg"] == "field value can't be encoded as JSON" {
+			warned = append(warned, entry["key"].(string))
+			continue
+		}
+		entries = append(entries, entry)
+	}
+	if want := []string{"done", "ratio", "callback"}; strings.Join(warned, ",") != strings.Join(want, ",") {
+		t.Errorf("got warnings for %v, want %v", warned, want)
+	}
+	if len(entries) != 2 {
+		t.Fatalf("got %d entries", len(entries))
+	}
+	first := entries[0]
+	if first["peer"] != "p" {
+		t.Errorf("got peer %v", first["peer"])
+	}
+	for _, key := range []string{"done", "ratio", "callback"} {
+		if _, ok := first[key]; ok {
+			t.Errorf("%s was encoded", key)
+		}
+		if _, ok := first[key+"Error"].(string); !ok {
+			t.Errorf("got no error for %s", key)
+		}
+	}
+	if _, ok := entries[1]["ratioError"].(string); !ok {
+		t.Errorf("got no error for an infinite ratio: %v", entries[1])
+	}
+}
+
+func TestCheckValue(t *testing.T) {
+	for _, f := range []zapcore.Field{
+		zap.Float64("nan", math.NaN()),
+		zap.Float32("inf", float32(math.Inf(-1))),
+		zap.Reflect("chan", make(chan int)),
+		zap.Any("nested", map[string]interface{}{"f": func() {}}),
+	} {
+		if checkValue(f) == nil {
+			t.Errorf("%s passed the check", f.Key)
+		}
+	}
+	for _, f := range []zapcore.Field{
+		zap.Float64("pi", math.Pi),
+		zap.Any("map", map[string]int{"a": 1}),
+		zap.String("s", "v"),
+	} {
+		if err := checkValue(f); err != nil {
+			t.Errorf("%s failed the check: %s", f.Key, err)
+		}
+	}
+}
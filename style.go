@@ -0,0 +1,147 @@
+package log
+
+import (
+	"time"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/buffer"
+	"go.uber.org/zap/zapcore"
+)
+
+// ConsolePart is a part of the entry header in human-readable output.
+type ConsolePart int
+
+// The parts of the entry header, see ConsoleStyle.Order.
+const (
+	ConsoleTime ConsolePart = iota
+	ConsoleLevel
+	ConsoleLogger
+	ConsoleCaller
+)
+
+// ConsoleStyle customizes human-readable (colorized and plaintext) output,
+// i.e. to match the styling of a dashboard the output is embedded into.
+type ConsoleStyle struct {
+	// LevelLabels replace the names of levels, i.e. {LevelWarn: "WRN"}.
+	// Levels without a label keep their upper-case name.
+	LevelLabels map[LogLevel]string
+
+	// LevelColors are the ANSI SGR parameters levels are colored with in
+	// colorized output, i.e. {LevelWarn: "1;33"} for bold yellow. Levels
+	// without a color keep their default one.
+	LevelColors map[LogLevel]string
+
+	// Order is the order of the parts of the header that precedes the
+	// message. Parts that are left out are not written. Defaults to time,
+	// level, logger and caller.
+	Order []ConsolePart
+}
+
+var defaultConsoleOrder = []ConsolePart{ConsoleTime, ConsoleLevel, ConsoleLogger, ConsoleCaller}
+
+// defaultLevelColors are the colors of zapcore.CapitalColorLevelEncoder.
+var defaultLevelColors = map[LogLevel]string{
+	LevelDebug:  "35",
+	LevelInfo:   "34",
+	LevelWarn:   "33",
+	LevelError:  "31",
+	LevelDPanic: "31",
+	LevelPanic:  "31",
+	LevelFatal:  "31",
+}
+
+var styleBufferPool = buffer.NewPool()
+
+var _ zapcore.Encoder = (*styledEncoder)(nil)
+
+// styledEncoder writes the entry header as configured by a ConsoleStyle,
+// followed by the message, fields and stack trace as encoded by the
+// embedded encoder.
+type styledEncoder struct {
+	zapcore.Encoder
+	style *ConsoleStyle
+	color bool
+	loc   *time.Location
+}
+
+// newStyledEncoder builds an encoder for human-readable output in the given
+// style. Everything else is as in newEncoder.
+func newStyledEncoder(format LogFormat, loc *time.Location, style *ConsoleStyle) zapcore.Encoder {
+	if loc == nil {
+		loc = time.UTC
+	}
+
+	encCfg := zap.NewProductionEncoderConfig()
+	encCfg.TimeKey = zapcore.OmitKey
+	encCfg.LevelKey = zapcore.OmitKey
+	encCfg.NameKey = zapcore.OmitKey
+	encCfg.CallerKey = zapcore.OmitKey
+	encCfg.EncodeDuration = zapcore.StringDurationEncoder
+	return &styledEncoder{
+		Encoder: &safeEncoder{&humanEncoder{zapcore.NewConsoleEncoder(encCfg)}},
+		style:   style,
+		color:   format != PlaintextOutput,
+		loc:     loc,
+	}
+}
+
+func (enc *styledEncoder) Clone() zapcore.Encoder {
+	return &styledEncoder{Encoder: enc.Encoder.Clone(), style: enc.style, color: enc.color, loc: enc.loc}
+}
+
+func (enc *styledEncoder) EncodeEntry(ent zapcore.Entry, fields []zapcore.Field) (*buffer.Buffer, error) {
+	body, err := enc.Encoder.EncodeEntry(ent, fields)
+	if err != nil {
+		return nil, err
+	}
+	defer body.Free()
+
+	order := enc.style.Order
+	if order == nil {
+		order = defaultConsoleOrder
+	}
+	line := styleBufferPool.Get()
+	for _, part := range order {
+		switch part {
+		case ConsoleTime:
+			line.AppendTime(ent.Time.In(enc.loc), "2006-01-02T15:04:05.000Z0700")
+		case ConsoleLevel:
+			enc.appendLevel(line, LogLevel(ent.Level))
+		case ConsoleLogger:
+			if ent.LoggerName == "" {
+				continue
+			}
+			line.AppendString(ent.LoggerName)
+		case ConsoleCaller:
+			if !ent.Caller.Defined {
+				continue
+			}
+			line.AppendString(ent.Caller.TrimmedPath())
+		default:
+			continue
+		}
+		line.AppendByte('\t')
+	}
+	line.Write(body.Bytes())
+	return line, nil
+}
+
+func (enc *styledEncoder) appendLevel(line *buffer.Buffer, lvl LogLevel) {
+	label, ok := enc.style.LevelLabels[lvl]
+	if !ok {
+		label = zapcore.Level(lvl).CapitalString()
+	}
+	if !enc.color {
+		line.AppendString(label)
+		return
+	}
+	color, ok := enc.style.LevelColors[lvl]
+	if !ok {
+		color, ok = defaultLevelColors[lvl]
+	}
+	if !ok {
+		line.AppendString(label)
+		return
+	}
+	line.AppendString("\x1b[" + color + "m" + label + "\x1b[0m")
+}
@@ -0,0 +1,53 @@
+package log
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+func TestStyledEncoder(t *testing.T) {
+	style := &ConsoleStyle{
+		LevelLabels: map[LogLevel]string{LevelWarn: "WRN"},
+		LevelColors: map[LogLevel]string{LevelWarn: "1;33"},
+		Order:       []ConsolePart{ConsoleLevel, ConsoleLogger, ConsoleTime},
+	}
+	ent := zapcore.Entry{
+		Level:      zapcore.WarnLevel,
+		Time:       time.Date(2010, 5, 23, 15, 14, 0, 0, time.UTC),
+		LoggerName: "style-test",
+		Message:    "styled",
+	}
+
+	for _, tc := range []struct {
+		format LogFormat
+		level  zapcore.Level
+		want   string
+	}{
+		{PlaintextOutput, zapcore.WarnLevel, "WRN\tstyle-test\t2010-05-23T15:14:00.000Z\tstyled\t{\"size\": \"1 KiB\"}\n"},
+		{ColorizedOutput, zapcore.WarnLevel, "\x1b[1;33mWRN\x1b[0m\tstyle-test\t2010-05-23T15:14:00.000Z\tstyled\t{\"size\": \"1 KiB\"}\n"},
+		{ColorizedOutput, zapcore.InfoLevel, "\x1b[34mINFO\x1b[0m\tstyle-test\t2010-05-23T15:14:00.000Z\tstyled\t{\"size\": \"1 KiB\"}\n"},
+	} {
+		ent.Level = tc.level
+		buf, err := newStyledEncoder(tc.format, nil, style).EncodeEntry(ent, []zapcore.Field{Bytes("size", 1024)})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := buf.String(); got != tc.want {
+			t.Errorf("got %q, want %q", got, tc.want)
+		}
+	}
+}
+
+func TestStyledEncoderWith(t *testing.T) {
+	style := &ConsoleStyle{Order: []ConsolePart{ConsoleLevel}}
+	buf := &bytes.Buffer{}
+	core := zapcore.NewCore(newStyledEncoder(PlaintextOutput, nil, style), zapcore.AddSync(buf), zapcore.DebugLevel)
+	zap.New(core).With(zap.String("peer", "p")).Info("entry")
+	if got, want := buf.String(), "INFO\tentry\t{\"peer\": \"p\"}\n"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,144 @@
+package log
+
+import (
+	"sync"
+	"sync/atomic"
+
+	"go.uber.org/zap/zapcore"
+)
+
+// subscriptions delivers entries to subscribers. It is part of loggerCore
+// from the start, so that it sees the entries of all loggers, including
+// those derived with With before the first subscription.
+var subscriptions = &subscriptionCore{set: &subscriberSet{}}
+
+// Subscribe delivers the entries that match filter to the returned channel,
+// as Go values, i.e. to show the latest errors in a UI. The channel buffers
+// up to size entries; entries that don't fit are dropped rather than
+// blocking the logger. Subscribers see entries enabled by the level of
+// their subsystem, whatever the outputs and rules.
+//
+// The returned function cancels the subscription and closes the channel.
+func Subscribe(filter Filter, size int) (<-chan Entry, func(), error) {
+	m, err := newEntryMatcher(filter)
+	if err != nil {
+		return nil, nil, err
+	}
+	sub := &subscriber{match: m, entries: make(chan Entry, size)}
+	subscriptions.set.add(sub)
+
+	var once sync.Once
+	cancel := func() {
+		once.Do(func() {
+			subscriptions.set.remove(sub)
+			sub.close()
+		})
+	}
+	return sub.entries, cancel, nil
+}
+
+type subscriber struct {
+	match *entryMatcher
+
+	mu      sync.Mutex // guards sends on entries and closed
+	entries chan Entry
+	closed  bool
+}
+
+// send delivers e unless the subscriber's buffer is full.
+func (s *subscriber) send(e Entry) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.closed {
+		return
+	}
+	select {
+	case s.entries <- e:
+	default:
+	}
+}
+
+func (s *subscriber) close() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.closed = true
+	close(s.entries)
+}
+
+// subscriberSet is a copy-on-write set of subscribers.
+type subscriberSet struct {
+	mu   sync.Mutex   // serializes updates of subs
+	subs atomic.Value // []*subscriber, never modified once stored
+}
+
+func (s *subscriberSet) load() []*subscriber {
+	subs, _ := s.subs.Load().([]*subscriber)
+	return subs
+}
+
+func (s *subscriberSet) add(sub *subscriber) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	subs := s.load()
+	s.subs.Store(append(subs[:len(subs):len(subs)], sub))
+}
+
+func (s *subscriberSet) remove(sub *subscriber) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var subs []*subscriber
+	for _, other := range s.load() {
+		if other != sub {
+			subs = append(subs, other)
+		}
+	}
+	s.subs.Store(subs)
+}
+
+var _ zapcore.Core = (*subscriptionCore)(nil)
+
+// subscriptionCore converts entries for subscribers.
+type subscriptionCore struct {
+	set    *subscriberSet
+	fields []zapcore.Field // accumulated by With
+}
+
+func (c *subscriptionCore) With(fields []zapcore.Field) zapcore.Core {
+	return &subscriptionCore{
+		set:    c.set,
+		fields: append(c.fields[:len(c.fields):len(c.fields)], fields...),
+	}
+}
+
+func (c *subscriptionCore) Enabled(zapcore.Level) bool {
+	return len(c.set.load()) > 0
+}
+
+func (c *subscriptionCore) Check(ent zapcore.Entry, ce *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if len(c.set.load()) > 0 {
+		return ce.AddCore(ent, c)
+	}
+	return ce
+}
+
+func (c *subscriptionCore) Write(ent zapcore.Entry, fields []zapcore.Field) error {
+	subs := c.set.load()
+	if len(subs) == 0 {
+		return nil
+	}
+	e := newEntry(ent, c.fields, fields)
+	for _, sub := range subs {
+		if sub.match.match(&e) {
+			sub.send(e.clone())
+		}
+	}
+	return nil
+}
+
+func (c *subscriptionCore) Sync() error {
+	return nil
+}
@@ -0,0 +1,43 @@
+package log
+
+import "testing"
+
+func TestSubscribe(t *testing.T) {
+	SetupLogging(Config{Level: LevelInfo})
+	defer SetupLogging(Config{Stderr: true})
+
+	logger := getLogger("subscribe-test").With("peer", "p")
+
+	entries, cancel, err := Subscribe(Filter{Level: "warn", Subsystem: "subscribe-test"}, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	logger.Info("too low")
+	getLogger("subscribe-other-test").Error("other subsystem")
+	logger.Named("child").Warnw("first", "n", 1)
+	logger.Error("second")
+	// the buffer is full.
+	logger.Error("dropped")
+
+	e := <-entries
+	if e.Message != "first" || e.Subsystem != "subscribe-test.child" || e.Level != LevelWarn {
+		t.Errorf("got entry %+v", e)
+	}
+	if e.Fields["peer"] != "p" || e.Fields["n"] != int64(1) {
+		t.Errorf("got fields %v", e.Fields)
+	}
+	if e := <-entries; e.Message != "second" {
+		t.Errorf("got entry %+v, want the second one", e)
+	}
+
+	cancel()
+	cancel()
+	logger.Error("after cancel")
+	if e, ok := <-entries; ok {
+		t.Errorf("got entry %+v after cancel", e)
+	}
+
+	if _, _, err := Subscribe(Filter{Level: "loud"}, 1); err == nil {
+		t.Error("expected an error for an invalid level")
+	}
+}
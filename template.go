@@ -0,0 +1,185 @@
+package log
+
+import (
+	"fmt"
+	"strconv"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/buffer"
+	"go.uber.org/zap/zapcore"
+)
+
+// TemplateKey is the field key of the template of an entry logged with a
+// Template, which stays the same when the arguments differ and can be used
+// to aggregate entries.
+const TemplateKey = "template"
+
+// templatePool provides the buffers messages are formatted into.
+var templatePool = buffer.NewPool()
+
+// templateVerbs are the verbs supported by templates.
+const templateVerbs = "sdvq"
+
+// Template is a precompiled message format, see NewTemplate.
+type Template struct {
+	raw   string
+	lits  []string // literal text before each verb, and after the last one
+	verbs []byte
+}
+
+// NewTemplate compiles format, which may use the verbs %s, %d, %v and %q
+// and %% for a literal percent sign, without flags, widths or precisions.
+// Entries are logged with Emit, which formats the message without parsing
+// format again and only if the entry is enabled. It panics if format is
+// invalid, as templates are meant to be package level variables, i.e.
+//
+//	var dialFailed = log.NewTemplate("dial to %s failed after %d attempts")
+func NewTemplate(format string) *Template {
+	t := &Template{raw: format}
+	var lit []byte
+	for i := 0; i < len(format); i++ {
+		if format[i] != '%' {
+			lit = append(lit, format[i])
+			continue
+		}
+		i++
+		if i == len(format) {
+			panic(fmt.Sprintf("log: template %q ends with %%", format))
+		}
+		verb := format[i]
+		if verb == '%' {
+			lit = append(lit, '%')
+			continue
+		}
+		if !containsByte(templateVerbs, verb) {
+			panic(fmt.Sprintf("log: template %q has unsupported verb %%%c", format, verb))
+		}
+		t.lits = append(t.lits, string(lit))
+		t.verbs = append(t.verbs, verb)
+		lit = lit[:0]
+	}
+	t.lits = append(t.lits, string(lit))
+	return t
+}
+
+func containsByte(s string, c byte) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] == c {
+			return true
+		}
+	}
+	return false
+}
+
+// String returns the format of the template.
+func (t *Template) String() string {
+	return t.raw
+}
+
+type templateArgKind int
+
+const (
+	stringArg templateArgKind = iota
+	intArg
+	uintArg
+	anyArg
+)
+
+// TemplateArg is an argument of a Template, see StringArg, IntArg, UintArg
+// and AnyArg.
+type TemplateArg struct {
+	kind templateArgKind
+	str  string
+	num  uint64
+	val  interface{}
+}
+
+// StringArg is a string argument, for %s, %q and %v.
+func StringArg(s string) TemplateArg {
+	return TemplateArg{kind: stringArg, str: s}
+}
+
+// IntArg is an integer argument, for %d and %v.
+func IntArg(i int64) TemplateArg {
+	return TemplateArg{kind: intArg, num: uint64(i)}
+}
+
+// UintArg is an unsigned integer argument, for %d and %v.
+func UintArg(u uint64) TemplateArg {
+	return TemplateArg{kind: uintArg, num: u}
+}
+
+// AnyArg is an argument of any type, formatted by fmt. It is meant for
+// values without a typed argument, i.e. errors and Stringers.
+func AnyArg(v interface{}) TemplateArg {
+	return TemplateArg{kind: anyArg, val: v}
+}
+
+// Emit logs an entry at level with the message formatted from args, and the
+// template in the TemplateKey field. Arguments that don't match their verb
+// or are missing are marked in the message like fmt does, i.e.
+// "%!d(string=x)".
+func (t *Template) Emit(l *ZapEventLogger, level LogLevel, args ...TemplateArg) {
+	logger := l.skipLogger.Desugar()
+	if !logger.Core().Enabled(zapcore.Level(level)) {
+		return
+	}
+	if ce := logger.Check(zapcore.Level(level), t.format(args)); ce != nil {
+		ce.Write(zap.String(TemplateKey, t.raw))
+	}
+}
+
+// format formats the message.
+func (t *Template) format(args []TemplateArg) string {
+	buf := templatePool.Get()
+	defer buf.Free()
+
+	for i, verb := range t.verbs {
+		buf.AppendString(t.lits[i])
+		if i >= len(args) {
+			buf.AppendString("%!")
+			buf.AppendByte(verb)
+			buf.AppendString("(MISSING)")
+			continue
+		}
+		appendTemplateArg(buf, verb, args[i])
+	}
+	buf.AppendString(t.lits[len(t.verbs)])
+	for i := len(t.verbs); i < len(args); i++ {
+		buf.AppendString("%!(EXTRA ")
+		appendTemplateArg(buf, 'v', args[i])
+		buf.AppendByte(')')
+	}
+	return buf.String()
+}
+
+// appendTemplateArg appends arg formatted with verb.
+func appendTemplateArg(buf *buffer.Buffer, verb byte, arg TemplateArg) {
+	switch {
+	case arg.kind == stringArg && (verb == 's' || verb == 'v'):
+		buf.AppendString(arg.str)
+	case arg.kind == stringArg && verb == 'q':
+		buf.AppendString(strconv.Quote(arg.str))
+	case arg.kind == intArg && (verb == 'd' || verb == 'v'):
+		buf.AppendInt(int64(arg.num))
+	case arg.kind == uintArg && (verb == 'd' || verb == 'v'):
+		buf.AppendUint(arg.num)
+	case arg.kind == anyArg:
+		fmt.Fprintf(buf, "%"+string(verb), arg.val)
+	default:
+		fmt.Fprintf(buf, "%%!%c(%s)", verb, arg.value())
+	}
+}
+
+// value returns the argument formatted like fmt does for bad verbs, i.e.
+// "string=x".
+func (a TemplateArg) value() string {
+	switch a.kind {
+	case stringArg:
+		return "string=" + a.str
+	case intArg:
+		return "int64=" + strconv.FormatInt(int64(a.num), 10)
+	default:
+		return "uint64=" + strconv.FormatUint(a.num, 10)
+	}
+}
@@ -0,0 +1,68 @@
+package log
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+
+	"go.uber.org/zap/zapcore"
+)
+
+func TestTemplateFormat(t *testing.T) {
+	testCases := []struct {
+		format string
+		args   []TemplateArg
+		want   string
+	}{
+		{"dial to %s failed after %d attempts", []TemplateArg{StringArg("peer"), IntArg(-3)}, "dial to peer failed after -3 attempts"},
+		{"%q is %v%%", []TemplateArg{StringArg("disk"), UintArg(90)}, `"disk" is 90%`},
+		{"failed: %v", []TemplateArg{AnyArg(errors.New("boom"))}, "failed: boom"},
+		{"%d peers", []TemplateArg{StringArg("x")}, "%!d(string=x) peers"},
+		{"%s and %s", []TemplateArg{StringArg("a")}, "a and %!s(MISSING)"},
+		{"done", []TemplateArg{IntArg(1)}, "done%!(EXTRA 1)"},
+	}
+	for _, tc := range testCases {
+		if got := NewTemplate(tc.format).format(tc.args); got != tc.want {
+			t.Errorf("%q: got %q, want %q", tc.format, got, tc.want)
+		}
+	}
+}
+
+func TestTemplateInvalid(t *testing.T) {
+	for _, format := range []string{"%x", "%5d", "trailing %"} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("%q: no panic", format)
+				}
+			}()
+			NewTemplate(format)
+		}()
+	}
+}
+
+func TestTemplateEmit(t *testing.T) {
+	defer SetupLogging(Config{Stderr: true})
+
+	buf := &bytes.Buffer{}
+	SetupLogging(Config{Level: LevelInfo})
+	SetPrimaryCore(newCore(JSONOutput, zapcore.AddSync(buf), LevelDebug, nil))
+
+	dialFailed := NewTemplate("dial to %s failed after %d attempts")
+	logger := Logger("template-test")
+	dialFailed.Emit(logger, LevelDebug, StringArg("peer"), IntArg(3))
+	dialFailed.Emit(logger, LevelWarn, StringArg("peer"), IntArg(3))
+
+	var entry map[string]interface{}
+	if err := json.Unmarshal(buf.Bytes(), &entry); err != nil {
+		t.Fatalf("%s: %q", err, buf.String())
+	}
+	if entry["msg"] != "dial to peer failed after 3 attempts" || entry[TemplateKey] != dialFailed.String() {
+		t.Errorf("got entry %v", entry)
+	}
+	if caller, _ := entry["caller"].(string); !strings.Contains(caller, "template_test.go") {
+		t.Errorf("got caller %q", caller)
+	}
+}
@@ -0,0 +1,41 @@
+package log
+
+import "context"
+
+// TenantKey is the field key of the tenant an entry belongs to. Rules can
+// match it to route or drop the entries of a tenant, i.e.
+// Rule{Fields: map[string]string{TenantKey: "acme"}, Output: "/var/log/acme.log"}.
+const TenantKey = "tenant"
+
+type tenantKey struct{}
+
+// ContextWithTenant returns a copy of ctx bound to tenant, see
+// WithTenantFromContext.
+func ContextWithTenant(ctx context.Context, tenant string) context.Context {
+	return context.WithValue(ctx, tenantKey{}, tenant)
+}
+
+// TenantFromContext returns the tenant ctx is bound to.
+func TenantFromContext(ctx context.Context) (string, bool) {
+	tenant, ok := ctx.Value(tenantKey{}).(string)
+	return tenant, ok
+}
+
+// WithTenant returns a new logger that adds tenant to all entries, in the
+// TenantKey field.
+func WithTenant(l *ZapEventLogger, tenant string) *ZapEventLogger {
+	copyLogger := *l
+	copyLogger.SugaredLogger = *copyLogger.SugaredLogger.With(TenantKey, tenant)
+	copyLogger.skipLogger = *copyLogger.skipLogger.With(TenantKey, tenant)
+	return &copyLogger
+}
+
+// WithTenantFromContext returns a new logger that adds the tenant ctx is
+// bound to to all entries, or l if ctx is not bound to a tenant.
+func WithTenantFromContext(l *ZapEventLogger, ctx context.Context) *ZapEventLogger {
+	tenant, ok := TenantFromContext(ctx)
+	if !ok {
+		return l
+	}
+	return WithTenant(l, tenant)
+}
@@ -0,0 +1,58 @@
+package log
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestTenantRouting(t *testing.T) {
+	primary, err := ioutil.TempFile("", "go-log-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(primary.Name())
+	acme, err := ioutil.TempFile("", "go-log-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(acme.Name())
+
+	SetupLogging(Config{
+		Format: PlaintextOutput,
+		Level:  LevelInfo,
+		File:   primary.Name(),
+		Rules: []Rule{{
+			Fields: map[string]string{TenantKey: "acme"},
+			Action: RouteAction,
+			Output: acme.Name(),
+		}},
+	})
+	defer SetupLogging(Config{Stderr: true})
+
+	log := Logger("tenant-test")
+	ctx := ContextWithTenant(context.Background(), "acme")
+	if tenant, ok := TenantFromContext(ctx); !ok || tenant != "acme" {
+		t.Fatalf("got tenant %q", tenant)
+	}
+	WithTenantFromContext(log, ctx).Warn("for acme")
+	WithTenant(log, "other").Warn("for other")
+	WithTenantFromContext(log, context.Background()).Warn("for nobody")
+
+	content, err := ioutil.ReadFile(acme.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := string(content); !strings.Contains(got, `{"tenant": "acme"}`) || strings.Contains(got, "other") {
+		t.Errorf("acme output got %q", got)
+	}
+	content, err = ioutil.ReadFile(primary.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := string(content); !strings.Contains(got, "for other") || !strings.Contains(got, "for nobody") || strings.Contains(got, "acme") {
+		t.Errorf("primary output got %q", got)
+	}
+}
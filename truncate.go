@@ -0,0 +1,113 @@
+package log
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"unicode/utf8"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// Suffixes of the keys of the fields added for a truncated value, i.e.
+// "payload_sha256_8" and "payload_len" for the field "payload".
+const (
+	// TruncatedHashSuffix is the suffix of the field with the first 8 hex
+	// digits of the SHA-256 hash of the whole value, as printed by
+	// `sha256sum | cut -c1-8`.
+	TruncatedHashSuffix = "_sha256_8"
+	// TruncatedLenSuffix is the suffix of the field with the length of the
+	// whole value in bytes.
+	TruncatedLenSuffix = "_len"
+)
+
+var _ zapcore.Core = (*truncateCore)(nil)
+
+// truncateCore truncates the values of string and byte slice fields to a
+// maximum size, adding the hash and the length of the whole value, so that
+// truncated values can still be matched across entries and verified.
+type truncateCore struct {
+	next    zapcore.Core
+	maxSize int
+}
+
+func (c *truncateCore) With(fields []zapcore.Field) zapcore.Core {
+	return &truncateCore{next: c.next.With(c.truncate(fields)), maxSize: c.maxSize}
+}
+
+func (c *truncateCore) Enabled(lvl zapcore.Level) bool {
+	return c.next.Enabled(lvl)
+}
+
+func (c *truncateCore) Check(ent zapcore.Entry, ce *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if c.next.Enabled(ent.Level) {
+		return ce.AddCore(ent, c)
+	}
+	return ce
+}
+
+func (c *truncateCore) Write(ent zapcore.Entry, fields []zapcore.Field) error {
+	return writeChecked(c.next, ent, c.truncate(fields))
+}
+
+func (c *truncateCore) Sync() error {
+	return c.next.Sync()
+}
+
+// truncate returns fields with large values truncated, each followed by the
+// fields with its hash and length. fields is not modified.
+func (c *truncateCore) truncate(fields []zapcore.Field) []zapcore.Field {
+	var out []zapcore.Field
+	for i, f := range fields {
+		var data []byte
+		truncated := f
+		switch f.Type {
+		case zapcore.StringType:
+			if len(f.String) > c.maxSize {
+				data = []byte(f.String)
+				truncated.String = truncateString(f.String, c.maxSize)
+			}
+		case zapcore.ByteStringType, zapcore.BinaryType:
+			if b, ok := f.Interface.([]byte); ok && len(b) > c.maxSize {
+				data = b
+				if f.Type == zapcore.ByteStringType {
+					truncated.Interface = []byte(truncateString(string(b), c.maxSize))
+				} else {
+					truncated.Interface = b[:c.maxSize:c.maxSize]
+				}
+			}
+		}
+		if data == nil {
+			if out != nil {
+				out = append(out, f)
+			}
+			continue
+		}
+
+		if out == nil {
+			out = append(make([]zapcore.Field, 0, len(fields)+2), fields[:i]...)
+		}
+		sum := sha256.Sum256(data)
+		out = append(out,
+			truncated,
+			zap.String(f.Key+TruncatedHashSuffix, hex.EncodeToString(sum[:4])),
+			zap.Int(f.Key+TruncatedLenSuffix, len(data)),
+		)
+	}
+	if out == nil {
+		return fields
+	}
+	return out
+}
+
+// truncateString returns the longest prefix of s of at most n bytes that
+// does not split a UTF-8 encoded rune.
+func truncateString(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	for n > 0 && !utf8.RuneStart(s[n]) {
+		n--
+	}
+	return s[:n]
+}
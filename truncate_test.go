@@ -0,0 +1,65 @@
+package log
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+func TestTruncateCore(t *testing.T) {
+	buf := &bytes.Buffer{}
+	core := &truncateCore{next: newCore(JSONOutput, zapcore.AddSync(buf), LevelDebug, nil), maxSize: 8}
+	large := strings.Repeat("x", 100)
+	logger := zap.New(core).With(zap.Binary("payload", []byte(large)))
+	logger.Info("truncated", zap.String("small", "value"), zap.String("large", large), zap.String("after", "a"))
+
+	var entry map[string]interface{}
+	if err := json.Unmarshal(buf.Bytes(), &entry); err != nil {
+		t.Fatal(err)
+	}
+	sum := sha256.Sum256([]byte(large))
+	hash := hex.EncodeToString(sum[:])[:8]
+	if entry["small"] != "value" || entry["after"] != "a" {
+		t.Errorf("got entry %v", entry)
+	}
+	if entry["large"] != "xxxxxxxx" || entry["large"+TruncatedHashSuffix] != hash || entry["large"+TruncatedLenSuffix] != float64(100) {
+		t.Errorf("got large %v", entry)
+	}
+	// base64 of 8 bytes of x.
+	if entry["payload"] != "eHh4eHh4eHg=" || entry["payload"+TruncatedHashSuffix] != hash {
+		t.Errorf("got payload %v", entry)
+	}
+	if _, ok := entry["small"+TruncatedLenSuffix]; ok {
+		t.Error("got the length of a small value")
+	}
+}
+
+func TestTruncateString(t *testing.T) {
+	for _, tc := range []struct {
+		s    string
+		n    int
+		want string
+	}{
+		{"abc", 5, "abc"},
+		{"abcdef", 3, "abc"},
+		{"aé", 2, "a"},
+		{"日本", 4, "日"},
+	} {
+		if got := truncateString(tc.s, tc.n); got != tc.want {
+			t.Errorf("truncateString(%q, %d) = %q, want %q", tc.s, tc.n, got, tc.want)
+		}
+	}
+}
+
+func TestTruncateCoreWriteError(t *testing.T) {
+	core := &truncateCore{next: newCore(JSONOutput, failingWriteSyncer{}, LevelDebug, nil), maxSize: 8}
+	if err := core.Write(zapcore.Entry{Level: zapcore.InfoLevel}, nil); err == nil {
+		t.Error("expected the write error of the output")
+	}
+}
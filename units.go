@@ -0,0 +1,96 @@
+package log
+
+import (
+	"math"
+	"strconv"
+
+	"go.uber.org/zap/buffer"
+	"go.uber.org/zap/zapcore"
+)
+
+// byteSize and byteRate are logged as plain numbers in JSON output and in
+// human-readable units in the other formats.
+type (
+	byteSize int64
+	byteRate float64
+)
+
+// Bytes constructs a field with a byte count, i.e. "1.5 MiB" in
+// human-readable output. JSON output has the number of bytes.
+func Bytes(key string, n int64) zapcore.Field {
+	return zapcore.Field{Key: key, Type: zapcore.ReflectType, Interface: byteSize(n)}
+}
+
+// BytesPerSecond constructs a field with a transfer rate, i.e. "2.3 MiB/s" in
+// human-readable output. JSON output has the number of bytes per second.
+func BytesPerSecond(key string, rate float64) zapcore.Field {
+	return zapcore.Field{Key: key, Type: zapcore.ReflectType, Interface: byteRate(rate)}
+}
+
+var byteUnits = []string{"B", "KiB", "MiB", "GiB", "TiB", "PiB", "EiB"}
+
+// humanBytes renders n bytes in binary units with one decimal.
+func humanBytes(n float64) string {
+	unit := 0
+	for math.Abs(n) >= 1024 && unit < len(byteUnits)-1 {
+		n /= 1024
+		unit++
+	}
+	if unit == 0 {
+		return strconv.FormatFloat(n, 'f', -1, 64) + " B"
+	}
+	return strconv.FormatFloat(math.Round(n*10)/10, 'f', -1, 64) + " " + byteUnits[unit]
+}
+
+// humanize returns the human-readable form of the value of a Bytes or
+// BytesPerSecond field.
+func humanize(v interface{}) (string, bool) {
+	switch v := v.(type) {
+	case byteSize:
+		return humanBytes(float64(v)), true
+	case byteRate:
+		return humanBytes(float64(v)) + "/s", true
+	}
+	return "", false
+}
+
+var _ zapcore.Encoder = (*humanEncoder)(nil)
+
+// humanEncoder renders Bytes and BytesPerSecond fields in human-readable
+// units.
+type humanEncoder struct {
+	zapcore.Encoder
+}
+
+func (enc *humanEncoder) Clone() zapcore.Encoder {
+	return &humanEncoder{enc.Encoder.Clone()}
+}
+
+// AddReflected is used for fields added with With.
+func (enc *humanEncoder) AddReflected(key string, v interface{}) error {
+	if s, ok := humanize(v); ok {
+		enc.Encoder.AddString(key, s)
+		return nil
+	}
+	return enc.Encoder.AddReflected(key, v)
+}
+
+func (enc *humanEncoder) EncodeEntry(ent zapcore.Entry, fields []zapcore.Field) (*buffer.Buffer, error) {
+	var rendered []zapcore.Field
+	for i, f := range fields {
+		if f.Type != zapcore.ReflectType {
+			continue
+		}
+		if s, ok := humanize(f.Interface); ok {
+			if rendered == nil {
+				// don't modify the caller's fields.
+				rendered = append([]zapcore.Field(nil), fields...)
+			}
+			rendered[i] = zapcore.Field{Key: f.Key, Type: zapcore.StringType, String: s}
+		}
+	}
+	if rendered != nil {
+		fields = rendered
+	}
+	return enc.Encoder.EncodeEntry(ent, fields)
+}
@@ -0,0 +1,72 @@
+package log
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+func TestHumanBytes(t *testing.T) {
+	for n, want := range map[float64]string{
+		0:                "0 B",
+		1023:             "1023 B",
+		1024:             "1 KiB",
+		1536:             "1.5 KiB",
+		5 << 20:          "5 MiB",
+		-3 << 30:         "-3 GiB",
+		1.25 * (1 << 40): "1.3 TiB",
+	} {
+		if got := humanBytes(n); got != want {
+			t.Errorf("humanBytes(%v) = %q, want %q", n, got, want)
+		}
+	}
+}
+
+func TestHumanUnits(t *testing.T) {
+	for _, tc := range []struct {
+		format LogFormat
+		want   string
+	}{
+		{PlaintextOutput, `{"conn": "1 KiB", "size": "1.5 MiB", "rate": "2 KiB/s", "took": "1.5s"}`},
+		{JSONOutput, `"conn":1024,"size":1572864,"rate":2048,"took":1.5}`},
+	} {
+		buf := &bytes.Buffer{}
+		logger := zap.New(newCore(tc.format, zapcore.AddSync(buf), LevelDebug, nil)).
+			With(Bytes("conn", 1024))
+		logger.Info("transfer", Bytes("size", 3<<19), BytesPerSecond("rate", 2048), zap.Duration("took", 1500*time.Millisecond))
+		if got := buf.String(); !strings.Contains(got, tc.want) {
+			t.Errorf("%v: got %q, want %q", tc.format, got, tc.want)
+		}
+	}
+}
+
+func TestHumanUnitsWithEncoderWorkers(t *testing.T) {
+	logfile, err := ioutil.TempFile("", "go-log-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(logfile.Name())
+
+	SetupLogging(Config{Format: PlaintextOutput, File: logfile.Name(), EncoderWorkers: 2})
+	defer SetupLogging(Config{Stderr: true})
+
+	log := getLogger("units-test")
+	log.Desugar().Error("transfer", Bytes("size", 2<<20), BytesPerSecond("rate", 3<<20))
+	if err := log.Sync(); err != nil {
+		t.Fatal(err)
+	}
+
+	content, err := ioutil.ReadFile(logfile.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(content), `{"size": "2 MiB", "rate": "3 MiB/s"}`) {
+		t.Errorf("got %q", content)
+	}
+}